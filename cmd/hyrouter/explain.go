@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/plugins"
+	"github.com/hybrowse/hyrouter/internal/routing"
+	"github.com/hybrowse/hyrouter/internal/server"
+)
+
+var explainConfig = server.ExplainConfig
+
+func runExplain(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("hyrouter explain", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "Path to config file")
+	logLevel := fs.String("log-level", "warn", "Log level (debug|info|warn|error)")
+	sni := fs.String("sni", "", "SNI hostname of the simulated connection")
+	uuid := fs.String("uuid", "", "Player UUID")
+	username := fs.String("username", "", "Player username")
+	language := fs.String("language", "", "Client language (e.g. de-DE)")
+	clientIP := fs.String("client-ip", "", "Client IP address (for client_ip hash keys and source_cidrs)")
+	alpn := fs.String("alpn", "", "Negotiated ALPN protocol")
+	protocolHash := fs.String("protocol-hash", "", "Client protocol hash")
+	buildNumber := fs.Int("protocol-build-number", 0, "Client protocol build number")
+	clientVersion := fs.String("client-version", "", "Client version")
+	clientType := fs.Int("client-type", -1, "Client type (-1 for unknown)")
+	asJSON := fs.Bool("json", false, "Print the explanation as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	lvl, err := parseLogLevel(*logLevel)
+	if err != nil {
+		return err
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: lvl}))
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return err
+	}
+
+	req := server.ExplainRequest{
+		SNI:                 *sni,
+		UUID:                *uuid,
+		Username:            *username,
+		Language:            *language,
+		ClientIP:            *clientIP,
+		ALPN:                *alpn,
+		ProtocolHash:        *protocolHash,
+		ProtocolBuildNumber: *buildNumber,
+		ClientVersion:       *clientVersion,
+	}
+	if *clientType >= 0 {
+		if *clientType > 255 {
+			return fmt.Errorf("-client-type must be between 0 and 255")
+		}
+		ct := uint8(*clientType)
+		req.ClientType = &ct
+	}
+	ex, err := explainConfig(ctx, cfg, logger, req)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(ex)
+	}
+	writeExplanation(w, ex)
+	return nil
+}
+
+func writeExplanation(w io.Writer, ex *server.Explanation) {
+	tr := ex.Trace
+	switch {
+	case tr == nil:
+		fmt.Fprintf(w, "route: %d\n", ex.Decision.RouteIndex)
+	case tr.Matched && tr.Pattern != "":
+		fmt.Fprintf(w, "route: %s (sni %q matched %q)\n", tr.Route, tr.SNI, tr.Pattern)
+	case tr.Matched:
+		fmt.Fprintf(w, "route: %s (match conditions met)\n", tr.Route)
+	case tr.Route != "":
+		fmt.Fprintf(w, "route: %s (no route matched sni %q)\n", tr.Route, tr.SNI)
+	default:
+		fmt.Fprintf(w, "route: none (no route matched sni %q and routing.default is not set)\n", tr.SNI)
+	}
+	if tr != nil {
+		if tr.SplitArm != "" {
+			fmt.Fprintf(w, "split arm: %s\n", tr.SplitArm)
+		}
+		if tr.Maintenance {
+			fmt.Fprintln(w, "maintenance: route is in maintenance")
+		}
+		if len(tr.Candidates) > 0 {
+			fmt.Fprintf(w, "candidates: %s\n", joinTargets(candidateTargets(tr.Candidates)))
+		}
+		for _, at := range tr.Attempts {
+			step := at.Step
+			if step == tr.Step {
+				step += " (used)"
+			}
+			fmt.Fprintf(w, "attempt %s: strategy=%s\n", step, at.Strategy)
+			for i, f := range at.Filters {
+				fmt.Fprintf(w, "  filter[%d]: %s\n", i, describeFilter(f))
+			}
+			for _, b := range at.Backends {
+				if len(at.Filters) == 0 {
+					break
+				}
+				results := make([]string, 0, len(b.Filters))
+				for _, ok := range b.Filters {
+					results = append(results, passFail(ok))
+				}
+				fmt.Fprintf(w, "  %s: %s [%s]\n", target(b.Target), passFail(b.Pass), strings.Join(results, " "))
+			}
+			if len(at.Sort) > 0 {
+				fmt.Fprintf(w, "  sorted: %s\n", joinTargets(at.Sorted))
+			}
+			if len(at.Truncated) > 0 {
+				fmt.Fprintf(w, "  limit %d dropped: %s\n", at.Limit, joinTargets(at.Truncated))
+			}
+			if at.Error != "" {
+				fmt.Fprintf(w, "  error: %s\n", at.Error)
+			} else if at.SelectedIndex >= 0 && at.SelectedIndex < len(at.Sorted) {
+				fmt.Fprintf(w, "  pick: %s (index %d)\n", target(at.Sorted[at.SelectedIndex]), at.SelectedIndex)
+			}
+		}
+	}
+	if ex.RoutingError != "" {
+		fmt.Fprintf(w, "routing error: %s\n", ex.RoutingError)
+	}
+	for _, st := range ex.Plugins {
+		switch {
+		case st.Error != "":
+			fmt.Fprintf(w, "plugin %s: error: %s\n", st.Plugin, st.Error)
+		case st.Response != nil && st.Response.Deny:
+			fmt.Fprintf(w, "plugin %s: deny %q\n", st.Plugin, st.Response.DenyReason)
+		default:
+			fmt.Fprintf(w, "plugin %s: %s -> backend=%s referral_content_len=%d\n", st.Plugin, describeResponse(st), target(st.Backend.Target()), st.ReferralContentLen)
+		}
+	}
+	switch ex.Outcome {
+	case "referral":
+		fmt.Fprintf(w, "result: referral to %s\n", target(ex.Backend.Target()))
+	default:
+		fmt.Fprintf(w, "result: disconnect (%s) %q\n", ex.DisconnectKind, ex.DisconnectReason)
+	}
+}
+
+func describeFilter(f routing.Filter) string {
+	parts := []string{f.Type}
+	add := func(k, v string) {
+		if v != "" {
+			parts = append(parts, k+"="+v)
+		}
+	}
+	add("subject", f.Subject)
+	add("left", f.Left)
+	add("op", f.Op)
+	add("right", f.Right)
+	add("enabled_key", f.EnabledKey)
+	add("list_key", f.ListKey)
+	add("key", f.Key)
+	add("value", f.Value)
+	add("values", strings.Join(f.Values, ","))
+	for i, sub := range f.Filters {
+		add(fmt.Sprintf("filters[%d]", i), "("+describeFilter(sub)+")")
+	}
+	if f.Expr != "" {
+		add("expr", fmt.Sprintf("%q", f.Expr))
+	}
+	return strings.Join(parts, " ")
+}
+
+func describeResponse(st plugins.Step) string {
+	r := st.Response
+	if r == nil {
+		return "no response"
+	}
+	var changes []string
+	if r.Candidates != nil {
+		changes = append(changes, fmt.Sprintf("candidates=%d", len(r.Candidates)))
+	}
+	if r.SelectedIndex != nil {
+		changes = append(changes, fmt.Sprintf("selected_index=%d", *r.SelectedIndex))
+	}
+	if r.Backend != nil {
+		changes = append(changes, "backend="+target(r.Backend.Target()))
+	}
+	if r.ReferralContent != nil {
+		changes = append(changes, fmt.Sprintf("referral_content=%d bytes", len(r.ReferralContent)))
+	}
+	if len(changes) == 0 {
+		return "no changes"
+	}
+	return strings.Join(changes, " ")
+}
+
+func passFail(ok bool) string {
+	if ok {
+		return "pass"
+	}
+	return "fail"
+}
+
+func target(t routing.Target) string {
+	if t.Host == "" {
+		return "-"
+	}
+	return fmt.Sprintf("%s:%d", t.Host, t.Port)
+}
+
+func candidateTargets(bs []routing.Backend) []routing.Target {
+	out := make([]routing.Target, 0, len(bs))
+	for _, b := range bs {
+		out = append(out, b.Target())
+	}
+	return out
+}
+
+func joinTargets(ts []routing.Target) string {
+	parts := make([]string, 0, len(ts))
+	for _, t := range ts {
+		parts = append(parts, target(t))
+	}
+	return strings.Join(parts, ", ")
+}
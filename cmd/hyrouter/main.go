@@ -4,6 +4,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -21,6 +22,8 @@ var runServer = func(ctx context.Context, cfg *config.Config, logger *slog.Logge
 
 var osExit = os.Exit
 
+var stdout io.Writer = os.Stdout
+
 func main() {
 	osExit(mainMain(os.Args[1:]))
 }
@@ -38,6 +41,15 @@ func mainMain(args []string) int {
 }
 
 func run(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "explain":
+			return runExplain(ctx, args[1:], stdout)
+		case "validate":
+			return runValidate(ctx, args[1:], stdout)
+		}
+	}
+
 	fs := flag.NewFlagSet("hyrouter", flag.ContinueOnError)
 	configPath := fs.String("config", "config.yaml", "Path to config file")
 	logLevel := fs.String("log-level", "info", "Log level (debug|info|warn|error)")
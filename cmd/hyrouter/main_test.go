@@ -1,10 +1,12 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/hybrowse/hyrouter/internal/config"
@@ -136,3 +138,80 @@ func TestMain_CallsExitOnError(t *testing.T) {
 		t.Fatalf("expected non-zero")
 	}
 }
+
+func TestRunExplain(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	b := []byte("listen: ':5520'\nrouting:\n  routes:\n    - match:\n        hostname: play.example.com\n      pool:\n        strategy: round_robin\n        filters:\n          - type: whitelist\n            enabled_key: label.wl\n            list_key: annotation.wl\n        backends:\n          - host: a\n            port: 1\n            meta:\n              label.wl: 'true'\n          - host: b\n            port: 2\n")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	var out bytes.Buffer
+	prev := stdout
+	stdout = &out
+	defer func() { stdout = prev }()
+
+	if err := run(context.Background(), []string{"explain", "-config", path, "-sni", "play.example.com", "-uuid", "u"}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	text := out.String()
+	for _, want := range []string{
+		`route: routing.routes[0] (sni "play.example.com" matched "play.example.com")`,
+		"a:1: fail [fail]",
+		"b:2: pass [pass]",
+		"pick: b:2 (index 0)",
+		"result: referral to b:2",
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("missing %q in:\n%s", want, text)
+		}
+	}
+
+	out.Reset()
+	if err := run(context.Background(), []string{"explain", "-config", path, "-sni", "other", "-json"}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !strings.Contains(out.String(), `"disconnect_kind": "no_route"`) {
+		t.Fatalf("out=%s", out.String())
+	}
+
+	if err := run(context.Background(), []string{"explain", "-config", filepath.Join(dir, "missing.yaml")}); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestRunValidate(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.yaml")
+	if err := os.WriteFile(good, []byte("listen: ':5520'\nrouting:\n  default:\n    strategy: round_robin\n    backends:\n      - host: a\n        port: 1\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	bad := filepath.Join(dir, "bad.yaml")
+	if err := os.WriteFile(bad, []byte("listen: ''\nrouting:\n  default:\n    strategy: nope\n    backends:\n      - host: a\n        port: 0\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	var out bytes.Buffer
+	prev := stdout
+	stdout = &out
+	defer func() { stdout = prev }()
+
+	if err := run(context.Background(), []string{"validate", "-config", good}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !strings.Contains(out.String(), "good.yaml: ok") {
+		t.Fatalf("out=%s", out.String())
+	}
+
+	out.Reset()
+	err := run(context.Background(), []string{"validate", "-config", bad})
+	if err == nil || !strings.Contains(err.Error(), "3 problem(s) found") {
+		t.Fatalf("err=%v", err)
+	}
+	for _, want := range []string{"listen must not be empty", `routing.default: unknown strategy "nope"`, "routing.default.backends[0]: port must be between 1 and 65535"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("missing %q in:\n%s", want, out.String())
+		}
+	}
+}
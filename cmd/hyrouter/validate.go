@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/validate"
+)
+
+func runValidate(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("hyrouter validate", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "Path to config file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.Parse(*configPath)
+	if err != nil {
+		return err
+	}
+	problems := validate.Config(ctx, cfg)
+	for _, p := range problems {
+		fmt.Fprintln(w, p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s: %d problem(s) found", *configPath, len(problems))
+	}
+	fmt.Fprintf(w, "%s: ok\n", *configPath)
+	return nil
+}
@@ -0,0 +1,50 @@
+//go:build examples
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/discovery"
+	"github.com/hybrowse/hyrouter/internal/routing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func main() {
+	listen := flag.String("listen", "127.0.0.1:7778", "listen address")
+	flag.Parse()
+
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		panic(err)
+	}
+
+	srv := discovery.NewSnapshotServer()
+	srv.Set([]routing.Backend{
+		{Host: "play.hyvane.com", Port: 5520, Meta: map[string]string{"label.mode": "lobby"}},
+	})
+
+	// Pretend an orchestrator starts and stops a second server every few seconds.
+	go func() {
+		extra := routing.Backend{Host: "127.0.0.1", Port: 5521, Meta: map[string]string{"label.mode": "lobby"}}
+		for up := true; ; up = !up {
+			time.Sleep(5 * time.Second)
+			if up {
+				srv.Upsert(extra)
+			} else {
+				srv.Remove(extra.Target())
+			}
+			fmt.Printf("extra backend up=%v\n", up)
+		}
+	}()
+
+	s := grpc.NewServer(grpc.Creds(insecure.NewCredentials()))
+	discovery.RegisterGRPCServer(s, srv)
+	if err := s.Serve(l); err != nil {
+		panic(err)
+	}
+}
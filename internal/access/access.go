@@ -0,0 +1,259 @@
+package access
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/filewatch"
+)
+
+// Rules are CIDR allow/deny lists. Entries are CIDR prefixes or single addresses.
+// Files contain one entry per line; empty lines and text after # are ignored.
+type Rules struct {
+	Allow      []string `json:"allow" yaml:"allow"`
+	Deny       []string `json:"deny" yaml:"deny"`
+	AllowFiles []string `json:"allow_files" yaml:"allow_files"`
+	DenyFiles  []string `json:"deny_files" yaml:"deny_files"`
+}
+
+// Validate checks the inline entries of r. Files are only read by NewList.
+func (r *Rules) Validate(path string) []error {
+	if r == nil {
+		return nil
+	}
+	var errs []error
+	for i, s := range r.Allow {
+		if _, err := ParsePrefix(s); err != nil {
+			errs = append(errs, fmt.Errorf("%s.allow[%d]: %w", path, i, err))
+		}
+	}
+	for i, s := range r.Deny {
+		if _, err := ParsePrefix(s); err != nil {
+			errs = append(errs, fmt.Errorf("%s.deny[%d]: %w", path, i, err))
+		}
+	}
+	for i, f := range r.AllowFiles {
+		if strings.TrimSpace(f) == "" {
+			errs = append(errs, fmt.Errorf("%s.allow_files[%d] must not be empty", path, i))
+		}
+	}
+	for i, f := range r.DenyFiles {
+		if strings.TrimSpace(f) == "" {
+			errs = append(errs, fmt.Errorf("%s.deny_files[%d] must not be empty", path, i))
+		}
+	}
+	return errs
+}
+
+// ParsePrefix parses a CIDR prefix or a single IP address (as a /32 or /128 prefix).
+func ParsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid CIDR %q", s)
+		}
+		return p.Masked(), nil
+	}
+	a, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid IP address %q", s)
+	}
+	a = a.Unmap()
+	return netip.PrefixFrom(a, a.BitLen()), nil
+}
+
+// List is a compiled set of Rules. File-backed entries can be reloaded; a file that fails to
+// load keeps its previous entries.
+type List struct {
+	rules Rules
+
+	mu    sync.RWMutex
+	allow []netip.Prefix
+	deny  []netip.Prefix
+	files map[string][]netip.Prefix
+}
+
+func NewList(r Rules) (*List, error) {
+	l := &List{rules: r, files: map[string][]netip.Prefix{}}
+	for _, s := range r.Allow {
+		p, err := ParsePrefix(s)
+		if err != nil {
+			return nil, err
+		}
+		l.allow = append(l.allow, p)
+	}
+	for _, s := range r.Deny {
+		p, err := ParsePrefix(s)
+		if err != nil {
+			return nil, err
+		}
+		l.deny = append(l.deny, p)
+	}
+	for _, f := range l.Files() {
+		if err := l.ReloadFile(f); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Files returns the allow and deny files of the list.
+func (l *List) Files() []string {
+	return append(append([]string(nil), l.rules.AllowFiles...), l.rules.DenyFiles...)
+}
+
+// ReloadFile re-reads path. On error the previous entries of path are kept.
+func (l *List) ReloadFile(path string) error {
+	prefixes, err := readPrefixFile(path)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.files[path] = prefixes
+	l.mu.Unlock()
+	return nil
+}
+
+// Allowed reports whether addr passes the list: it must not be denied and, if the list has
+// any allow entries, it must be allowed.
+func (l *List) Allowed(addr netip.Addr) bool {
+	if l == nil {
+		return true
+	}
+	addr = addr.Unmap()
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if contains(l.deny, addr) {
+		return false
+	}
+	for _, f := range l.rules.DenyFiles {
+		if contains(l.files[f], addr) {
+			return false
+		}
+	}
+	if len(l.rules.Allow) == 0 && len(l.rules.AllowFiles) == 0 {
+		return true
+	}
+	if contains(l.allow, addr) {
+		return true
+	}
+	for _, f := range l.rules.AllowFiles {
+		if contains(l.files[f], addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func readPrefixFile(path string) ([]netip.Prefix, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read access list: %w", err)
+	}
+	var out []netip.Prefix
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	line := 0
+	for sc.Scan() {
+		line++
+		s := sc.Text()
+		if i := strings.IndexByte(s, '#'); i >= 0 {
+			s = s[:i]
+		}
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		p, err := ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		out = append(out, p)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read access list: %w", err)
+	}
+	return out, nil
+}
+
+// Policy combines a global list with per-route lists (keyed by route index).
+// All methods are safe to call on a nil *Policy, which allows everything.
+type Policy struct {
+	global *List
+	routes map[int]*List
+}
+
+func NewPolicy(global *Rules, routes map[int]*Rules) (*Policy, error) {
+	p := &Policy{routes: map[int]*List{}}
+	if global != nil {
+		l, err := NewList(*global)
+		if err != nil {
+			return nil, err
+		}
+		p.global = l
+	}
+	for i, r := range routes {
+		if r == nil {
+			continue
+		}
+		l, err := NewList(*r)
+		if err != nil {
+			return nil, fmt.Errorf("routing.routes[%d].access: %w", i, err)
+		}
+		p.routes[i] = l
+	}
+	return p, nil
+}
+
+// Allowed reports whether addr may connect to the route with index routeIndex (-1 for the default route or no route).
+func (p *Policy) Allowed(addr netip.Addr, routeIndex int) bool {
+	if p == nil {
+		return true
+	}
+	if !p.global.Allowed(addr) {
+		return false
+	}
+	return p.routes[routeIndex].Allowed(addr)
+}
+
+// Watch reloads list files whenever they change, until ctx is done.
+func (p *Policy) Watch(ctx context.Context, interval time.Duration, logger *slog.Logger) {
+	if p == nil {
+		return
+	}
+	lists := []*List{p.global}
+	for _, l := range p.routes {
+		lists = append(lists, l)
+	}
+	for _, l := range lists {
+		if l == nil {
+			continue
+		}
+		for _, f := range l.Files() {
+			go filewatch.Poll(ctx, f, interval, func() {
+				if err := l.ReloadFile(f); err != nil {
+					logger.Warn("access list reload failed; keeping previous entries", "path", f, "error", err)
+					return
+				}
+				logger.Info("access list reloaded", "path", f)
+			})
+		}
+	}
+}
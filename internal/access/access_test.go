@@ -0,0 +1,96 @@
+package access
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListAllowDeny(t *testing.T) {
+	l, err := NewList(Rules{Allow: []string{"10.0.0.0/8", "2001:db8::/32"}, Deny: []string{"10.1.2.3"}})
+	if err != nil {
+		t.Fatalf("NewList: %v", err)
+	}
+	cases := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "10.9.9.9", want: true},
+		{addr: "::ffff:10.9.9.9", want: true},
+		{addr: "10.1.2.3", want: false},
+		{addr: "192.0.2.1", want: false},
+		{addr: "2001:db8::1", want: true},
+	}
+	for _, c := range cases {
+		if got := l.Allowed(netip.MustParseAddr(c.addr)); got != c.want {
+			t.Fatalf("Allowed(%s)=%v want %v", c.addr, got, c.want)
+		}
+	}
+}
+
+func TestListFileReloadKeepsPreviousOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deny.txt")
+	if err := os.WriteFile(path, []byte("# abusive networks\n198.51.100.0/24 # hosting\n\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	l, err := NewList(Rules{DenyFiles: []string{path}})
+	if err != nil {
+		t.Fatalf("NewList: %v", err)
+	}
+	if l.Allowed(netip.MustParseAddr("198.51.100.7")) {
+		t.Fatalf("expected denied address")
+	}
+
+	if err := os.WriteFile(path, []byte("not-an-ip\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := l.ReloadFile(path); err == nil {
+		t.Fatalf("expected reload error")
+	}
+	if l.Allowed(netip.MustParseAddr("198.51.100.7")) {
+		t.Fatalf("expected previous entries to be kept")
+	}
+
+	if err := os.WriteFile(path, []byte("203.0.113.0/24\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := l.ReloadFile(path); err != nil {
+		t.Fatalf("ReloadFile: %v", err)
+	}
+	if !l.Allowed(netip.MustParseAddr("198.51.100.7")) || l.Allowed(netip.MustParseAddr("203.0.113.1")) {
+		t.Fatalf("expected reloaded entries")
+	}
+}
+
+func TestPolicyRoutes(t *testing.T) {
+	p, err := NewPolicy(&Rules{Deny: []string{"192.0.2.0/24"}}, map[int]*Rules{1: {Allow: []string{"203.0.113.0/24"}}})
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+	office := netip.MustParseAddr("203.0.113.5")
+	other := netip.MustParseAddr("198.51.100.5")
+	if !p.Allowed(other, 0) || !p.Allowed(other, -1) {
+		t.Fatalf("expected routes without lists to allow")
+	}
+	if p.Allowed(other, 1) || !p.Allowed(office, 1) {
+		t.Fatalf("expected route 1 to only allow the office network")
+	}
+	if p.Allowed(netip.MustParseAddr("192.0.2.1"), 0) {
+		t.Fatalf("expected global deny")
+	}
+	var nilPolicy *Policy
+	if !nilPolicy.Allowed(other, 1) {
+		t.Fatalf("expected nil policy to allow")
+	}
+}
+
+func TestRulesValidate(t *testing.T) {
+	r := &Rules{Allow: []string{"10.0.0.0/33"}, Deny: []string{"nope"}, AllowFiles: []string{" "}}
+	if errs := r.Validate("access"); len(errs) != 3 {
+		t.Fatalf("errs=%v", errs)
+	}
+	if _, err := NewList(Rules{AllowFiles: []string{filepath.Join(t.TempDir(), "missing")}}); err == nil {
+		t.Fatalf("expected error for missing file")
+	}
+}
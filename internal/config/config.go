@@ -2,27 +2,114 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/hybrowse/hyrouter/internal/access"
 	"github.com/hybrowse/hyrouter/internal/routing"
 	"gopkg.in/yaml.v3"
 	"k8s.io/apimachinery/pkg/labels"
 )
 
 type Config struct {
-	Listen    string           `json:"listen" yaml:"listen"`
-	TLS       TLSConfig        `json:"tls" yaml:"tls"`
-	QUIC      QUICConfig       `json:"quic" yaml:"quic"`
-	Routing   routing.Config   `json:"routing" yaml:"routing"`
-	Referral  *ReferralConfig  `json:"referral" yaml:"referral"`
-	Plugins   []PluginConfig   `json:"plugins" yaml:"plugins"`
-	Discovery *DiscoveryConfig `json:"discovery" yaml:"discovery"`
-	Messages  MessagesConfig   `json:"messages" yaml:"messages"`
-	Logging   LoggingConfig    `json:"logging" yaml:"logging"`
+	Listen             string                    `json:"listen" yaml:"listen"`
+	TLS                TLSConfig                 `json:"tls" yaml:"tls"`
+	QUIC               QUICConfig                `json:"quic" yaml:"quic"`
+	Routing            routing.Config            `json:"routing" yaml:"routing"`
+	Referral           *ReferralConfig           `json:"referral" yaml:"referral"`
+	Plugins            []PluginConfig            `json:"plugins" yaml:"plugins"`
+	Discovery          *DiscoveryConfig          `json:"discovery" yaml:"discovery"`
+	Messages           MessagesConfig            `json:"messages" yaml:"messages"`
+	Logging            LoggingConfig             `json:"logging" yaml:"logging"`
+	Metrics            *MetricsConfig            `json:"metrics" yaml:"metrics"`
+	Reload             ReloadConfig              `json:"reload" yaml:"reload"`
+	Admin              *AdminConfig              `json:"admin" yaml:"admin"`
+	HealthCheck        *HealthCheckConfig        `json:"health_check" yaml:"health_check"`
+	OutlierDetection   *OutlierDetectionConfig   `json:"outlier_detection" yaml:"outlier_detection"`
+	ReferralAccounting *ReferralAccountingConfig `json:"referral_accounting" yaml:"referral_accounting"`
+	Queue              *QueueConfig              `json:"queue" yaml:"queue"`
+	Limits             *LimitsConfig             `json:"limits" yaml:"limits"`
+	Access             *access.Rules             `json:"access" yaml:"access"`
+
+	source string
+}
+
+// Source returns the path the config was loaded from (empty if it was constructed in code).
+func (c *Config) Source() string {
+	if c == nil {
+		return ""
+	}
+	return c.source
+}
+
+type ReloadConfig struct {
+	Watch    bool   `json:"watch" yaml:"watch"`
+	Interval string `json:"interval" yaml:"interval"`
+}
+
+type HealthCheckConfig struct {
+	Interval           string   `json:"interval" yaml:"interval"`
+	Timeout            string   `json:"timeout" yaml:"timeout"`
+	UnhealthyThreshold int      `json:"unhealthy_threshold" yaml:"unhealthy_threshold"`
+	HealthyThreshold   int      `json:"healthy_threshold" yaml:"healthy_threshold"`
+	ALPN               []string `json:"alpn" yaml:"alpn"`
+	FailOpen           bool     `json:"fail_open" yaml:"fail_open"`
+}
+
+type OutlierDetectionConfig struct {
+	Key               string  `json:"key" yaml:"key"`
+	ReturnWindow      string  `json:"return_window" yaml:"return_window"`
+	Window            string  `json:"window" yaml:"window"`
+	FailureRatio      float64 `json:"failure_ratio" yaml:"failure_ratio"`
+	MinReferrals      int     `json:"min_referrals" yaml:"min_referrals"`
+	EjectionDuration  string  `json:"ejection_duration" yaml:"ejection_duration"`
+	MaxEjectedPercent int     `json:"max_ejected_percent" yaml:"max_ejected_percent"`
+}
+
+type ReferralAccountingConfig struct {
+	Window string `json:"window" yaml:"window"`
+}
+
+type QueueConfig struct {
+	MaxLength     int      `json:"max_length" yaml:"max_length"`
+	MaxWait       string   `json:"max_wait" yaml:"max_wait"`
+	RetryInterval string   `json:"retry_interval" yaml:"retry_interval"`
+	PriorityUUIDs []string `json:"priority_uuids" yaml:"priority_uuids"`
+}
+
+type LimitsConfig struct {
+	PerIP       *PerIPLimitConfig `json:"per_ip" yaml:"per_ip"`
+	Global      *RateLimitConfig  `json:"global" yaml:"global"`
+	MaxInFlight int               `json:"max_in_flight" yaml:"max_in_flight"`
+	Action      string            `json:"action" yaml:"action"`
+}
+
+type RateLimitConfig struct {
+	Rate  float64 `json:"rate" yaml:"rate"`
+	Burst int     `json:"burst" yaml:"burst"`
+}
+
+type PerIPLimitConfig struct {
+	Rate       float64 `json:"rate" yaml:"rate"`
+	Burst      int     `json:"burst" yaml:"burst"`
+	IPv4Prefix int     `json:"ipv4_prefix" yaml:"ipv4_prefix"`
+	IPv6Prefix int     `json:"ipv6_prefix" yaml:"ipv6_prefix"`
+}
+
+type AdminConfig struct {
+	Listen string `json:"listen" yaml:"listen"`
+	Token  string `json:"token" yaml:"token"`
+}
+
+type MetricsConfig struct {
+	Listen string `json:"listen" yaml:"listen"`
+	Path   string `json:"path" yaml:"path"`
 }
 
 type LoggingConfig struct {
@@ -44,6 +131,10 @@ type DisconnectMessagesConfig struct {
 	NoBackends     string `json:"no_backends" yaml:"no_backends"`
 	RoutingError   string `json:"routing_error" yaml:"routing_error"`
 	DiscoveryError string `json:"discovery_error" yaml:"discovery_error"`
+	Maintenance    string `json:"maintenance" yaml:"maintenance"`
+	RateLimited    string `json:"rate_limited" yaml:"rate_limited"`
+	AccessDenied   string `json:"access_denied" yaml:"access_denied"`
+	QueueTimeout   string `json:"queue_timeout" yaml:"queue_timeout"`
 }
 
 type TLSConfig struct {
@@ -92,12 +183,29 @@ func Default() *Config {
 				NoBackends:     "The server is full or restarting. Please try again in a moment.",
 				RoutingError:   "The server is currently unreachable. Please try again later.",
 				DiscoveryError: "The server is looking for an available instance. Please try again in a moment.",
+				Maintenance:    "The server is under maintenance. Please try again later.",
+				RateLimited:    "Too many connection attempts. Please wait a moment and try again.",
+				AccessDenied:   "You are not allowed to join this server.",
+				QueueTimeout:   "You waited too long for a free server. Please try again later.",
 			},
 		},
 	}
 }
 
+// Load parses the config at path and validates it.
 func Load(path string) (*Config, error) {
+	cfg, err := Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Parse reads the config at path and applies defaults without validating it.
+func Parse(path string) (*Config, error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read config: %w", err)
@@ -125,136 +233,210 @@ func Load(path string) (*Config, error) {
 				NoBackends:     "Der Server ist gerade voll oder startet neu. Bitte versuche es gleich erneut.",
 				RoutingError:   "Der Server ist aktuell nicht erreichbar. Bitte versuche es später erneut.",
 				DiscoveryError: "Der Server sucht gerade eine freie Instanz. Bitte versuche es gleich erneut.",
+				Maintenance:    "Der Server wird gerade gewartet. Bitte versuche es später erneut.",
+				RateLimited:    "Zu viele Verbindungsversuche. Bitte warte einen Moment und versuche es erneut.",
+				AccessDenied:   "Du darfst diesen Server nicht betreten.",
+				QueueTimeout:   "Es ist zu lange kein Platz frei geworden. Bitte versuche es später erneut.",
 			},
 			"fr": {
 				NoRoute:        "Le serveur est actuellement indisponible.",
 				NoBackends:     "Le serveur est plein ou redémarre. Réessaie dans un instant.",
 				RoutingError:   "Le serveur est actuellement inaccessible. Réessaie plus tard.",
 				DiscoveryError: "Le serveur cherche une instance disponible. Réessaie dans un instant.",
+				Maintenance:    "Le serveur est en maintenance. Réessaie plus tard.",
+				RateLimited:    "Trop de tentatives de connexion. Patiente un instant et réessaie.",
+				AccessDenied:   "Tu n'es pas autorisé à rejoindre ce serveur.",
+				QueueTimeout:   "Aucune place ne s'est libérée à temps. Réessaie plus tard.",
 			},
 			"es": {
 				NoRoute:        "El servidor no está disponible en este momento.",
 				NoBackends:     "El servidor está lleno o reiniciándose. Inténtalo de nuevo en un momento.",
 				RoutingError:   "No se puede acceder al servidor en este momento. Inténtalo más tarde.",
 				DiscoveryError: "El servidor está buscando una instancia disponible. Inténtalo de nuevo en un momento.",
+				Maintenance:    "El servidor está en mantenimiento. Inténtalo más tarde.",
+				RateLimited:    "Demasiados intentos de conexión. Espera un momento e inténtalo de nuevo.",
+				AccessDenied:   "No tienes permiso para unirte a este servidor.",
+				QueueTimeout:   "No se ha liberado ningún hueco a tiempo. Inténtalo más tarde.",
 			},
 			"pt": {
 				NoRoute:        "O servidor não está disponível no momento.",
 				NoBackends:     "O servidor está cheio ou reiniciando. Tente novamente em instantes.",
 				RoutingError:   "Não foi possível acessar o servidor no momento. Tente novamente mais tarde.",
 				DiscoveryError: "O servidor está procurando uma instância disponível. Tente novamente em instantes.",
+				Maintenance:    "O servidor está em manutenção. Tente novamente mais tarde.",
+				RateLimited:    "Muitas tentativas de conexão. Aguarde um momento e tente novamente.",
+				AccessDenied:   "Você não tem permissão para entrar neste servidor.",
+				QueueTimeout:   "Nenhuma vaga foi liberada a tempo. Tente novamente mais tarde.",
 			},
 			"pt-BR": {
 				NoRoute:        "O servidor está indisponível no momento.",
 				NoBackends:     "O servidor está cheio ou reiniciando. Tente novamente em instantes.",
 				RoutingError:   "O servidor está inacessível no momento. Tente novamente mais tarde.",
 				DiscoveryError: "O servidor está procurando uma instância disponível. Tente novamente em instantes.",
+				Maintenance:    "O servidor está em manutenção. Tente novamente mais tarde.",
+				RateLimited:    "Muitas tentativas de conexão. Aguarde um momento e tente novamente.",
+				AccessDenied:   "Você não tem permissão para entrar neste servidor.",
+				QueueTimeout:   "Nenhuma vaga foi liberada a tempo. Tente novamente mais tarde.",
 			},
 			"it": {
 				NoRoute:        "Il server non è disponibile al momento.",
 				NoBackends:     "Il server è pieno o si sta riavviando. Riprova tra un momento.",
 				RoutingError:   "Il server non è raggiungibile al momento. Riprova più tardi.",
 				DiscoveryError: "Il server sta cercando un'istanza disponibile. Riprova tra un momento.",
+				Maintenance:    "Il server è in manutenzione. Riprova più tardi.",
+				RateLimited:    "Troppi tentativi di connessione. Attendi un momento e riprova.",
+				AccessDenied:   "Non hai il permesso di entrare in questo server.",
+				QueueTimeout:   "Non si è liberato nessun posto in tempo. Riprova più tardi.",
 			},
 		}
 	}
 
-	if err := cfg.Validate(); err != nil {
-		return nil, err
-	}
+	cfg.source = path
 
 	return cfg, nil
 }
 
+// Validate reports every problem in c. The returned error joins one error per problem;
+// use Problems to split it.
 func (c *Config) Validate() error {
+	var errs []error
 	if c.Listen == "" {
-		return fmt.Errorf("listen must not be empty")
+		errs = append(errs, fmt.Errorf("listen must not be empty"))
 	}
 	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
-		return fmt.Errorf("tls.cert_file and tls.key_file must be set together")
+		errs = append(errs, fmt.Errorf("tls.cert_file and tls.key_file must be set together"))
 	}
 	if len(c.TLS.ALPN) == 0 {
-		return fmt.Errorf("tls.alpn must not be empty")
+		errs = append(errs, fmt.Errorf("tls.alpn must not be empty"))
 	}
 	if c.QUIC.MaxIdleTimeout != "" {
 		if _, err := time.ParseDuration(c.QUIC.MaxIdleTimeout); err != nil {
-			return fmt.Errorf("invalid quic.max_idle_timeout: %w", err)
+			errs = append(errs, fmt.Errorf("invalid quic.max_idle_timeout: %w", err))
 		}
 	}
 	if err := c.Routing.Validate(); err != nil {
-		return err
+		errs = append(errs, err)
+	}
+	if c.Reload.Interval != "" {
+		d, err := time.ParseDuration(c.Reload.Interval)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid reload.interval: %w", err))
+		} else if d <= 0 {
+			errs = append(errs, fmt.Errorf("reload.interval must be > 0"))
+		}
+	}
+	if c.Metrics != nil {
+		if strings.TrimSpace(c.Metrics.Listen) == "" {
+			errs = append(errs, fmt.Errorf("metrics.listen must not be empty"))
+		}
+		if c.Metrics.Path != "" && !strings.HasPrefix(c.Metrics.Path, "/") {
+			errs = append(errs, fmt.Errorf("metrics.path must start with /"))
+		}
+	}
+	if c.Admin != nil {
+		if strings.TrimSpace(c.Admin.Listen) == "" {
+			errs = append(errs, fmt.Errorf("admin.listen must not be empty"))
+		}
+		if strings.TrimSpace(c.Admin.Token) == "" {
+			errs = append(errs, fmt.Errorf("admin.token must not be empty"))
+		}
+	}
+	if c.HealthCheck != nil {
+		errs = append(errs, c.HealthCheck.validate()...)
 	}
-	if c.Referral != nil {
-		_ = c.Referral.KeyID
-		_ = c.Referral.HMACSecret
+	if c.OutlierDetection != nil {
+		errs = append(errs, c.OutlierDetection.validate()...)
 	}
+	if c.ReferralAccounting != nil {
+		errs = append(errs, c.ReferralAccounting.validate()...)
+	}
+	if c.Queue != nil {
+		errs = append(errs, c.Queue.validate()...)
+	}
+	if c.Limits != nil {
+		errs = append(errs, c.Limits.validate()...)
+	}
+	errs = append(errs, c.Access.Validate("access")...)
 	seen := map[string]struct{}{}
 	for i, p := range c.Plugins {
 		if p.Name == "" {
-			return fmt.Errorf("plugins[%d].name must not be empty", i)
-		}
-		if _, ok := seen[p.Name]; ok {
-			return fmt.Errorf("plugins[%d].name must be unique", i)
+			errs = append(errs, fmt.Errorf("plugins[%d].name must not be empty", i))
+		} else if _, ok := seen[p.Name]; ok {
+			errs = append(errs, fmt.Errorf("plugins[%d].name must be unique", i))
 		}
 		seen[p.Name] = struct{}{}
 		if p.Stage != "" {
 			s := strings.ToLower(p.Stage)
 			if s != "deny" && s != "route" && s != "mutate" {
-				return fmt.Errorf("plugins[%d].stage must be one of: deny, route, mutate", i)
+				errs = append(errs, fmt.Errorf("plugins[%d].stage must be one of: deny, route, mutate", i))
 			}
 		}
 		switch strings.ToLower(p.Type) {
 		case "grpc":
 			if p.GRPC == nil || p.GRPC.Address == "" {
-				return fmt.Errorf("plugins[%d].grpc.address must not be empty", i)
+				errs = append(errs, fmt.Errorf("plugins[%d].grpc.address must not be empty", i))
 			}
 		case "wasm":
 			if p.WASM == nil || p.WASM.Path == "" {
-				return fmt.Errorf("plugins[%d].wasm.path must not be empty", i)
+				errs = append(errs, fmt.Errorf("plugins[%d].wasm.path must not be empty", i))
 			}
 		default:
-			return fmt.Errorf("plugins[%d].type must be one of: grpc, wasm", i)
+			errs = append(errs, fmt.Errorf("plugins[%d].type must be one of: grpc, wasm", i))
 		}
 	}
 	providers := map[string]struct{}{}
 	if c.Discovery != nil {
 		if err := c.Discovery.Validate(); err != nil {
-			return err
+			errs = append(errs, err)
 		}
 		for _, p := range c.Discovery.Providers {
 			providers[p.Name] = struct{}{}
 		}
 	}
 	if err := validateRoutingDiscoveryRefs(c.Routing, providers); err != nil {
-		return err
+		errs = append(errs, err)
 	}
-	return nil
+	return errors.Join(errs...)
+}
+
+// Problems flattens an error returned by Validate into one error per problem.
+func Problems(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if j, ok := err.(interface{ Unwrap() []error }); ok {
+		var out []error
+		for _, e := range j.Unwrap() {
+			out = append(out, Problems(e)...)
+		}
+		return out
+	}
+	return []error{err}
 }
 
 func validateRoutingDiscoveryRefs(r routing.Config, providers map[string]struct{}) error {
-	checkPool := func(path string, p routing.Pool) error {
+	var errs []error
+	checkPool := func(path string, p routing.Pool) {
 		if p.Discovery == nil {
-			return nil
+			return
 		}
 		if len(providers) == 0 {
-			return fmt.Errorf("%s: discovery is configured but top-level discovery section is missing", path)
+			errs = append(errs, fmt.Errorf("%s: discovery is configured but top-level discovery section is missing", path))
+			return
 		}
 		if _, ok := providers[p.Discovery.Provider]; !ok {
-			return fmt.Errorf("%s: unknown discovery provider %q", path, p.Discovery.Provider)
+			errs = append(errs, fmt.Errorf("%s: unknown discovery provider %q", path, p.Discovery.Provider))
 		}
-		return nil
 	}
 	if r.Default != nil {
-		if err := checkPool("routing.default", *r.Default); err != nil {
-			return err
-		}
+		checkPool("routing.default", *r.Default)
 	}
 	for i, rt := range r.Routes {
-		if err := checkPool(fmt.Sprintf("routing.routes[%d].pool", i), rt.Pool); err != nil {
-			return err
+		for _, pp := range rt.Pools(fmt.Sprintf("routing.routes[%d]", i)) {
+			checkPool(pp.Path, pp.Pool)
 		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
 type DiscoveryConfig struct {
@@ -266,6 +448,61 @@ type DiscoveryProviderConfig struct {
 	Type       string                     `json:"type" yaml:"type"`
 	Kubernetes *KubernetesDiscoveryConfig `json:"kubernetes" yaml:"kubernetes"`
 	Agones     *AgonesDiscoveryConfig     `json:"agones" yaml:"agones"`
+	File       *FileDiscoveryConfig       `json:"file" yaml:"file"`
+	DNS        *DNSDiscoveryConfig        `json:"dns" yaml:"dns"`
+	HTTP       *HTTPDiscoveryConfig       `json:"http" yaml:"http"`
+	GRPC       *GRPCDiscoveryConfig       `json:"grpc" yaml:"grpc"`
+	Consul     *ConsulDiscoveryConfig     `json:"consul" yaml:"consul"`
+}
+
+type ConsulDiscoveryConfig struct {
+	Address       string   `json:"address" yaml:"address"`
+	Service       string   `json:"service" yaml:"service"`
+	Tags          []string `json:"tags" yaml:"tags"`
+	Datacenter    string   `json:"datacenter" yaml:"datacenter"`
+	Namespace     string   `json:"namespace" yaml:"namespace"`
+	TokenEnv      string   `json:"token_env" yaml:"token_env"`
+	TokenFile     string   `json:"token_file" yaml:"token_file"`
+	Wait          string   `json:"wait" yaml:"wait"`
+	RetryInterval string   `json:"retry_interval" yaml:"retry_interval"`
+}
+
+type GRPCDiscoveryConfig struct {
+	Address    string            `json:"address" yaml:"address"`
+	Params     map[string]string `json:"params" yaml:"params"`
+	Backoff    string            `json:"backoff" yaml:"backoff"`
+	MaxBackoff string            `json:"max_backoff" yaml:"max_backoff"`
+}
+
+type HTTPDiscoveryConfig struct {
+	URL      string                   `json:"url" yaml:"url"`
+	Watch    string                   `json:"watch" yaml:"watch"`
+	Interval string                   `json:"interval" yaml:"interval"`
+	Timeout  string                   `json:"timeout" yaml:"timeout"`
+	Jitter   string                   `json:"jitter" yaml:"jitter"`
+	Auth     *HTTPDiscoveryAuthConfig `json:"auth" yaml:"auth"`
+}
+
+// HTTPDiscoveryAuthConfig sends a header whose value is read from an environment variable or a file,
+// so the secret itself never appears in the config.
+type HTTPDiscoveryAuthConfig struct {
+	Header    string `json:"header" yaml:"header"`
+	ValueEnv  string `json:"value_env" yaml:"value_env"`
+	ValueFile string `json:"value_file" yaml:"value_file"`
+}
+
+type DNSDiscoveryConfig struct {
+	Name       string   `json:"name" yaml:"name"`
+	Type       string   `json:"type" yaml:"type"`
+	Port       int      `json:"port" yaml:"port"`
+	Servers    []string `json:"servers" yaml:"servers"`
+	MinRefresh string   `json:"min_refresh" yaml:"min_refresh"`
+	MaxRefresh string   `json:"max_refresh" yaml:"max_refresh"`
+}
+
+type FileDiscoveryConfig struct {
+	Path     string `json:"path" yaml:"path"`
+	Interval string `json:"interval" yaml:"interval"`
 }
 
 type KubernetesDiscoveryConfig struct {
@@ -331,51 +568,53 @@ func (c *DiscoveryConfig) Validate() error {
 	if c == nil {
 		return nil
 	}
+	var errs []error
 	seen := map[string]struct{}{}
 	for i, p := range c.Providers {
 		if p.Name == "" {
-			return fmt.Errorf("discovery.providers[%d].name must not be empty", i)
-		}
-		if _, ok := seen[p.Name]; ok {
-			return fmt.Errorf("discovery.providers[%d].name must be unique", i)
+			errs = append(errs, fmt.Errorf("discovery.providers[%d].name must not be empty", i))
+		} else if _, ok := seen[p.Name]; ok {
+			errs = append(errs, fmt.Errorf("discovery.providers[%d].name must be unique", i))
 		}
 		seen[p.Name] = struct{}{}
 		switch strings.ToLower(strings.TrimSpace(p.Type)) {
 		case "kubernetes":
 			if p.Kubernetes == nil {
-				return fmt.Errorf("discovery.providers[%d].kubernetes must be set", i)
+				errs = append(errs, fmt.Errorf("discovery.providers[%d].kubernetes must be set", i))
+				continue
 			}
 			for j, r := range p.Kubernetes.Resources {
 				if r.Selector != nil {
 					labelExpr := strings.TrimSpace(r.Selector.Labels)
 					if labelExpr != "" {
 						if _, err := labels.Parse(labelExpr); err != nil {
-							return fmt.Errorf("discovery.providers[%d].kubernetes.resources[%d].selector.labels is invalid: %w", i, j, err)
+							errs = append(errs, fmt.Errorf("discovery.providers[%d].kubernetes.resources[%d].selector.labels is invalid: %w", i, j, err))
 						}
 					}
 					annExpr := strings.TrimSpace(r.Selector.Annotations)
 					if annExpr != "" {
 						if err := validateAnnotationSelector(annExpr); err != nil {
-							return fmt.Errorf("discovery.providers[%d].kubernetes.resources[%d].selector.annotations is invalid: %w", i, j, err)
+							errs = append(errs, fmt.Errorf("discovery.providers[%d].kubernetes.resources[%d].selector.annotations is invalid: %w", i, j, err))
 						}
 					}
 				}
 			}
 		case "agones":
 			if p.Agones == nil {
-				return fmt.Errorf("discovery.providers[%d].agones must be set", i)
+				errs = append(errs, fmt.Errorf("discovery.providers[%d].agones must be set", i))
+				continue
 			}
 			if p.Agones.Selector != nil {
 				labelExpr := strings.TrimSpace(p.Agones.Selector.Labels)
 				if labelExpr != "" {
 					if _, err := labels.Parse(labelExpr); err != nil {
-						return fmt.Errorf("discovery.providers[%d].agones.selector.labels is invalid: %w", i, err)
+						errs = append(errs, fmt.Errorf("discovery.providers[%d].agones.selector.labels is invalid: %w", i, err))
 					}
 				}
 				annExpr := strings.TrimSpace(p.Agones.Selector.Annotations)
 				if annExpr != "" {
 					if err := validateAnnotationSelector(annExpr); err != nil {
-						return fmt.Errorf("discovery.providers[%d].agones.selector.annotations is invalid: %w", i, err)
+						errs = append(errs, fmt.Errorf("discovery.providers[%d].agones.selector.annotations is invalid: %w", i, err))
 					}
 				}
 			}
@@ -383,20 +622,208 @@ func (c *DiscoveryConfig) Validate() error {
 				addrSrc := strings.ToLower(strings.TrimSpace(p.Agones.Address.Source))
 				if addrSrc != "" {
 					if addrSrc != "address" && addrSrc != "addresses" {
-						return fmt.Errorf("discovery.providers[%d].agones.address.source must be one of: address, addresses", i)
+						errs = append(errs, fmt.Errorf("discovery.providers[%d].agones.address.source must be one of: address, addresses", i))
 					}
 				}
 			}
 			if strings.TrimSpace(p.Agones.AllocateMinInterval) != "" {
 				if _, err := time.ParseDuration(p.Agones.AllocateMinInterval); err != nil {
-					return fmt.Errorf("discovery.providers[%d].agones.allocate_min_interval is invalid: %w", i, err)
+					errs = append(errs, fmt.Errorf("discovery.providers[%d].agones.allocate_min_interval is invalid: %w", i, err))
 				}
 			}
+		case "file":
+			if p.File == nil {
+				errs = append(errs, fmt.Errorf("discovery.providers[%d].file must be set", i))
+				continue
+			}
+			if strings.TrimSpace(p.File.Path) == "" {
+				errs = append(errs, fmt.Errorf("discovery.providers[%d].file.path must not be empty", i))
+			}
+			if strings.TrimSpace(p.File.Interval) != "" {
+				if d, err := time.ParseDuration(p.File.Interval); err != nil {
+					errs = append(errs, fmt.Errorf("discovery.providers[%d].file.interval is invalid: %w", i, err))
+				} else if d <= 0 {
+					errs = append(errs, fmt.Errorf("discovery.providers[%d].file.interval must be > 0", i))
+				}
+			}
+		case "dns":
+			if p.DNS == nil {
+				errs = append(errs, fmt.Errorf("discovery.providers[%d].dns must be set", i))
+				continue
+			}
+			errs = append(errs, p.DNS.validate(fmt.Sprintf("discovery.providers[%d].dns", i))...)
+		case "http":
+			if p.HTTP == nil {
+				errs = append(errs, fmt.Errorf("discovery.providers[%d].http must be set", i))
+				continue
+			}
+			errs = append(errs, p.HTTP.validate(fmt.Sprintf("discovery.providers[%d].http", i))...)
+		case "grpc":
+			if p.GRPC == nil {
+				errs = append(errs, fmt.Errorf("discovery.providers[%d].grpc must be set", i))
+				continue
+			}
+			errs = append(errs, p.GRPC.validate(fmt.Sprintf("discovery.providers[%d].grpc", i))...)
+		case "consul":
+			if p.Consul == nil {
+				errs = append(errs, fmt.Errorf("discovery.providers[%d].consul must be set", i))
+				continue
+			}
+			errs = append(errs, p.Consul.validate(fmt.Sprintf("discovery.providers[%d].consul", i))...)
 		default:
-			return fmt.Errorf("discovery.providers[%d].type must be one of: kubernetes, agones", i)
+			errs = append(errs, fmt.Errorf("discovery.providers[%d].type must be one of: kubernetes, agones, file, dns, http, grpc, consul", i))
 		}
 	}
-	return nil
+	return errors.Join(errs...)
+}
+
+func (c *ConsulDiscoveryConfig) validate(path string) []error {
+	var errs []error
+	if strings.TrimSpace(c.Service) == "" {
+		errs = append(errs, fmt.Errorf("%s.service must not be empty", path))
+	}
+	if strings.TrimSpace(c.Address) != "" {
+		if u, err := url.Parse(strings.TrimSpace(c.Address)); err != nil {
+			errs = append(errs, fmt.Errorf("%s.address is invalid: %w", path, err))
+		} else if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("%s.address must be an absolute http or https URL", path))
+		}
+	}
+	for i, tag := range c.Tags {
+		if strings.TrimSpace(tag) == "" {
+			errs = append(errs, fmt.Errorf("%s.tags[%d] must not be empty", path, i))
+		}
+	}
+	if c.TokenEnv != "" && c.TokenFile != "" {
+		errs = append(errs, fmt.Errorf("%s: token_env and token_file are mutually exclusive", path))
+	}
+	for _, d := range []struct {
+		name  string
+		value string
+	}{{"wait", c.Wait}, {"retry_interval", c.RetryInterval}} {
+		if strings.TrimSpace(d.value) == "" {
+			continue
+		}
+		if v, err := time.ParseDuration(d.value); err != nil {
+			errs = append(errs, fmt.Errorf("%s.%s is invalid: %w", path, d.name, err))
+		} else if v <= 0 {
+			errs = append(errs, fmt.Errorf("%s.%s must be > 0", path, d.name))
+		} else if d.name == "wait" && v > 10*time.Minute {
+			// Consul caps blocking queries at 10 minutes.
+			errs = append(errs, fmt.Errorf("%s.wait must be at most 10m", path))
+		}
+	}
+	return errs
+}
+
+func (c *GRPCDiscoveryConfig) validate(path string) []error {
+	var errs []error
+	if strings.TrimSpace(c.Address) == "" {
+		errs = append(errs, fmt.Errorf("%s.address must not be empty", path))
+	}
+	var bounds [2]time.Duration
+	for i, d := range []struct {
+		name  string
+		value string
+	}{{"backoff", c.Backoff}, {"max_backoff", c.MaxBackoff}} {
+		if strings.TrimSpace(d.value) == "" {
+			continue
+		}
+		if v, err := time.ParseDuration(d.value); err != nil {
+			errs = append(errs, fmt.Errorf("%s.%s is invalid: %w", path, d.name, err))
+		} else if v <= 0 {
+			errs = append(errs, fmt.Errorf("%s.%s must be > 0", path, d.name))
+		} else {
+			bounds[i] = v
+		}
+	}
+	if bounds[0] > 0 && bounds[1] > 0 && bounds[0] > bounds[1] {
+		errs = append(errs, fmt.Errorf("%s.backoff must not be greater than max_backoff", path))
+	}
+	return errs
+}
+
+func (c *HTTPDiscoveryConfig) validate(path string) []error {
+	var errs []error
+	if u, err := url.Parse(strings.TrimSpace(c.URL)); err != nil {
+		errs = append(errs, fmt.Errorf("%s.url is invalid: %w", path, err))
+	} else if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("%s.url must be an absolute http or https URL", path))
+	}
+	switch strings.ToLower(strings.TrimSpace(c.Watch)) {
+	case "", "poll", "long_poll", "sse":
+	default:
+		errs = append(errs, fmt.Errorf("%s.watch must be one of: poll, long_poll, sse", path))
+	}
+	for _, d := range []struct {
+		name  string
+		value string
+	}{{"interval", c.Interval}, {"timeout", c.Timeout}, {"jitter", c.Jitter}} {
+		if strings.TrimSpace(d.value) == "" {
+			continue
+		}
+		if v, err := time.ParseDuration(d.value); err != nil {
+			errs = append(errs, fmt.Errorf("%s.%s is invalid: %w", path, d.name, err))
+		} else if d.name == "jitter" && v < 0 {
+			errs = append(errs, fmt.Errorf("%s.jitter must be >= 0", path))
+		} else if d.name != "jitter" && v <= 0 {
+			errs = append(errs, fmt.Errorf("%s.%s must be > 0", path, d.name))
+		}
+	}
+	if a := c.Auth; a != nil {
+		if (a.ValueEnv == "") == (a.ValueFile == "") {
+			errs = append(errs, fmt.Errorf("%s.auth must set exactly one of value_env, value_file", path))
+		}
+		if a.Header != "" && strings.ContainsAny(a.Header, " :\r\n") {
+			errs = append(errs, fmt.Errorf("%s.auth.header is not a valid header name", path))
+		}
+	}
+	return errs
+}
+
+func (c *DNSDiscoveryConfig) validate(path string) []error {
+	var errs []error
+	if strings.TrimSpace(c.Name) == "" {
+		errs = append(errs, fmt.Errorf("%s.name must not be empty", path))
+	}
+	switch strings.ToLower(strings.TrimSpace(c.Type)) {
+	case "", "srv":
+		if c.Port != 0 {
+			errs = append(errs, fmt.Errorf("%s.port must not be set for SRV records (the port comes from the record)", path))
+		}
+	case "a":
+		if c.Port < 1 || c.Port > 65535 {
+			errs = append(errs, fmt.Errorf("%s.port must be between 1 and 65535", path))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("%s.type must be one of: srv, a", path))
+	}
+	for i, s := range c.Servers {
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			errs = append(errs, fmt.Errorf("%s.servers[%d] must be host:port: %w", path, i, err))
+		}
+	}
+	var bounds [2]time.Duration
+	for i, d := range []struct {
+		name  string
+		value string
+	}{{"min_refresh", c.MinRefresh}, {"max_refresh", c.MaxRefresh}} {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s.%s is invalid: %w", path, d.name, err))
+		} else if v <= 0 {
+			errs = append(errs, fmt.Errorf("%s.%s must be > 0", path, d.name))
+		} else {
+			bounds[i] = v
+		}
+	}
+	if bounds[0] > 0 && bounds[1] > 0 && bounds[0] > bounds[1] {
+		errs = append(errs, fmt.Errorf("%s.min_refresh must not be greater than max_refresh", path))
+	}
+	return errs
 }
 
 func validateAnnotationSelector(expr string) error {
@@ -422,3 +849,142 @@ func validateAnnotationSelector(expr string) error {
 	}
 	return nil
 }
+
+func (c *HealthCheckConfig) validate() []error {
+	var errs []error
+	for _, d := range []struct {
+		name  string
+		value string
+	}{{"interval", c.Interval}, {"timeout", c.Timeout}} {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid health_check.%s: %w", d.name, err))
+		} else if v <= 0 {
+			errs = append(errs, fmt.Errorf("health_check.%s must be > 0", d.name))
+		}
+	}
+	if c.UnhealthyThreshold < 0 {
+		errs = append(errs, fmt.Errorf("health_check.unhealthy_threshold must be >= 0"))
+	}
+	if c.HealthyThreshold < 0 {
+		errs = append(errs, fmt.Errorf("health_check.healthy_threshold must be >= 0"))
+	}
+	for i, p := range c.ALPN {
+		if strings.TrimSpace(p) == "" || strings.Contains(p, "*") {
+			errs = append(errs, fmt.Errorf("health_check.alpn[%d] must be a concrete protocol (e.g. hytale/2)", i))
+		}
+	}
+	return errs
+}
+
+func (c *OutlierDetectionConfig) validate() []error {
+	var errs []error
+	switch strings.ToLower(strings.TrimSpace(c.Key)) {
+	case "", "uuid", "client_ip":
+	default:
+		errs = append(errs, fmt.Errorf("outlier_detection.key must be one of: uuid, client_ip"))
+	}
+	for _, d := range []struct {
+		name  string
+		value string
+	}{{"return_window", c.ReturnWindow}, {"window", c.Window}, {"ejection_duration", c.EjectionDuration}} {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid outlier_detection.%s: %w", d.name, err))
+		} else if v <= 0 {
+			errs = append(errs, fmt.Errorf("outlier_detection.%s must be > 0", d.name))
+		}
+	}
+	if c.FailureRatio < 0 || c.FailureRatio > 1 {
+		errs = append(errs, fmt.Errorf("outlier_detection.failure_ratio must be between 0 and 1"))
+	}
+	if c.MinReferrals < 0 {
+		errs = append(errs, fmt.Errorf("outlier_detection.min_referrals must be >= 0"))
+	}
+	if c.MaxEjectedPercent < 0 || c.MaxEjectedPercent > 100 {
+		errs = append(errs, fmt.Errorf("outlier_detection.max_ejected_percent must be between 0 and 100"))
+	}
+	return errs
+}
+
+func (c *ReferralAccountingConfig) validate() []error {
+	if c.Window == "" {
+		return nil
+	}
+	v, err := time.ParseDuration(c.Window)
+	if err != nil {
+		return []error{fmt.Errorf("invalid referral_accounting.window: %w", err)}
+	}
+	if v <= 0 {
+		return []error{fmt.Errorf("referral_accounting.window must be > 0")}
+	}
+	return nil
+}
+
+func (c *QueueConfig) validate() []error {
+	var errs []error
+	if c.MaxLength < 0 {
+		errs = append(errs, fmt.Errorf("queue.max_length must be >= 0"))
+	}
+	for _, d := range []struct {
+		name  string
+		value string
+	}{{"max_wait", c.MaxWait}, {"retry_interval", c.RetryInterval}} {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid queue.%s: %w", d.name, err))
+		} else if v <= 0 {
+			errs = append(errs, fmt.Errorf("queue.%s must be > 0", d.name))
+		}
+	}
+	for i, u := range c.PriorityUUIDs {
+		if strings.TrimSpace(u) == "" {
+			errs = append(errs, fmt.Errorf("queue.priority_uuids[%d] must not be empty", i))
+		}
+	}
+	return errs
+}
+
+func (c *LimitsConfig) validate() []error {
+	var errs []error
+	switch strings.ToLower(strings.TrimSpace(c.Action)) {
+	case "", "disconnect", "close":
+	default:
+		errs = append(errs, fmt.Errorf("limits.action must be one of: disconnect, close"))
+	}
+	if c.MaxInFlight < 0 {
+		errs = append(errs, fmt.Errorf("limits.max_in_flight must be >= 0"))
+	}
+	if c.Global != nil {
+		if c.Global.Rate <= 0 {
+			errs = append(errs, fmt.Errorf("limits.global.rate must be > 0"))
+		}
+		if c.Global.Burst < 0 {
+			errs = append(errs, fmt.Errorf("limits.global.burst must be >= 0"))
+		}
+	}
+	if c.PerIP != nil {
+		if c.PerIP.Rate <= 0 {
+			errs = append(errs, fmt.Errorf("limits.per_ip.rate must be > 0"))
+		}
+		if c.PerIP.Burst < 0 {
+			errs = append(errs, fmt.Errorf("limits.per_ip.burst must be >= 0"))
+		}
+		if c.PerIP.IPv4Prefix < 0 || c.PerIP.IPv4Prefix > 32 {
+			errs = append(errs, fmt.Errorf("limits.per_ip.ipv4_prefix must be between 0 and 32"))
+		}
+		if c.PerIP.IPv6Prefix < 0 || c.PerIP.IPv6Prefix > 128 {
+			errs = append(errs, fmt.Errorf("limits.per_ip.ipv6_prefix must be between 0 and 128"))
+		}
+	}
+	return errs
+}
@@ -1,10 +1,13 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/hybrowse/hyrouter/internal/access"
 	"github.com/hybrowse/hyrouter/internal/routing"
 )
 
@@ -85,6 +88,24 @@ func TestValidateDiscoveryRefs(t *testing.T) {
 	}
 }
 
+func TestValidatePoolTarget(t *testing.T) {
+	cfg := Default()
+	cfg.Routing.Default = &routing.Pool{Strategy: "round_robin", Target: &routing.TargetTemplate{Host: "${sni}", Port: 5520}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	cfg = Default()
+	cfg.Routing.Default = &routing.Pool{
+		Strategy: "round_robin",
+		Backends: []routing.Backend{{Host: "a", Port: 1}},
+		Target:   &routing.TargetTemplate{Host: "", Port: 0},
+	}
+	if err := cfg.Validate(); err == nil || len(Problems(err)) != 3 {
+		t.Fatalf("expected 3 problems, got %v", err)
+	}
+}
+
 func TestValidateDiscoveryAgonesAllocateMinIntervalInvalid(t *testing.T) {
 	cfg := Default()
 	cfg.Discovery = &DiscoveryConfig{Providers: []DiscoveryProviderConfig{{
@@ -96,3 +117,321 @@ func TestValidateDiscoveryAgonesAllocateMinIntervalInvalid(t *testing.T) {
 		t.Fatalf("expected error")
 	}
 }
+
+func TestValidateDiscoveryFile(t *testing.T) {
+	cfg := Default()
+	cfg.Discovery = &DiscoveryConfig{Providers: []DiscoveryProviderConfig{
+		{Name: "a", Type: "file", File: &FileDiscoveryConfig{Path: "backends.yaml", Interval: "1s"}},
+	}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	cfg.Discovery = &DiscoveryConfig{Providers: []DiscoveryProviderConfig{
+		{Name: "a", Type: "file"},
+		{Name: "b", Type: "file", File: &FileDiscoveryConfig{Interval: "-1s"}},
+	}}
+	problems := Problems(cfg.Validate())
+	if len(problems) != 3 {
+		t.Fatalf("problems=%v", problems)
+	}
+}
+
+func TestValidateDiscoveryDNS(t *testing.T) {
+	cfg := Default()
+	cfg.Discovery = &DiscoveryConfig{Providers: []DiscoveryProviderConfig{
+		{Name: "a", Type: "dns", DNS: &DNSDiscoveryConfig{Name: "_hytale._udp.example.com"}},
+		{Name: "b", Type: "dns", DNS: &DNSDiscoveryConfig{Name: "play.example.com", Type: "a", Port: 5520, Servers: []string{"10.0.0.53:53"}, MinRefresh: "1s", MaxRefresh: "1m"}},
+	}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	cfg.Discovery = &DiscoveryConfig{Providers: []DiscoveryProviderConfig{
+		{Name: "a", Type: "dns"},
+		{Name: "b", Type: "dns", DNS: &DNSDiscoveryConfig{Type: "a", Servers: []string{"10.0.0.53"}}},
+		{Name: "c", Type: "dns", DNS: &DNSDiscoveryConfig{Name: "x", Type: "mx", MinRefresh: "2m", MaxRefresh: "1m"}},
+	}}
+	problems := Problems(cfg.Validate())
+	if len(problems) != 6 {
+		t.Fatalf("problems=%v", problems)
+	}
+}
+
+func TestValidateDiscoveryHTTP(t *testing.T) {
+	cfg := Default()
+	cfg.Discovery = &DiscoveryConfig{Providers: []DiscoveryProviderConfig{
+		{Name: "a", Type: "http", HTTP: &HTTPDiscoveryConfig{URL: "https://matchmaker.internal/backends"}},
+		{Name: "b", Type: "http", HTTP: &HTTPDiscoveryConfig{
+			URL: "http://matchmaker.internal/backends", Watch: "sse", Interval: "5s", Timeout: "2s", Jitter: "0s",
+			Auth: &HTTPDiscoveryAuthConfig{Header: "X-Api-Key", ValueEnv: "MATCHMAKER_KEY"},
+		}},
+	}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	cfg.Discovery = &DiscoveryConfig{Providers: []DiscoveryProviderConfig{
+		{Name: "a", Type: "http"},
+		{Name: "b", Type: "http", HTTP: &HTTPDiscoveryConfig{URL: "matchmaker/backends", Watch: "push", Interval: "0s", Jitter: "-1s"}},
+		{Name: "c", Type: "http", HTTP: &HTTPDiscoveryConfig{
+			URL:  "http://x",
+			Auth: &HTTPDiscoveryAuthConfig{Header: "Bad Header", ValueEnv: "A", ValueFile: "/b"},
+		}},
+	}}
+	problems := Problems(cfg.Validate())
+	if len(problems) != 7 {
+		t.Fatalf("problems=%v", problems)
+	}
+}
+
+func TestValidateDiscoveryGRPC(t *testing.T) {
+	cfg := Default()
+	cfg.Discovery = &DiscoveryConfig{Providers: []DiscoveryProviderConfig{
+		{Name: "a", Type: "grpc", GRPC: &GRPCDiscoveryConfig{Address: "127.0.0.1:7778", Params: map[string]string{"fleet": "eu"}, Backoff: "500ms", MaxBackoff: "10s"}},
+	}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	cfg.Discovery = &DiscoveryConfig{Providers: []DiscoveryProviderConfig{
+		{Name: "a", Type: "grpc"},
+		{Name: "b", Type: "grpc", GRPC: &GRPCDiscoveryConfig{Backoff: "-1s"}},
+		{Name: "c", Type: "grpc", GRPC: &GRPCDiscoveryConfig{Address: "x:1", Backoff: "1m", MaxBackoff: "1s"}},
+	}}
+	problems := Problems(cfg.Validate())
+	if len(problems) != 4 {
+		t.Fatalf("problems=%v", problems)
+	}
+}
+
+func TestValidateDiscoveryConsul(t *testing.T) {
+	cfg := Default()
+	cfg.Discovery = &DiscoveryConfig{Providers: []DiscoveryProviderConfig{
+		{Name: "a", Type: "consul", Consul: &ConsulDiscoveryConfig{Service: "lobby"}},
+		{Name: "b", Type: "consul", Consul: &ConsulDiscoveryConfig{
+			Address: "https://consul.internal:8501", Service: "lobby", Tags: []string{"hytale"},
+			Datacenter: "eu1", Namespace: "games", TokenFile: "/var/run/consul/token", Wait: "10m", RetryInterval: "1s",
+		}},
+	}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	cfg.Discovery = &DiscoveryConfig{Providers: []DiscoveryProviderConfig{
+		{Name: "a", Type: "consul"},
+		{Name: "b", Type: "consul", Consul: &ConsulDiscoveryConfig{Address: "consul:8500", Tags: []string{" "}}},
+		{Name: "c", Type: "consul", Consul: &ConsulDiscoveryConfig{Service: "x", TokenEnv: "A", TokenFile: "/b", Wait: "11m", RetryInterval: "0s"}},
+	}}
+	problems := Problems(cfg.Validate())
+	if len(problems) != 7 {
+		t.Fatalf("problems=%v", problems)
+	}
+}
+
+func TestValidateMetrics(t *testing.T) {
+	cfg := Default()
+	cfg.Metrics = &MetricsConfig{}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	cfg.Metrics = &MetricsConfig{Listen: ":9090", Path: "metrics"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	cfg.Metrics = &MetricsConfig{Listen: ":9090", Path: "/metrics"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
+
+func TestLoadRecordsSource(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	b := []byte("listen: ':5520'\nreload:\n  watch: true\n  interval: 5s\n")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Source() != path {
+		t.Fatalf("source=%q", cfg.Source())
+	}
+	if !cfg.Reload.Watch || cfg.Reload.Interval != "5s" {
+		t.Fatalf("reload=%#v", cfg.Reload)
+	}
+	if Default().Source() != "" {
+		t.Fatalf("expected empty source for default config")
+	}
+}
+
+func TestValidateReloadInterval(t *testing.T) {
+	cfg := Default()
+	cfg.Reload.Interval = "nope"
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected error")
+	}
+	cfg.Reload.Interval = "0s"
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestValidateAdmin(t *testing.T) {
+	cfg := Default()
+	cfg.Admin = &AdminConfig{Token: "t"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	cfg.Admin = &AdminConfig{Listen: "127.0.0.1:9091"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	cfg.Admin = &AdminConfig{Listen: "127.0.0.1:9091", Token: "t"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	cfg := Default()
+	cfg.Listen = ""
+	cfg.Reload.Interval = "nope"
+	cfg.Plugins = []PluginConfig{{Name: "a", Type: "x"}, {Name: "a", Type: "grpc"}}
+	cfg.Discovery = &DiscoveryConfig{Providers: []DiscoveryProviderConfig{{Name: "k", Type: "kubernetes"}}}
+
+	problems := Problems(cfg.Validate())
+	want := []string{
+		"listen must not be empty",
+		"plugins[0].type must be one of: grpc, wasm",
+		"plugins[1].name must be unique",
+		"plugins[1].grpc.address must not be empty",
+		"discovery.providers[0].kubernetes must be set",
+	}
+	if len(problems) != len(want)+1 {
+		t.Fatalf("problems=%v", problems)
+	}
+	joined := errors.Join(problems...).Error()
+	for _, w := range want {
+		if !strings.Contains(joined, w) {
+			t.Fatalf("missing %q in:\n%s", w, joined)
+		}
+	}
+	if Problems(nil) != nil {
+		t.Fatalf("expected no problems")
+	}
+}
+
+func TestParseDoesNotValidate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("listen: ''\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Parse(path)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if cfg.Source() != path || len(cfg.Messages.DisconnectLocales) == 0 {
+		t.Fatalf("cfg=%#v", cfg)
+	}
+	if _, err := Load(path); err == nil {
+		t.Fatalf("expected Load to fail")
+	}
+}
+
+func TestValidateHealthCheck(t *testing.T) {
+	cfg := Default()
+	cfg.HealthCheck = &HealthCheckConfig{}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	cfg.HealthCheck = &HealthCheckConfig{Interval: "nope", Timeout: "0s", UnhealthyThreshold: -1, ALPN: []string{"hytale/*"}}
+	problems := Problems(cfg.Validate())
+	if len(problems) != 4 {
+		t.Fatalf("problems=%v", problems)
+	}
+}
+
+func TestValidateOutlierDetection(t *testing.T) {
+	cfg := Default()
+	cfg.OutlierDetection = &OutlierDetectionConfig{Key: "client_ip", ReturnWindow: "20s", FailureRatio: 0.3}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	cfg.OutlierDetection = &OutlierDetectionConfig{Key: "name", Window: "x", FailureRatio: 2, MaxEjectedPercent: 101}
+	problems := Problems(cfg.Validate())
+	if len(problems) != 4 {
+		t.Fatalf("problems=%v", problems)
+	}
+}
+
+func TestValidateReferralAccounting(t *testing.T) {
+	cfg := Default()
+	cfg.ReferralAccounting = &ReferralAccountingConfig{Window: "15s"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	for _, w := range []string{"soon", "-1s"} {
+		cfg.ReferralAccounting = &ReferralAccountingConfig{Window: w}
+		if problems := Problems(cfg.Validate()); len(problems) != 1 {
+			t.Fatalf("window=%q problems=%v", w, problems)
+		}
+	}
+}
+
+func TestValidateQueue(t *testing.T) {
+	cfg := Default()
+	cfg.Queue = &QueueConfig{MaxLength: 100, MaxWait: "3m", RetryInterval: "1s", PriorityUUIDs: []string{"00000000-0000-0000-0000-000000000001"}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	cfg.Queue = &QueueConfig{MaxLength: -1, MaxWait: "x", RetryInterval: "0s", PriorityUUIDs: []string{" "}}
+	problems := Problems(cfg.Validate())
+	if len(problems) != 4 {
+		t.Fatalf("problems=%v", problems)
+	}
+}
+
+func TestValidateLimits(t *testing.T) {
+	cfg := Default()
+	cfg.Limits = &LimitsConfig{PerIP: &PerIPLimitConfig{Rate: 5, Burst: 10, IPv4Prefix: 24}, Global: &RateLimitConfig{Rate: 100}, MaxInFlight: 1000, Action: "close"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	cfg.Limits = &LimitsConfig{PerIP: &PerIPLimitConfig{IPv6Prefix: 129}, Global: &RateLimitConfig{Rate: 1, Burst: -1}, MaxInFlight: -1, Action: "drop"}
+	problems := Problems(cfg.Validate())
+	if len(problems) != 5 {
+		t.Fatalf("problems=%v", problems)
+	}
+}
+
+func TestValidateAccess(t *testing.T) {
+	cfg := Default()
+	cfg.Access = &access.Rules{Deny: []string{"192.0.2.0/24", "2001:db8::1"}}
+	cfg.Routing.Routes = []routing.Route{{
+		Match:  routing.Match{Hostname: "staging.example.com"},
+		Pool:   routing.Pool{Strategy: "round_robin", Backends: []routing.Backend{{Host: "a", Port: 1}}},
+		Access: &access.Rules{Allow: []string{"203.0.113.0/24"}},
+	}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	cfg.Access = &access.Rules{Allow: []string{"bad"}, DenyFiles: []string{""}}
+	cfg.Routing.Routes[0].Access = &access.Rules{Deny: []string{"10.0.0.0/40"}}
+	problems := Problems(cfg.Validate())
+	if len(problems) != 3 {
+		t.Fatalf("problems=%v", problems)
+	}
+}
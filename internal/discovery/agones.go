@@ -36,6 +36,7 @@ type agonesProvider struct {
 
 	rebuildMu sync.Mutex
 	snapshot  atomic.Value
+	updatedAt atomic.Int64
 
 	allocateMu   sync.Mutex
 	nextAllocate time.Time
@@ -111,7 +112,8 @@ func (p *agonesProvider) Start(ctx context.Context) error {
 
 func (p *agonesProvider) Resolve(ctx context.Context) ([]routing.Backend, error) {
 	mode := strings.ToLower(strings.TrimSpace(p.cfg.Mode))
-	if mode == "" || mode == "observe" {
+	// Dry runs never allocate; they see the observed GameServers instead.
+	if mode == "" || mode == "observe" || routing.IsDryRun(ctx) {
 		v := p.snapshot.Load()
 		if v == nil {
 			return nil, nil
@@ -127,6 +129,10 @@ func (p *agonesProvider) Resolve(ctx context.Context) ([]routing.Backend, error)
 	return p.allocate(ctx)
 }
 
+func (p *agonesProvider) Health() ProviderHealth {
+	return snapshotHealth(p.name, "agones", p.startErr, &p.snapshot, &p.updatedAt)
+}
+
 func (p *agonesProvider) rebuild() {
 	p.rebuildMu.Lock()
 	defer p.rebuildMu.Unlock()
@@ -180,6 +186,7 @@ func (p *agonesProvider) rebuild() {
 	}
 
 	p.snapshot.Store(out)
+	p.updatedAt.Store(time.Now().UnixNano())
 }
 
 func (p *agonesProvider) allocate(ctx context.Context) ([]routing.Backend, error) {
@@ -308,6 +315,7 @@ func toBackendFromGameServer(u *unstructured.Unstructured, cfg *config.AgonesDis
 	if v := u.GetAnnotations()["hyrouter/weight"]; v != "" {
 		meta["annotation.hyrouter/weight"] = v
 	}
+	copyHostnameLabel(meta, u.GetLabels())
 
 	counters, _, _ := unstructured.NestedMap(status, "counters")
 	for name, raw := range counters {
@@ -0,0 +1,327 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/routing"
+)
+
+const (
+	defaultConsulAddress       = "http://127.0.0.1:8500"
+	defaultConsulWait          = 5 * time.Minute
+	defaultConsulRetryInterval = 5 * time.Second
+	// consulQueryTimeout bounds queries without an index, which Consul answers right away.
+	consulQueryTimeout = 10 * time.Second
+)
+
+// consulProvider watches the healthy instances of a Consul service with blocking queries
+// against /v1/health/service. A failed query leaves the previous backends in place.
+type consulProvider struct {
+	name          string
+	service       string
+	endpoint      string
+	wait          time.Duration
+	retryInterval time.Duration
+	token         string
+	tokenFile     string
+	client        *http.Client
+	logger        *slog.Logger
+
+	// index is the X-Consul-Index of the last response. It is only touched by Start and
+	// the run goroutine it launches.
+	index uint64
+
+	snapshot  atomic.Value
+	updatedAt atomic.Int64
+
+	errMu    sync.Mutex
+	queryErr error
+
+	startOnce sync.Once
+}
+
+// consulServiceEntry is the subset of a /v1/health/service entry Hyrouter uses.
+type consulServiceEntry struct {
+	Node struct {
+		Node       string `json:"Node"`
+		Address    string `json:"Address"`
+		Datacenter string `json:"Datacenter"`
+	} `json:"Node"`
+	Service struct {
+		ID        string            `json:"ID"`
+		Service   string            `json:"Service"`
+		Tags      []string          `json:"Tags"`
+		Address   string            `json:"Address"`
+		Port      int               `json:"Port"`
+		Meta      map[string]string `json:"Meta"`
+		Namespace string            `json:"Namespace"`
+		Weights   struct {
+			Passing int `json:"Passing"`
+		} `json:"Weights"`
+	} `json:"Service"`
+	Checks []struct {
+		Status string `json:"Status"`
+	} `json:"Checks"`
+}
+
+func newConsulProvider(name string, cfg *config.ConsulDiscoveryConfig, logger *slog.Logger) (*consulProvider, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("discovery provider %q: consul config must be set", name)
+	}
+	if strings.TrimSpace(cfg.Service) == "" {
+		return nil, fmt.Errorf("discovery provider %q: consul.service must not be empty", name)
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	address := strings.TrimRight(strings.TrimSpace(cfg.Address), "/")
+	if address == "" {
+		address = defaultConsulAddress
+	}
+	q := url.Values{}
+	q.Set("passing", "true")
+	for _, tag := range cfg.Tags {
+		q.Add("tag", tag)
+	}
+	if cfg.Datacenter != "" {
+		q.Set("dc", cfg.Datacenter)
+	}
+	if cfg.Namespace != "" {
+		q.Set("ns", cfg.Namespace)
+	}
+	p := &consulProvider{
+		name:          name,
+		service:       cfg.Service,
+		endpoint:      address + "/v1/health/service/" + url.PathEscape(cfg.Service) + "?" + q.Encode(),
+		wait:          defaultConsulWait,
+		retryInterval: defaultConsulRetryInterval,
+		tokenFile:     cfg.TokenFile,
+		client:        &http.Client{},
+		logger:        logger,
+	}
+	for _, f := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{{"wait", cfg.Wait, &p.wait}, {"retry_interval", cfg.RetryInterval, &p.retryInterval}} {
+		if strings.TrimSpace(f.value) == "" {
+			continue
+		}
+		d, err := time.ParseDuration(f.value)
+		if err != nil {
+			return nil, fmt.Errorf("discovery provider %q: invalid consul.%s: %w", name, f.name, err)
+		}
+		*f.dst = d
+	}
+	switch {
+	case cfg.TokenFile != "":
+		if _, err := p.aclToken(); err != nil {
+			return nil, fmt.Errorf("discovery provider %q: %w", name, err)
+		}
+	case cfg.TokenEnv != "":
+		p.token = strings.TrimSpace(os.Getenv(cfg.TokenEnv))
+		if p.token == "" {
+			return nil, fmt.Errorf("discovery provider %q: environment variable %s for consul.token_env is not set", name, cfg.TokenEnv)
+		}
+	}
+	p.snapshot.Store([]routing.Backend(nil))
+	return p, nil
+}
+
+// Start loads the current instances with a non-blocking query and then follows changes with
+// blocking queries until ctx is done. A failed first query is not fatal: the provider reports
+// "not synced" until a query succeeds.
+func (p *consulProvider) Start(ctx context.Context) error {
+	p.startOnce.Do(func() {
+		err := p.record(p.query(ctx))
+		go p.run(ctx, err)
+	})
+	return nil
+}
+
+func (p *consulProvider) Resolve(_ context.Context) ([]routing.Backend, error) {
+	bs, _ := p.snapshot.Load().([]routing.Backend)
+	out := make([]routing.Backend, len(bs))
+	copy(out, bs)
+	return out, nil
+}
+
+func (p *consulProvider) Health() ProviderHealth {
+	h := snapshotHealth(p.name, "consul", nil, &p.snapshot, &p.updatedAt)
+	p.errMu.Lock()
+	defer p.errMu.Unlock()
+	switch {
+	case p.queryErr == nil:
+	case h.Healthy:
+		h.Healthy = false
+		h.Error = "query failed, serving previous backends: " + p.queryErr.Error()
+	default:
+		h.Error = "not synced: " + p.queryErr.Error()
+	}
+	return h
+}
+
+// run issues blocking queries back to back. Consul answers each one as soon as the service
+// changes or wait expires. After an error, or without an index to block on, the next query
+// waits retry_interval so a misbehaving server is not hammered.
+func (p *consulProvider) run(ctx context.Context, err error) {
+	for {
+		if err != nil || p.index == 0 {
+			t := time.NewTimer(p.retryInterval)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return
+			case <-t.C:
+			}
+		}
+		err = p.query(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		p.record(err)
+	}
+}
+
+func (p *consulProvider) record(err error) error {
+	p.errMu.Lock()
+	p.queryErr = err
+	p.errMu.Unlock()
+	if err != nil {
+		p.logger.Warn("discovery consul query failed; keeping previous backends", "provider", p.name, "service", p.service, "error", err)
+	}
+	return err
+}
+
+func (p *consulProvider) query(ctx context.Context) error {
+	endpoint := p.endpoint
+	timeout := consulQueryTimeout
+	if p.index > 0 {
+		endpoint += "&index=" + strconv.FormatUint(p.index, 10) + "&wait=" + strconv.FormatInt(p.wait.Milliseconds(), 10) + "ms"
+		// Consul adds up to wait/16 of jitter to blocking queries.
+		timeout += p.wait + p.wait/16
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	token, err := p.aclToken()
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("X-Consul-Token", token)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var entries []consulServiceEntry
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxHTTPBackendsBody)).Decode(&entries); err != nil {
+		return fmt.Errorf("decode health entries: %w", err)
+	}
+
+	// Consul's blocking query rules: an index that goes backwards means the state was reset,
+	// so start over with a non-blocking query.
+	index, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	if index < p.index {
+		index = 0
+	}
+	p.index = index
+
+	bs := consulBackends(entries)
+	p.snapshot.Store(bs)
+	p.updatedAt.Store(time.Now().UnixNano())
+	p.logger.Debug("discovery consul updated", "provider", p.name, "service", p.service, "backends", len(bs), "index", index)
+	return nil
+}
+
+// aclToken returns the ACL token. Files are re-read on every query so rotated tokens are picked up.
+func (p *consulProvider) aclToken() (string, error) {
+	if p.tokenFile == "" {
+		return p.token, nil
+	}
+	b, err := os.ReadFile(p.tokenFile)
+	if err != nil {
+		return "", fmt.Errorf("read consul.token_file: %w", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// consulBackends maps passing instances to backends. Tags become labels (key=value tags split
+// at the first "=", plain tags are set to "true"), service meta is copied into labels on top,
+// and consul.* carries where the instance is registered.
+func consulBackends(entries []consulServiceEntry) []routing.Backend {
+	out := make([]routing.Backend, 0, len(entries))
+	for _, e := range entries {
+		if !consulPassing(e) {
+			continue
+		}
+		host := e.Service.Address
+		if host == "" {
+			host = e.Node.Address
+		}
+		if host == "" || e.Service.Port < 1 || e.Service.Port > 65535 {
+			continue
+		}
+		meta := map[string]string{
+			"consul.service": e.Service.Service,
+			"consul.id":      e.Service.ID,
+			"consul.node":    e.Node.Node,
+		}
+		if e.Node.Datacenter != "" {
+			meta["consul.datacenter"] = e.Node.Datacenter
+		}
+		if e.Service.Namespace != "" {
+			meta["consul.namespace"] = e.Service.Namespace
+		}
+		if len(e.Service.Tags) > 0 {
+			meta["consul.tags"] = strings.Join(e.Service.Tags, ",")
+		}
+		for _, tag := range e.Service.Tags {
+			if k, v, ok := strings.Cut(tag, "="); ok {
+				meta["label."+k] = v
+			} else {
+				meta["label."+tag] = "true"
+			}
+		}
+		for k, v := range e.Service.Meta {
+			meta["label."+k] = v
+		}
+		b := routing.Backend{Host: host, Port: e.Service.Port, Weight: e.Service.Weights.Passing, Meta: meta}
+		applyWeight(&b)
+		out = append(out, b)
+	}
+	sortBackends(out)
+	return out
+}
+
+// consulPassing double-checks ?passing=true, in case a proxy or an older agent ignored it.
+func consulPassing(e consulServiceEntry) bool {
+	for _, c := range e.Checks {
+		if c.Status != "passing" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,167 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/routing"
+)
+
+// fakeConsul serves /v1/health/service like a Consul agent, including blocking queries:
+// a request with ?index= equal to the current index waits until publish is called.
+type fakeConsul struct {
+	mu      sync.Mutex
+	index   uint64
+	body    string
+	status  int
+	changed chan struct{}
+	queries []*http.Request
+}
+
+func newFakeConsul(body string) *fakeConsul {
+	return &fakeConsul{index: 7, body: body, status: http.StatusOK, changed: make(chan struct{})}
+}
+
+func (f *fakeConsul) publish(status int, body string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.index++
+	f.status, f.body = status, body
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.queries = append(f.queries, r)
+	changed := f.changed
+	blocking := r.URL.Query().Get("index") == strconv.FormatUint(f.index, 10)
+	f.mu.Unlock()
+	if blocking {
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("X-Consul-Token") != "acl-token" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(f.index, 10))
+	w.WriteHeader(f.status)
+	_, _ = io.WriteString(w, f.body)
+}
+
+func (f *fakeConsul) firstQuery() *http.Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.queries[0]
+}
+
+func consulEntry(node, addr string, port int, tags string, checks string) string {
+	return fmt.Sprintf(`{"Node":{"Node":%q,"Address":"10.1.0.1","Datacenter":"eu1"},`+
+		`"Service":{"ID":"lobby-%s","Service":"lobby","Tags":[%s],"Address":%q,"Port":%d,"Meta":{"mode":"lobby","region":"eu"},"Namespace":"games","Weights":{"Passing":2}},`+
+		`"Checks":[%s]}`, node, node, tags, addr, port, checks)
+}
+
+func TestConsulProvider(t *testing.T) {
+	first := "[" + consulEntry("n1", "10.0.0.1", 5520, `"hytale","version=1.2"`, `{"Status":"passing"}`) + "]"
+	f := newFakeConsul(first)
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	t.Setenv("HYROUTER_TEST_CONSUL_TOKEN", "acl-token")
+	p, err := newConsulProvider("consul", &config.ConsulDiscoveryConfig{
+		Address: srv.URL, Service: "lobby", Tags: []string{"hytale", "version=1.2"},
+		Datacenter: "eu1", Namespace: "games", TokenEnv: "HYROUTER_TEST_CONSUL_TOKEN",
+		Wait: "1s", RetryInterval: "5ms",
+	}, slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{})))
+	if err != nil {
+		t.Fatalf("newConsulProvider: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := p.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	q := f.firstQuery().URL
+	if q.Path != "/v1/health/service/lobby" || q.Query().Get("passing") != "true" || q.Query().Get("dc") != "eu1" ||
+		q.Query().Get("ns") != "games" || !slices.Equal(q.Query()["tag"], []string{"hytale", "version=1.2"}) || q.Query().Has("index") {
+		t.Fatalf("query=%s", q)
+	}
+
+	bs, _ := p.Resolve(ctx)
+	if len(bs) != 1 {
+		t.Fatalf("backends=%#v", bs)
+	}
+	b := bs[0]
+	if b.Host != "10.0.0.1" || b.Port != 5520 || b.Weight != 2 {
+		t.Fatalf("backend=%#v", b)
+	}
+	for k, want := range map[string]string{
+		"label.mode":        "lobby",
+		"label.hytale":      "true",
+		"label.version":     "1.2",
+		"consul.service":    "lobby",
+		"consul.id":         "lobby-n1",
+		"consul.node":       "n1",
+		"consul.datacenter": "eu1",
+		"consul.namespace":  "games",
+		"consul.tags":       "hytale,version=1.2",
+	} {
+		if b.Meta[k] != want {
+			t.Fatalf("meta[%s]=%q, want %q (meta=%#v)", k, b.Meta[k], want, b.Meta)
+		}
+	}
+
+	// The next query blocks on the index and returns as soon as the service changes.
+	// Instances with a failing check and instances without a service address are handled.
+	f.publish(http.StatusOK, "["+
+		consulEntry("n1", "10.0.0.1", 5520, `"hytale"`, `{"Status":"passing"}`)+","+
+		consulEntry("n2", "", 5521, `"hytale"`, `{"Status":"passing"},{"Status":"passing"}`)+","+
+		consulEntry("n3", "10.0.0.3", 5522, `"hytale"`, `{"Status":"critical"}`)+"]")
+	waitForBackends(t, p, func(bs []routing.Backend) bool { return len(bs) == 2 })
+	if bs, _ := p.Resolve(ctx); bs[0].Host != "10.0.0.1" || bs[1].Host != "10.1.0.1" || bs[1].Port != 5521 {
+		t.Fatalf("backends=%#v", bs)
+	}
+
+	// Errors keep the previous backends.
+	f.publish(http.StatusInternalServerError, "rpc error")
+	waitFor(t, func() bool { return !p.Health().Healthy })
+	if bs, _ := p.Resolve(ctx); len(bs) != 2 {
+		t.Fatalf("backends=%#v", bs)
+	}
+	if h := p.Health(); h.Type != "consul" || h.Backends != 2 {
+		t.Fatalf("health=%#v", h)
+	}
+
+	f.publish(http.StatusOK, "[]")
+	waitForBackends(t, p, func(bs []routing.Backend) bool { return len(bs) == 0 })
+	waitFor(t, func() bool { return p.Health().Healthy })
+}
+
+func TestNewConsulProviderErrors(t *testing.T) {
+	for name, cfg := range map[string]config.ConsulDiscoveryConfig{
+		"empty service": {},
+		"bad wait":      {Service: "lobby", Wait: "forever"},
+		"unset env":     {Service: "lobby", TokenEnv: "HYROUTER_TEST_UNSET"},
+		"missing file":  {Service: "lobby", TokenFile: "/nonexistent/hyrouter-token"},
+	} {
+		if _, err := newConsulProvider("consul", &cfg, nil); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
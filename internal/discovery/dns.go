@@ -0,0 +1,298 @@
+package discovery
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/routing"
+	"github.com/miekg/dns"
+)
+
+const (
+	defaultDNSMinRefresh = 5 * time.Second
+	defaultDNSMaxRefresh = 5 * time.Minute
+	dnsResolvConf        = "/etc/resolv.conf"
+)
+
+// dnsProvider resolves backends from SRV or A/AAAA records and re-resolves them when the records expire.
+// A failed lookup leaves the previous backends in place.
+type dnsProvider struct {
+	name       string
+	record     string
+	qname      string
+	srv        bool
+	port       int
+	servers    []string
+	minRefresh time.Duration
+	maxRefresh time.Duration
+	logger     *slog.Logger
+
+	udp *dns.Client
+	tcp *dns.Client
+
+	snapshot  atomic.Value
+	updatedAt atomic.Int64
+
+	errMu      sync.Mutex
+	resolveErr error
+
+	startOnce sync.Once
+}
+
+func newDNSProvider(name string, cfg *config.DNSDiscoveryConfig, logger *slog.Logger) (*dnsProvider, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("discovery provider %q: dns config must be set", name)
+	}
+	record := strings.TrimSuffix(strings.TrimSpace(cfg.Name), ".")
+	if record == "" {
+		return nil, fmt.Errorf("discovery provider %q: dns.name must not be empty", name)
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	p := &dnsProvider{
+		name:       name,
+		record:     record,
+		qname:      dns.Fqdn(record),
+		port:       cfg.Port,
+		servers:    cfg.Servers,
+		minRefresh: defaultDNSMinRefresh,
+		maxRefresh: defaultDNSMaxRefresh,
+		logger:     logger,
+		udp:        &dns.Client{Net: "udp", Timeout: 2 * time.Second},
+		tcp:        &dns.Client{Net: "tcp", Timeout: 2 * time.Second},
+	}
+	switch strings.ToLower(strings.TrimSpace(cfg.Type)) {
+	case "", "srv":
+		p.srv = true
+	case "a":
+		if p.port < 1 || p.port > 65535 {
+			return nil, fmt.Errorf("discovery provider %q: dns.port must be between 1 and 65535", name)
+		}
+	default:
+		return nil, fmt.Errorf("discovery provider %q: dns.type must be one of: srv, a", name)
+	}
+	for _, f := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{{"min_refresh", cfg.MinRefresh, &p.minRefresh}, {"max_refresh", cfg.MaxRefresh, &p.maxRefresh}} {
+		if f.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(f.value)
+		if err != nil {
+			return nil, fmt.Errorf("discovery provider %q: invalid dns.%s: %w", name, f.name, err)
+		}
+		*f.dst = d
+	}
+	if p.minRefresh > p.maxRefresh {
+		return nil, fmt.Errorf("discovery provider %q: dns.min_refresh must not be greater than max_refresh", name)
+	}
+	if len(p.servers) == 0 {
+		rc, err := dns.ClientConfigFromFile(dnsResolvConf)
+		if err != nil {
+			return nil, fmt.Errorf("discovery provider %q: dns.servers not set and %s unreadable: %w", name, dnsResolvConf, err)
+		}
+		for _, s := range rc.Servers {
+			p.servers = append(p.servers, net.JoinHostPort(s, rc.Port))
+		}
+		if len(p.servers) == 0 {
+			return nil, fmt.Errorf("discovery provider %q: dns.servers not set and %s lists no nameservers", name, dnsResolvConf)
+		}
+	}
+	p.snapshot.Store([]routing.Backend(nil))
+	return p, nil
+}
+
+// Start resolves the record once and keeps re-resolving it until ctx is done.
+// A failed first lookup is not fatal: the provider reports "not synced" until a lookup succeeds.
+func (p *dnsProvider) Start(ctx context.Context) error {
+	p.startOnce.Do(func() {
+		next := p.refresh(ctx)
+		go p.run(ctx, next)
+	})
+	return nil
+}
+
+func (p *dnsProvider) Resolve(_ context.Context) ([]routing.Backend, error) {
+	bs, _ := p.snapshot.Load().([]routing.Backend)
+	out := make([]routing.Backend, len(bs))
+	copy(out, bs)
+	return out, nil
+}
+
+func (p *dnsProvider) Health() ProviderHealth {
+	h := snapshotHealth(p.name, "dns", nil, &p.snapshot, &p.updatedAt)
+	p.errMu.Lock()
+	defer p.errMu.Unlock()
+	switch {
+	case p.resolveErr == nil:
+	case h.Healthy:
+		h.Healthy = false
+		h.Error = "resolve failed, serving previous backends: " + p.resolveErr.Error()
+	default:
+		h.Error = "not synced: " + p.resolveErr.Error()
+	}
+	return h
+}
+
+func (p *dnsProvider) run(ctx context.Context, next time.Duration) {
+	t := time.NewTimer(next)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			t.Reset(p.refresh(ctx))
+		}
+	}
+}
+
+// refresh resolves the record once and returns how long to wait before the next lookup:
+// the lowest record TTL clamped to [min_refresh, max_refresh], or min_refresh after a failure.
+func (p *dnsProvider) refresh(ctx context.Context) time.Duration {
+	var (
+		bs  []routing.Backend
+		ttl uint32
+		err error
+	)
+	if p.srv {
+		bs, ttl, err = p.lookupSRV(ctx)
+	} else {
+		bs, ttl, err = p.lookupAddrs(ctx)
+	}
+	p.errMu.Lock()
+	p.resolveErr = err
+	p.errMu.Unlock()
+	if err != nil {
+		p.logger.Warn("discovery dns lookup failed; keeping previous backends", "provider", p.name, "name", p.record, "error", err)
+		return p.minRefresh
+	}
+	p.snapshot.Store(bs)
+	p.updatedAt.Store(time.Now().UnixNano())
+	next := p.minRefresh
+	if len(bs) > 0 {
+		next = min(max(time.Duration(ttl)*time.Second, p.minRefresh), p.maxRefresh)
+	}
+	p.logger.Debug("discovery dns resolved", "provider", p.name, "name", p.record, "backends", len(bs), "next_refresh", next)
+	return next
+}
+
+// lookupSRV maps the highest-priority (lowest value) SRV records to backends, as clients
+// are expected to use the other priorities only when that group is unreachable (RFC 2782).
+func (p *dnsProvider) lookupSRV(ctx context.Context) ([]routing.Backend, uint32, error) {
+	msg, err := p.exchange(ctx, dns.TypeSRV)
+	if err != nil {
+		return nil, 0, err
+	}
+	var records []*dns.SRV
+	for _, rr := range msg.Answer {
+		if srv, ok := rr.(*dns.SRV); ok && srv.Target != "." {
+			records = append(records, srv)
+		}
+	}
+	if len(records) == 0 {
+		return nil, 0, nil
+	}
+	best := slices.MinFunc(records, func(a, b *dns.SRV) int { return cmp.Compare(a.Priority, b.Priority) }).Priority
+	ttl := uint32(math.MaxUint32)
+	var out []routing.Backend
+	for _, srv := range records {
+		if srv.Priority != best {
+			continue
+		}
+		ttl = min(ttl, srv.Hdr.Ttl)
+		target := strings.TrimSuffix(srv.Target, ".")
+		out = append(out, routing.Backend{
+			Host:   target,
+			Port:   int(srv.Port),
+			Weight: int(srv.Weight),
+			Meta: map[string]string{
+				"dns.name":     p.record,
+				"dns.target":   target,
+				"dns.priority": strconv.Itoa(int(srv.Priority)),
+			},
+		})
+	}
+	sortBackends(out)
+	return out, ttl, nil
+}
+
+// lookupAddrs maps A and AAAA records to backends on the configured port.
+func (p *dnsProvider) lookupAddrs(ctx context.Context) ([]routing.Backend, uint32, error) {
+	ttl := uint32(math.MaxUint32)
+	var out []routing.Backend
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		msg, err := p.exchange(ctx, qtype)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, rr := range msg.Answer {
+			var ip net.IP
+			switch r := rr.(type) {
+			case *dns.A:
+				ip = r.A
+			case *dns.AAAA:
+				ip = r.AAAA
+			default:
+				continue
+			}
+			ttl = min(ttl, rr.Header().Ttl)
+			out = append(out, routing.Backend{
+				Host: ip.String(),
+				Port: p.port,
+				Meta: map[string]string{"dns.name": p.record},
+			})
+		}
+	}
+	sortBackends(out)
+	return out, ttl, nil
+}
+
+// exchange sends the query to each server in turn and returns the first usable answer.
+// NXDOMAIN is returned as an error right away; other failures move on to the next server.
+func (p *dnsProvider) exchange(ctx context.Context, qtype uint16) (*dns.Msg, error) {
+	q := new(dns.Msg)
+	q.SetQuestion(p.qname, qtype)
+	var errs []error
+	for _, server := range p.servers {
+		msg, _, err := p.udp.ExchangeContext(ctx, q, server)
+		if err == nil && msg.Truncated {
+			msg, _, err = p.tcp.ExchangeContext(ctx, q, server)
+		}
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("%s: %w", server, err))
+		case msg.Rcode == dns.RcodeNameError:
+			return nil, fmt.Errorf("%s %s: NXDOMAIN", dns.TypeToString[qtype], p.record)
+		case msg.Rcode != dns.RcodeSuccess:
+			errs = append(errs, fmt.Errorf("%s: %s", server, dns.RcodeToString[msg.Rcode]))
+		default:
+			return msg, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, fmt.Errorf("%s %s: %w", dns.TypeToString[qtype], p.record, errors.Join(errs...))
+}
+
+func sortBackends(bs []routing.Backend) {
+	slices.SortFunc(bs, func(a, b routing.Backend) int {
+		return cmp.Or(cmp.Compare(a.Host, b.Host), cmp.Compare(a.Port, b.Port))
+	})
+}
@@ -0,0 +1,199 @@
+package discovery
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/miekg/dns"
+)
+
+// fakeDNS is a local UDP name server whose answers can be swapped between lookups.
+type fakeDNS struct {
+	mu      sync.Mutex
+	records []dns.RR
+	rcode   int
+}
+
+func (f *fakeDNS) set(rcode int, records ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rcode = rcode
+	f.records = nil
+	for _, s := range records {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			panic(err)
+		}
+		f.records = append(f.records, rr)
+	}
+}
+
+func (f *fakeDNS) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m := new(dns.Msg)
+	m.SetRcode(r, f.rcode)
+	for _, rr := range f.records {
+		if rr.Header().Rrtype == r.Question[0].Qtype && rr.Header().Name == r.Question[0].Name {
+			m.Answer = append(m.Answer, rr)
+		}
+	}
+	_ = w.WriteMsg(m)
+}
+
+func startFakeDNS(t *testing.T) (*fakeDNS, string) {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	f := &fakeDNS{}
+	started := make(chan struct{})
+	srv := &dns.Server{PacketConn: pc, Handler: f, NotifyStartedFunc: func() { close(started) }}
+	go func() { _ = srv.ActivateAndServe() }()
+	<-started
+	t.Cleanup(func() { _ = srv.Shutdown() })
+	return f, pc.LocalAddr().String()
+}
+
+func newTestDNSProvider(t *testing.T, cfg config.DNSDiscoveryConfig) *dnsProvider {
+	t.Helper()
+	p, err := newDNSProvider("dns", &cfg, slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{})))
+	if err != nil {
+		t.Fatalf("newDNSProvider: %v", err)
+	}
+	return p
+}
+
+func TestDNSProviderSRV(t *testing.T) {
+	f, addr := startFakeDNS(t)
+	f.set(dns.RcodeSuccess,
+		"_hytale._udp.example.test. 30 IN SRV 10 5 5520 b.example.test.",
+		"_hytale._udp.example.test. 60 IN SRV 10 0 5521 a.example.test.",
+		"_hytale._udp.example.test. 1 IN SRV 20 1 5522 standby.example.test.",
+	)
+	p := newTestDNSProvider(t, config.DNSDiscoveryConfig{Name: "_hytale._udp.example.test", Servers: []string{addr}})
+
+	ctx := context.Background()
+	if next := p.refresh(ctx); next != 30*time.Second {
+		t.Fatalf("next=%v", next)
+	}
+	bs, _ := p.Resolve(ctx)
+	if len(bs) != 2 {
+		t.Fatalf("backends=%#v", bs)
+	}
+	if bs[0].Host != "a.example.test" || bs[0].Port != 5521 || bs[0].Weight != 0 {
+		t.Fatalf("backend[0]=%#v", bs[0])
+	}
+	if bs[1].Host != "b.example.test" || bs[1].Port != 5520 || bs[1].Weight != 5 {
+		t.Fatalf("backend[1]=%#v", bs[1])
+	}
+	if m := bs[1].Meta; m["dns.name"] != "_hytale._udp.example.test" || m["dns.target"] != "b.example.test" || m["dns.priority"] != "10" {
+		t.Fatalf("meta=%#v", m)
+	}
+	if h := p.Health(); !h.Healthy || h.Type != "dns" || h.Backends != 2 {
+		t.Fatalf("health=%#v", h)
+	}
+
+	// A resolver outage keeps the previous backends and retries after min_refresh.
+	f.set(dns.RcodeServerFailure)
+	if next := p.refresh(ctx); next != defaultDNSMinRefresh {
+		t.Fatalf("next=%v", next)
+	}
+	if bs, _ := p.Resolve(ctx); len(bs) != 2 {
+		t.Fatalf("backends=%#v", bs)
+	}
+	if h := p.Health(); h.Healthy || h.Backends != 2 || h.Error == "" {
+		t.Fatalf("health=%#v", h)
+	}
+
+	// An empty answer is a valid, empty backend list.
+	f.set(dns.RcodeSuccess)
+	p.refresh(ctx)
+	if bs, _ := p.Resolve(ctx); len(bs) != 0 {
+		t.Fatalf("backends=%#v", bs)
+	}
+	if h := p.Health(); !h.Healthy {
+		t.Fatalf("health=%#v", h)
+	}
+}
+
+func TestDNSProviderAddrs(t *testing.T) {
+	f, addr := startFakeDNS(t)
+	f.set(dns.RcodeSuccess,
+		"play.example.test. 1 IN A 10.0.0.2",
+		"play.example.test. 1 IN A 10.0.0.1",
+		"play.example.test. 9000 IN AAAA 2001:db8::1",
+	)
+	p := newTestDNSProvider(t, config.DNSDiscoveryConfig{
+		Name: "play.example.test", Type: "a", Port: 5520, Servers: []string{addr},
+		MinRefresh: "2s", MaxRefresh: "1m",
+	})
+
+	ctx := context.Background()
+	if next := p.refresh(ctx); next != 2*time.Second {
+		t.Fatalf("next=%v (want min_refresh)", next)
+	}
+	bs, _ := p.Resolve(ctx)
+	if len(bs) != 3 || bs[0].Host != "10.0.0.1" || bs[1].Host != "10.0.0.2" || bs[2].Host != "2001:db8::1" {
+		t.Fatalf("backends=%#v", bs)
+	}
+	for _, b := range bs {
+		if b.Port != 5520 || b.Meta["dns.name"] != "play.example.test" {
+			t.Fatalf("backend=%#v", b)
+		}
+	}
+
+	f.set(dns.RcodeSuccess, "play.example.test. 9000 IN A 10.0.0.1")
+	if next := p.refresh(ctx); next != time.Minute {
+		t.Fatalf("next=%v (want max_refresh)", next)
+	}
+
+	// NXDOMAIN is treated as a lookup failure, not as an empty list.
+	f.set(dns.RcodeNameError)
+	p.refresh(ctx)
+	if bs, _ := p.Resolve(ctx); len(bs) != 1 {
+		t.Fatalf("backends=%#v", bs)
+	}
+}
+
+func TestDNSProviderStartUnreachable(t *testing.T) {
+	// Nothing listens on this socket once it is closed, so every lookup fails.
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := pc.LocalAddr().String()
+	_ = pc.Close()
+
+	p := newTestDNSProvider(t, config.DNSDiscoveryConfig{Name: "play.example.test", Type: "a", Port: 5520, Servers: []string{addr}})
+	p.udp.Timeout = 100 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := p.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if h := p.Health(); h.Healthy || h.Error == "" || h.LastUpdate != nil {
+		t.Fatalf("health=%#v", h)
+	}
+}
+
+func TestNewDNSProviderErrors(t *testing.T) {
+	for name, cfg := range map[string]config.DNSDiscoveryConfig{
+		"empty name":     {Servers: []string{"127.0.0.1:53"}},
+		"a without port": {Name: "x", Type: "a", Servers: []string{"127.0.0.1:53"}},
+		"bad type":       {Name: "x", Type: "mx", Servers: []string{"127.0.0.1:53"}},
+		"bad refresh":    {Name: "x", MinRefresh: "soon", Servers: []string{"127.0.0.1:53"}},
+		"min over max":   {Name: "x", MinRefresh: "10m", MaxRefresh: "1m", Servers: []string{"127.0.0.1:53"}},
+	} {
+		if _, err := newDNSProvider("dns", &cfg, nil); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/filewatch"
+	"github.com/hybrowse/hyrouter/internal/routing"
+	"gopkg.in/yaml.v3"
+)
+
+// fileProvider reads backends from a YAML or JSON file and re-reads it whenever it changes.
+// A file that cannot be read or parsed leaves the previous backends in place.
+type fileProvider struct {
+	name     string
+	path     string
+	interval time.Duration
+	logger   *slog.Logger
+
+	snapshot  atomic.Value
+	updatedAt atomic.Int64
+
+	errMu     sync.Mutex
+	reloadErr error
+
+	startOnce sync.Once
+	startErr  error
+}
+
+type backendsFile struct {
+	Backends []routing.Backend `json:"backends" yaml:"backends"`
+}
+
+func newFileProvider(name string, cfg *config.FileDiscoveryConfig, logger *slog.Logger) (*fileProvider, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("discovery provider %q: file config must be set", name)
+	}
+	if strings.TrimSpace(cfg.Path) == "" {
+		return nil, fmt.Errorf("discovery provider %q: file.path must not be empty", name)
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	p := &fileProvider{name: name, path: cfg.Path, logger: logger}
+	if cfg.Interval != "" {
+		d, err := time.ParseDuration(cfg.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("discovery provider %q: invalid file.interval: %w", name, err)
+		}
+		p.interval = d
+	}
+	p.snapshot.Store([]routing.Backend(nil))
+	return p, nil
+}
+
+// Start reads the file once and then watches it until ctx is done. The first read must succeed.
+func (p *fileProvider) Start(ctx context.Context) error {
+	p.startOnce.Do(func() {
+		bs, err := readBackendsFile(p.path)
+		if err != nil {
+			p.startErr = fmt.Errorf("discovery provider %q: %w", p.name, err)
+			return
+		}
+		p.store(bs)
+		go filewatch.Poll(ctx, p.path, p.interval, p.reload)
+	})
+	return p.startErr
+}
+
+func (p *fileProvider) Resolve(_ context.Context) ([]routing.Backend, error) {
+	bs, _ := p.snapshot.Load().([]routing.Backend)
+	out := make([]routing.Backend, len(bs))
+	copy(out, bs)
+	return out, nil
+}
+
+func (p *fileProvider) Health() ProviderHealth {
+	h := snapshotHealth(p.name, "file", p.startErr, &p.snapshot, &p.updatedAt)
+	p.errMu.Lock()
+	defer p.errMu.Unlock()
+	if h.Healthy && p.reloadErr != nil {
+		h.Healthy = false
+		h.Error = "reload failed, serving previous backends: " + p.reloadErr.Error()
+	}
+	return h
+}
+
+func (p *fileProvider) reload() {
+	bs, err := readBackendsFile(p.path)
+	p.errMu.Lock()
+	p.reloadErr = err
+	p.errMu.Unlock()
+	if err != nil {
+		p.logger.Warn("discovery file reload failed; keeping previous backends", "provider", p.name, "path", p.path, "error", err)
+		return
+	}
+	p.store(bs)
+	p.logger.Info("discovery file reloaded", "provider", p.name, "path", p.path, "backends", len(bs))
+}
+
+func (p *fileProvider) store(bs []routing.Backend) {
+	p.snapshot.Store(bs)
+	p.updatedAt.Store(time.Now().UnixNano())
+}
+
+// readBackendsFile parses a file of the form {"backends": [{"host", "port", "weight", "meta"}]}.
+// Files ending in .json are read as JSON, everything else as YAML.
+func readBackendsFile(path string) ([]routing.Backend, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read backends file: %w", err)
+	}
+	// An empty file is usually a write in progress, not an intentionally empty list.
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil, fmt.Errorf("%s: file is empty", path)
+	}
+	var doc backendsFile
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		err = json.Unmarshal(b, &doc)
+	} else {
+		err = yaml.Unmarshal(b, &doc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := checkBackends(doc.Backends); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return doc.Backends, nil
+}
+
+// checkBackends rejects backends that could never be referred to.
+func checkBackends(bs []routing.Backend) error {
+	for i, be := range bs {
+		switch {
+		case strings.TrimSpace(be.Host) == "":
+			return fmt.Errorf("backends[%d].host must not be empty", i)
+		case be.Port < 1 || be.Port > 65535:
+			return fmt.Errorf("backends[%d].port must be between 1 and 65535", i)
+		case be.Weight < 0:
+			return fmt.Errorf("backends[%d].weight must be >= 0", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package discovery
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+)
+
+func TestFileProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backends.yaml")
+	write := func(s string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(s), 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	write("backends:\n  - host: 10.0.0.1\n    port: 5520\n    weight: 2\n    meta:\n      label.region: eu\n")
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	m, err := New(&config.DiscoveryConfig{Providers: []config.DiscoveryProviderConfig{
+		{Name: "static", Type: "file", File: &config.FileDiscoveryConfig{Path: path, Interval: "5ms"}},
+	}}, logger)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := m.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	bs, ok, err := m.Resolve(ctx, "static")
+	if !ok || err != nil || len(bs) != 1 || bs[0].Host != "10.0.0.1" || bs[0].Weight != 2 || bs[0].Meta["label.region"] != "eu" {
+		t.Fatalf("backends=%#v ok=%v err=%v", bs, ok, err)
+	}
+
+	waitFor := func(cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	// Give the watcher time to record the initial file state.
+	time.Sleep(50 * time.Millisecond)
+
+	// Invalid content keeps the previous snapshot and marks the provider unhealthy.
+	write("backends:\n  - host: 10.0.0.2\n    port: 0\n")
+	waitFor(func() bool { return !m.Health()[0].Healthy })
+	if bs, _, _ := m.Resolve(ctx, "static"); len(bs) != 1 || bs[0].Host != "10.0.0.1" {
+		t.Fatalf("backends=%#v", bs)
+	}
+
+	write("backends:\n  - host: 10.0.0.2\n    port: 5520\n  - host: 10.0.0.3\n    port: 5521\n")
+	waitFor(func() bool { bs, _, _ := m.Resolve(ctx, "static"); return len(bs) == 2 })
+	if h := m.Health()[0]; !h.Healthy || h.Type != "file" || h.Backends != 2 {
+		t.Fatalf("health=%#v", h)
+	}
+}
+
+func TestReadBackendsFile(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "backends.json")
+	if err := os.WriteFile(jsonPath, []byte(`{"backends":[{"host":"a","port":1}]}`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if bs, err := readBackendsFile(jsonPath); err != nil || len(bs) != 1 || bs[0].Host != "a" {
+		t.Fatalf("backends=%#v err=%v", bs, err)
+	}
+
+	for name, content := range map[string]string{
+		"empty.yaml":   " \n",
+		"host.yaml":    "backends:\n  - port: 1\n",
+		"weight.yaml":  "backends:\n  - host: a\n    port: 1\n    weight: -1\n",
+		"invalid.json": "{",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if _, err := readBackendsFile(path); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+	if _, err := readBackendsFile(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Fatalf("expected error for missing file")
+	}
+}
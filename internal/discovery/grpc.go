@@ -0,0 +1,235 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/routing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	defaultGRPCBackoff    = time.Second
+	defaultGRPCMaxBackoff = 30 * time.Second
+	// grpcStartTimeout bounds how long Start waits for the first snapshot.
+	grpcStartTimeout = 10 * time.Second
+)
+
+var errWatchEnded = errors.New("watch stream ended")
+
+var watchStreamDesc = &grpc.StreamDesc{StreamName: "Watch", ServerStreams: true}
+
+// grpcProvider follows a hyrouter.Discovery/Watch stream served by an external process.
+// When the stream breaks it reconnects with exponential backoff and keeps serving the last
+// complete snapshot in the meantime.
+type grpcProvider struct {
+	name       string
+	address    string
+	params     map[string]string
+	backoff    time.Duration
+	maxBackoff time.Duration
+	logger     *slog.Logger
+
+	snapshot  atomic.Value
+	updatedAt atomic.Int64
+
+	errMu    sync.Mutex
+	watchErr error
+
+	// synced is closed once the first stream has delivered a full snapshot or failed.
+	synced   chan struct{}
+	syncOnce sync.Once
+
+	startOnce sync.Once
+}
+
+func newGRPCProvider(name string, cfg *config.GRPCDiscoveryConfig, logger *slog.Logger) (*grpcProvider, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("discovery provider %q: grpc config must be set", name)
+	}
+	if strings.TrimSpace(cfg.Address) == "" {
+		return nil, fmt.Errorf("discovery provider %q: grpc.address must not be empty", name)
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	p := &grpcProvider{
+		name:       name,
+		address:    cfg.Address,
+		params:     cfg.Params,
+		backoff:    defaultGRPCBackoff,
+		maxBackoff: defaultGRPCMaxBackoff,
+		logger:     logger,
+		synced:     make(chan struct{}),
+	}
+	for _, f := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{{"backoff", cfg.Backoff, &p.backoff}, {"max_backoff", cfg.MaxBackoff, &p.maxBackoff}} {
+		if strings.TrimSpace(f.value) == "" {
+			continue
+		}
+		d, err := time.ParseDuration(f.value)
+		if err != nil {
+			return nil, fmt.Errorf("discovery provider %q: invalid grpc.%s: %w", name, f.name, err)
+		}
+		*f.dst = d
+	}
+	if p.backoff > p.maxBackoff {
+		return nil, fmt.Errorf("discovery provider %q: grpc.backoff must not be greater than max_backoff", name)
+	}
+	p.snapshot.Store([]routing.Backend(nil))
+	return p, nil
+}
+
+// Start opens the watch stream and waits up to grpcStartTimeout for the first full snapshot.
+// A stream that fails first is not fatal: the provider keeps reconnecting in the background and
+// reports "not synced" until a snapshot arrives. The client connection is created here, not in
+// newGRPCProvider, so a provider that is never started holds no connection; it is closed when
+// ctx is done.
+func (p *grpcProvider) Start(ctx context.Context) error {
+	var err error
+	p.startOnce.Do(func() {
+		var conn *grpc.ClientConn
+		conn, err = grpc.NewClient(
+			p.address,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithDefaultCallOptions(grpc.ForceCodec(jsonCodec{})),
+		)
+		if err != nil {
+			err = fmt.Errorf("discovery provider %q: %w", p.name, err)
+			return
+		}
+		go p.run(ctx, conn)
+		t := time.NewTimer(grpcStartTimeout)
+		defer t.Stop()
+		select {
+		case <-p.synced:
+		case <-t.C:
+		case <-ctx.Done():
+		}
+	})
+	return err
+}
+
+func (p *grpcProvider) Resolve(_ context.Context) ([]routing.Backend, error) {
+	bs, _ := p.snapshot.Load().([]routing.Backend)
+	out := make([]routing.Backend, len(bs))
+	copy(out, bs)
+	return out, nil
+}
+
+func (p *grpcProvider) Health() ProviderHealth {
+	h := snapshotHealth(p.name, "grpc", nil, &p.snapshot, &p.updatedAt)
+	p.errMu.Lock()
+	defer p.errMu.Unlock()
+	switch {
+	case p.watchErr == nil:
+	case h.Healthy:
+		h.Healthy = false
+		h.Error = "watch failed, serving previous backends: " + p.watchErr.Error()
+	default:
+		h.Error = "not synced: " + p.watchErr.Error()
+	}
+	return h
+}
+
+func (p *grpcProvider) run(ctx context.Context, conn *grpc.ClientConn) {
+	defer conn.Close()
+	backoff := p.backoff
+	for {
+		synced, err := p.watch(ctx, conn)
+		if ctx.Err() != nil {
+			return
+		}
+		p.setErr(err)
+		p.logger.Warn("discovery grpc watch failed; keeping previous backends", "provider", p.name, "address", p.address, "retry_in", backoff, "error", err)
+		// A stream that delivered a snapshot was healthy, so the next attempt starts over at the initial backoff.
+		if synced {
+			backoff = p.backoff
+		}
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		backoff = min(backoff*2, p.maxBackoff)
+	}
+}
+
+// watch follows one stream until it fails. It reports whether the stream delivered a full snapshot.
+// Updates are applied to a per-stream copy and published only once the stream has sent a full
+// snapshot, so a reconnect never exposes a partial backend list.
+func (p *grpcProvider) watch(ctx context.Context, conn *grpc.ClientConn) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := conn.NewStream(ctx, watchStreamDesc, "/hyrouter.Discovery/Watch")
+	if err != nil {
+		return false, err
+	}
+	if err := stream.SendMsg(&WatchRequest{Provider: p.name, Params: p.params}); err != nil {
+		return false, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return false, err
+	}
+
+	var (
+		state  map[routing.Target]routing.Backend
+		synced bool
+	)
+	for {
+		var resp WatchResponse
+		if err := stream.RecvMsg(&resp); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = errWatchEnded
+			}
+			return synced, err
+		}
+		if err := checkBackends(resp.Backends); err != nil {
+			return synced, err
+		}
+		switch {
+		case resp.Full:
+			state = make(map[routing.Target]routing.Backend, len(resp.Backends))
+			synced = true
+		case !synced:
+			return false, errors.New("incremental update before the first full snapshot")
+		}
+		for _, b := range resp.Backends {
+			state[b.Target()] = b
+		}
+		for _, t := range resp.Removed {
+			delete(state, t)
+		}
+
+		bs := make([]routing.Backend, 0, len(state))
+		for _, b := range state {
+			bs = append(bs, b)
+		}
+		sortBackends(bs)
+		p.snapshot.Store(bs)
+		p.updatedAt.Store(time.Now().UnixNano())
+		p.setErr(nil)
+		p.logger.Debug("discovery grpc update", "provider", p.name, "full", resp.Full, "backends", len(bs))
+	}
+}
+
+func (p *grpcProvider) setErr(err error) {
+	p.errMu.Lock()
+	p.watchErr = err
+	p.errMu.Unlock()
+	p.syncOnce.Do(func() { close(p.synced) })
+}
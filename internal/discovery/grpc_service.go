@@ -0,0 +1,183 @@
+package discovery
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hybrowse/hyrouter/internal/routing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+)
+
+// WatchRequest opens a hyrouter.Discovery/Watch stream.
+type WatchRequest struct {
+	// Provider is the name of the discovery provider in the Hyrouter config.
+	Provider string `json:"provider"`
+	// Params are passed through from grpc.params in the provider config.
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// WatchResponse is one update on a Watch stream.
+//
+// With Full set, Backends is the complete backend list and replaces everything sent before.
+// Otherwise the update is incremental: Backends are added or replace the backend with the same
+// host and port, and Removed backends are dropped. The first update on every stream must be full.
+type WatchResponse struct {
+	Full     bool              `json:"full,omitempty"`
+	Backends []routing.Backend `json:"backends,omitempty"`
+	Removed  []routing.Target  `json:"removed,omitempty"`
+}
+
+// WatchStream is the server side of a Watch stream.
+type WatchStream interface {
+	Send(*WatchResponse) error
+	Context() context.Context
+}
+
+type GRPCServer interface {
+	Watch(*WatchRequest, WatchStream) error
+}
+
+// RegisterGRPCServer registers impl as the hyrouter.Discovery service on s.
+func RegisterGRPCServer(s *grpc.Server, impl GRPCServer) {
+	encoding.RegisterCodec(jsonCodec{})
+	service := &grpc.ServiceDesc{
+		ServiceName: "hyrouter.Discovery",
+		HandlerType: (*GRPCServer)(nil),
+		Methods:     []grpc.MethodDesc{},
+		Streams: []grpc.StreamDesc{
+			{StreamName: "Watch", Handler: watchHandler(impl), ServerStreams: true},
+		},
+		Metadata: "",
+	}
+	s.RegisterService(service, impl)
+}
+
+func watchHandler(impl GRPCServer) grpc.StreamHandler {
+	return func(_ any, stream grpc.ServerStream) error {
+		var req WatchRequest
+		if err := stream.RecvMsg(&req); err != nil {
+			return err
+		}
+		return impl.Watch(&req, &watchStream{stream})
+	}
+}
+
+type watchStream struct {
+	grpc.ServerStream
+}
+
+func (s *watchStream) Send(resp *WatchResponse) error { return s.SendMsg(resp) }
+
+// watchBuffer is how many updates a watcher may fall behind before it is disconnected.
+// It reconnects and starts over with a full snapshot.
+const watchBuffer = 64
+
+// SnapshotServer is a ready-made GRPCServer. The integrating process keeps it up to
+// date with Set, Upsert and Remove; every watcher gets a full snapshot on connect and
+// incremental updates afterwards. It serves the same backends to every provider name.
+type SnapshotServer struct {
+	mu       sync.Mutex
+	backends map[routing.Target]routing.Backend
+	watchers map[*snapshotWatcher]struct{}
+}
+
+type snapshotWatcher struct {
+	updates chan *WatchResponse
+	lagged  chan struct{}
+}
+
+func NewSnapshotServer() *SnapshotServer {
+	return &SnapshotServer{
+		backends: map[routing.Target]routing.Backend{},
+		watchers: map[*snapshotWatcher]struct{}{},
+	}
+}
+
+// Set replaces all backends.
+func (s *SnapshotServer) Set(backends []routing.Backend) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backends = map[routing.Target]routing.Backend{}
+	for _, b := range backends {
+		s.backends[b.Target()] = b
+	}
+	s.broadcast(&WatchResponse{Full: true, Backends: s.listLocked()})
+}
+
+// Upsert adds backends or replaces the ones with the same host and port.
+func (s *SnapshotServer) Upsert(backends ...routing.Backend) {
+	if len(backends) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, b := range backends {
+		s.backends[b.Target()] = b
+	}
+	s.broadcast(&WatchResponse{Backends: append([]routing.Backend(nil), backends...)})
+}
+
+// Remove drops the backends with the given host and port.
+func (s *SnapshotServer) Remove(targets ...routing.Target) {
+	if len(targets) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range targets {
+		delete(s.backends, t)
+	}
+	s.broadcast(&WatchResponse{Removed: append([]routing.Target(nil), targets...)})
+}
+
+func (s *SnapshotServer) Watch(_ *WatchRequest, stream WatchStream) error {
+	w := &snapshotWatcher{updates: make(chan *WatchResponse, watchBuffer), lagged: make(chan struct{})}
+	s.mu.Lock()
+	first := &WatchResponse{Full: true, Backends: s.listLocked()}
+	s.watchers[w] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.watchers, w)
+		s.mu.Unlock()
+	}()
+
+	if err := stream.Send(first); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-w.lagged:
+			return status.Error(codes.ResourceExhausted, "watcher fell behind; reconnect for a full snapshot")
+		case resp := <-w.updates:
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *SnapshotServer) broadcast(resp *WatchResponse) {
+	for w := range s.watchers {
+		select {
+		case w.updates <- resp:
+		default:
+			close(w.lagged)
+			delete(s.watchers, w)
+		}
+	}
+}
+
+func (s *SnapshotServer) listLocked() []routing.Backend {
+	out := make([]routing.Backend, 0, len(s.backends))
+	for _, b := range s.backends {
+		out = append(out, b)
+	}
+	sortBackends(out)
+	return out
+}
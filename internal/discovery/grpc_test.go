@@ -0,0 +1,129 @@
+package discovery
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/routing"
+	"google.golang.org/grpc"
+)
+
+func serveGRPCDiscovery(t *testing.T, impl GRPCServer) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := grpc.NewServer()
+	RegisterGRPCServer(s, impl)
+	go s.Serve(lis) // nolint:errcheck
+	t.Cleanup(s.Stop)
+	return lis.Addr().String()
+}
+
+func newTestGRPCProvider(t *testing.T, cfg config.GRPCDiscoveryConfig) *grpcProvider {
+	t.Helper()
+	p, err := newGRPCProvider("orchestrator", &cfg, slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{})))
+	if err != nil {
+		t.Fatalf("newGRPCProvider: %v", err)
+	}
+	return p
+}
+
+func TestGRPCProviderSnapshotServer(t *testing.T) {
+	srv := NewSnapshotServer()
+	srv.Set([]routing.Backend{{Host: "10.0.0.1", Port: 5520, Meta: map[string]string{"label.mode": "lobby"}}})
+	addr := serveGRPCDiscovery(t, srv)
+
+	p := newTestGRPCProvider(t, config.GRPCDiscoveryConfig{Address: addr})
+	if h := p.Health(); h.Healthy || h.Error != "not synced" {
+		t.Fatalf("health=%#v", h)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := p.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	// Start waits for the first snapshot.
+	if bs, _ := p.Resolve(ctx); len(bs) != 1 || bs[0].Meta["label.mode"] != "lobby" {
+		t.Fatalf("backends=%#v", bs)
+	}
+
+	srv.Upsert(routing.Backend{Host: "10.0.0.2", Port: 5520}, routing.Backend{Host: "10.0.0.1", Port: 5520, Weight: 3})
+	waitForBackends(t, p, func(bs []routing.Backend) bool { return len(bs) == 2 && bs[0].Weight == 3 })
+
+	srv.Remove(routing.Target{Host: "10.0.0.1", Port: 5520})
+	waitForBackends(t, p, func(bs []routing.Backend) bool { return len(bs) == 1 && bs[0].Host == "10.0.0.2" })
+
+	srv.Set(nil)
+	waitForBackends(t, p, func(bs []routing.Backend) bool { return len(bs) == 0 })
+	if h := p.Health(); !h.Healthy || h.Type != "grpc" {
+		t.Fatalf("health=%#v", h)
+	}
+}
+
+// flakyDiscovery ends every stream after one update. Odd attempts send an incremental update
+// first, which the provider must reject without touching its snapshot.
+type flakyDiscovery struct {
+	mu       sync.Mutex
+	attempts atomic.Int32
+	requests []WatchRequest
+}
+
+func (f *flakyDiscovery) Watch(req *WatchRequest, stream WatchStream) error {
+	f.mu.Lock()
+	f.requests = append(f.requests, *req)
+	f.mu.Unlock()
+	n := f.attempts.Add(1)
+	if n%2 == 1 {
+		return stream.Send(&WatchResponse{Backends: []routing.Backend{{Host: "partial", Port: 1}}})
+	}
+	return stream.Send(&WatchResponse{Full: true, Backends: []routing.Backend{{Host: "10.0.0.9", Port: 5520}}})
+}
+
+func TestGRPCProviderReconnects(t *testing.T) {
+	f := &flakyDiscovery{}
+	addr := serveGRPCDiscovery(t, f)
+
+	p := newTestGRPCProvider(t, config.GRPCDiscoveryConfig{
+		Address: addr, Params: map[string]string{"fleet": "eu"}, Backoff: "1ms", MaxBackoff: "5ms",
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := p.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	waitForBackends(t, p, func(bs []routing.Backend) bool { return len(bs) == 1 && bs[0].Host == "10.0.0.9" })
+	waitFor(t, func() bool { return f.attempts.Load() >= 4 })
+
+	// Between streams the last full snapshot stays in place.
+	for range 20 {
+		if bs, _ := p.Resolve(ctx); len(bs) != 1 || bs[0].Host != "10.0.0.9" {
+			t.Fatalf("backends=%#v", bs)
+		}
+	}
+	f.mu.Lock()
+	req := f.requests[0]
+	f.mu.Unlock()
+	if req.Provider != "orchestrator" || req.Params["fleet"] != "eu" {
+		t.Fatalf("request=%#v", req)
+	}
+}
+
+func TestNewGRPCProviderErrors(t *testing.T) {
+	for name, cfg := range map[string]config.GRPCDiscoveryConfig{
+		"empty address":   {},
+		"bad backoff":     {Address: "127.0.0.1:1", Backoff: "soon"},
+		"backoff too big": {Address: "127.0.0.1:1", Backoff: "1m", MaxBackoff: "1s"},
+	} {
+		if _, err := newGRPCProvider("g", &cfg, nil); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
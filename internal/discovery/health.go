@@ -0,0 +1,65 @@
+package discovery
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/routing"
+)
+
+type ProviderHealth struct {
+	Name       string     `json:"name"`
+	Type       string     `json:"type"`
+	Healthy    bool       `json:"healthy"`
+	Error      string     `json:"error,omitempty"`
+	Backends   int        `json:"backends"`
+	LastUpdate *time.Time `json:"last_update,omitempty"`
+}
+
+// healthReporter is implemented by providers that can report their sync state.
+type healthReporter interface {
+	Health() ProviderHealth
+}
+
+// Health reports the state of every provider, sorted by name.
+func (m *Manager) Health() []ProviderHealth {
+	if m == nil {
+		return nil
+	}
+	names := make([]string, 0, len(m.providers))
+	for name := range m.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := make([]ProviderHealth, 0, len(names))
+	for _, name := range names {
+		hr, ok := m.providers[name].(healthReporter)
+		if !ok {
+			out = append(out, ProviderHealth{Name: name, Healthy: true})
+			continue
+		}
+		out = append(out, hr.Health())
+	}
+	return out
+}
+
+func snapshotHealth(name string, typ string, startErr error, snapshot *atomic.Value, updatedAt *atomic.Int64) ProviderHealth {
+	h := ProviderHealth{Name: name, Type: typ}
+	if bs, ok := snapshot.Load().([]routing.Backend); ok {
+		h.Backends = len(bs)
+	}
+	if ts := updatedAt.Load(); ts != 0 {
+		t := time.Unix(0, ts).UTC()
+		h.LastUpdate = &t
+	}
+	switch {
+	case startErr != nil:
+		h.Error = startErr.Error()
+	case h.LastUpdate == nil:
+		h.Error = "not synced"
+	default:
+		h.Healthy = true
+	}
+	return h
+}
@@ -0,0 +1,380 @@
+package discovery
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/routing"
+)
+
+const (
+	defaultHTTPInterval        = 10 * time.Second
+	defaultHTTPTimeout         = 5 * time.Second
+	defaultHTTPLongPollTimeout = 90 * time.Second
+	maxHTTPBackendsBody        = 16 << 20
+)
+
+var errEventStreamClosed = errors.New("event stream closed")
+
+// httpProvider fetches backends from an HTTP endpoint, either by polling it, by long-polling it,
+// or by following a Server-Sent Events stream. A failed fetch leaves the previous backends in place.
+type httpProvider struct {
+	name     string
+	url      string
+	watch    string
+	interval time.Duration
+	timeout  time.Duration
+	jitter   time.Duration
+	logger   *slog.Logger
+	client   *http.Client
+
+	authHeader string
+	authValue  string
+	authFile   string
+
+	// etag is only touched by Start and the run goroutine it launches.
+	etag string
+
+	// synced is closed once the first fetch or event stream attempt has finished.
+	synced   chan struct{}
+	syncOnce sync.Once
+
+	snapshot  atomic.Value
+	updatedAt atomic.Int64
+
+	errMu    sync.Mutex
+	fetchErr error
+
+	startOnce sync.Once
+}
+
+func newHTTPProvider(name string, cfg *config.HTTPDiscoveryConfig, logger *slog.Logger) (*httpProvider, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("discovery provider %q: http config must be set", name)
+	}
+	if strings.TrimSpace(cfg.URL) == "" {
+		return nil, fmt.Errorf("discovery provider %q: http.url must not be empty", name)
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	p := &httpProvider{
+		name:     name,
+		url:      strings.TrimSpace(cfg.URL),
+		watch:    strings.ToLower(strings.TrimSpace(cfg.Watch)),
+		interval: defaultHTTPInterval,
+		timeout:  defaultHTTPTimeout,
+		logger:   logger,
+		client:   &http.Client{},
+		synced:   make(chan struct{}),
+	}
+	switch p.watch {
+	case "", "poll":
+		p.watch = "poll"
+	case "long_poll":
+		p.timeout = defaultHTTPLongPollTimeout
+	case "sse":
+	default:
+		return nil, fmt.Errorf("discovery provider %q: http.watch must be one of: poll, long_poll, sse", name)
+	}
+	for _, f := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{{"interval", cfg.Interval, &p.interval}, {"timeout", cfg.Timeout, &p.timeout}, {"jitter", cfg.Jitter, &p.jitter}} {
+		if strings.TrimSpace(f.value) == "" {
+			continue
+		}
+		d, err := time.ParseDuration(f.value)
+		if err != nil {
+			return nil, fmt.Errorf("discovery provider %q: invalid http.%s: %w", name, f.name, err)
+		}
+		*f.dst = d
+	}
+	if a := cfg.Auth; a != nil {
+		p.authHeader = a.Header
+		if p.authHeader == "" {
+			p.authHeader = "Authorization"
+		}
+		switch {
+		case a.ValueFile != "":
+			p.authFile = a.ValueFile
+			if _, err := p.authorization(); err != nil {
+				return nil, fmt.Errorf("discovery provider %q: %w", name, err)
+			}
+		case a.ValueEnv != "":
+			p.authValue = strings.TrimSpace(os.Getenv(a.ValueEnv))
+			if p.authValue == "" {
+				return nil, fmt.Errorf("discovery provider %q: environment variable %s for http.auth is not set", name, a.ValueEnv)
+			}
+		default:
+			return nil, fmt.Errorf("discovery provider %q: http.auth must set value_env or value_file", name)
+		}
+	}
+	p.snapshot.Store([]routing.Backend(nil))
+	return p, nil
+}
+
+// Start fetches the backends once and keeps them up to date until ctx is done. Event streams
+// deliver the backends on connect, so Start waits up to timeout for the first event instead.
+// A failed first fetch is not fatal: the provider reports "not synced" until a fetch succeeds.
+func (p *httpProvider) Start(ctx context.Context) error {
+	p.startOnce.Do(func() {
+		if p.watch != "sse" {
+			err := p.record(p.fetch(ctx))
+			go p.run(ctx, err)
+			return
+		}
+		go p.run(ctx, nil)
+		t := time.NewTimer(p.timeout)
+		defer t.Stop()
+		select {
+		case <-p.synced:
+		case <-t.C:
+		case <-ctx.Done():
+		}
+	})
+	return nil
+}
+
+func (p *httpProvider) Resolve(_ context.Context) ([]routing.Backend, error) {
+	bs, _ := p.snapshot.Load().([]routing.Backend)
+	out := make([]routing.Backend, len(bs))
+	copy(out, bs)
+	return out, nil
+}
+
+func (p *httpProvider) Health() ProviderHealth {
+	h := snapshotHealth(p.name, "http", nil, &p.snapshot, &p.updatedAt)
+	p.errMu.Lock()
+	defer p.errMu.Unlock()
+	switch {
+	case p.fetchErr == nil:
+	case h.Healthy:
+		h.Healthy = false
+		h.Error = "fetch failed, serving previous backends: " + p.fetchErr.Error()
+	default:
+		h.Error = "not synced: " + p.fetchErr.Error()
+	}
+	return h
+}
+
+// run keeps the snapshot current. Polling waits interval between requests. Long-polling and
+// event streams reconnect right away while the server behaves and back off by interval after an error.
+func (p *httpProvider) run(ctx context.Context, err error) {
+	for {
+		wait := p.interval
+		if err == nil && p.watch != "poll" {
+			wait = 0
+		}
+		if wait > 0 && p.jitter > 0 {
+			wait += rand.N(p.jitter)
+		}
+		if wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return
+			case <-t.C:
+			}
+		}
+		if p.watch == "sse" {
+			err = p.stream(ctx)
+		} else {
+			err = p.fetch(ctx)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		p.record(err)
+	}
+}
+
+func (p *httpProvider) record(err error) error {
+	p.errMu.Lock()
+	p.fetchErr = err
+	p.errMu.Unlock()
+	p.syncOnce.Do(func() { close(p.synced) })
+	if err != nil {
+		p.logger.Warn("discovery http fetch failed; keeping previous backends", "provider", p.name, "url", p.url, "error", err)
+	}
+	return err
+}
+
+func (p *httpProvider) store(bs []routing.Backend) {
+	p.snapshot.Store(bs)
+	p.updatedAt.Store(time.Now().UnixNano())
+}
+
+// fetch requests the backends once. With an ETag from the previous response the request is
+// conditional, and 304 Not Modified keeps the current backends.
+func (p *httpProvider) fetch(ctx context.Context) error {
+	timeout := p.timeout
+	if p.etag == "" && p.watch == "long_poll" {
+		// Without an ETag the server has nothing to wait for and answers right away.
+		timeout = min(timeout, defaultHTTPTimeout)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := p.newRequest(ctx, "application/json")
+	if err != nil {
+		return err
+	}
+	if p.etag != "" {
+		req.Header.Set("If-None-Match", p.etag)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		p.updatedAt.Store(time.Now().UnixNano())
+		return nil
+	case http.StatusOK:
+	default:
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPBackendsBody))
+	if err != nil {
+		return err
+	}
+	bs, err := parseBackendsJSON(body)
+	if err != nil {
+		return err
+	}
+	p.store(bs)
+	p.etag = resp.Header.Get("ETag")
+	p.logger.Debug("discovery http fetched", "provider", p.name, "url", p.url, "backends", len(bs))
+	return nil
+}
+
+// stream follows a Server-Sent Events response. Every "backends" event (or event without a name)
+// carries the full backend list as JSON in its data lines and replaces the snapshot.
+func (p *httpProvider) stream(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	req, err := p.newRequest(ctx, "text/event-stream")
+	if err != nil {
+		return err
+	}
+	// The timeout only covers connecting; an open stream may stay quiet for as long as nothing changes.
+	connect := time.AfterFunc(p.timeout, cancel)
+	resp, err := p.client.Do(req)
+	connect.Stop()
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 0, 64<<10), maxHTTPBackendsBody)
+	var (
+		event string
+		data  []string
+	)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			if len(data) > 0 && (event == "" || event == "backends") {
+				bs, err := parseBackendsJSON([]byte(strings.Join(data, "\n")))
+				if err != nil {
+					return fmt.Errorf("event stream: %w", err)
+				}
+				p.store(bs)
+				p.record(nil)
+				p.logger.Debug("discovery http event received", "provider", p.name, "url", p.url, "backends", len(bs))
+			}
+			event, data = "", nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("event stream: %w", err)
+	}
+	return errEventStreamClosed
+}
+
+func (p *httpProvider) newRequest(ctx context.Context, accept string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	if p.authHeader != "" {
+		v, err := p.authorization()
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(p.authHeader, v)
+	}
+	return req, nil
+}
+
+// authorization returns the auth header value. Files are re-read on every request so rotated
+// credentials (e.g. projected service account tokens) are picked up without a restart.
+func (p *httpProvider) authorization() (string, error) {
+	if p.authFile == "" {
+		return p.authValue, nil
+	}
+	b, err := os.ReadFile(p.authFile)
+	if err != nil {
+		return "", fmt.Errorf("read http.auth.value_file: %w", err)
+	}
+	v := strings.TrimSpace(string(b))
+	if v == "" {
+		return "", fmt.Errorf("http.auth.value_file %s is empty", p.authFile)
+	}
+	return v, nil
+}
+
+// parseBackendsJSON accepts either {"backends": [...]} or a bare list of backends.
+func parseBackendsJSON(b []byte) ([]routing.Backend, error) {
+	b = bytes.TrimSpace(b)
+	var bs []routing.Backend
+	if len(b) > 0 && b[0] == '[' {
+		if err := json.Unmarshal(b, &bs); err != nil {
+			return nil, fmt.Errorf("decode backends: %w", err)
+		}
+	} else {
+		var doc backendsFile
+		if err := json.Unmarshal(b, &doc); err != nil {
+			return nil, fmt.Errorf("decode backends: %w", err)
+		}
+		bs = doc.Backends
+	}
+	if err := checkBackends(bs); err != nil {
+		return nil, err
+	}
+	return bs, nil
+}
@@ -0,0 +1,254 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/routing"
+)
+
+func newTestHTTPProvider(t *testing.T, cfg config.HTTPDiscoveryConfig) *httpProvider {
+	t.Helper()
+	p, err := newHTTPProvider("http", &cfg, slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{})))
+	if err != nil {
+		t.Fatalf("newHTTPProvider: %v", err)
+	}
+	return p
+}
+
+func waitForBackends(t *testing.T, p Provider, cond func([]routing.Backend) bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		bs, _ := p.Resolve(context.Background())
+		if cond(bs) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out, backends=%#v", bs)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHTTPProviderPoll(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		body    = `{"backends":[{"host":"10.0.0.1","port":5520,"weight":2,"meta":{"label.mode":"lobby"}}]}`
+		etag    = `"v1"`
+		status  = http.StatusOK
+		notMods atomic.Int32
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		if r.Header.Get("If-None-Match") == etag {
+			notMods.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		_, _ = io.WriteString(w, body)
+	}))
+	defer srv.Close()
+	set := func(s int, b, e string) {
+		mu.Lock()
+		defer mu.Unlock()
+		status, body, etag = s, b, e
+	}
+
+	t.Setenv("HYROUTER_TEST_TOKEN", "secret")
+	p := newTestHTTPProvider(t, config.HTTPDiscoveryConfig{
+		URL: srv.URL, Interval: "5ms", Jitter: "1ms",
+		Auth: &config.HTTPDiscoveryAuthConfig{Header: "X-Token", ValueEnv: "HYROUTER_TEST_TOKEN"},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := p.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	bs, _ := p.Resolve(ctx)
+	if len(bs) != 1 || bs[0].Host != "10.0.0.1" || bs[0].Weight != 2 || bs[0].Meta["label.mode"] != "lobby" {
+		t.Fatalf("backends=%#v", bs)
+	}
+
+	waitFor(t, func() bool { return notMods.Load() >= 2 })
+	if h := p.Health(); !h.Healthy || h.Type != "http" || h.Backends != 1 {
+		t.Fatalf("health=%#v", h)
+	}
+
+	// Errors keep the previous backends.
+	set(http.StatusInternalServerError, "", "")
+	waitFor(t, func() bool { return !p.Health().Healthy })
+	if bs, _ := p.Resolve(ctx); len(bs) != 1 {
+		t.Fatalf("backends=%#v", bs)
+	}
+
+	set(http.StatusOK, `[{"host":"10.0.0.2","port":5520},{"host":"10.0.0.3","port":5521}]`, `"v2"`)
+	waitForBackends(t, p, func(bs []routing.Backend) bool { return len(bs) == 2 })
+	waitFor(t, func() bool { return p.Health().Healthy })
+}
+
+func TestHTTPProviderLongPoll(t *testing.T) {
+	version := make(chan int, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer rotated" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// Hold conditional requests until a new version is published.
+		if r.Header.Get("If-None-Match") != "" {
+			select {
+			case v := <-version:
+				w.Header().Set("ETag", fmt.Sprintf(`"%d"`, v))
+				fmt.Fprintf(w, `{"backends":[{"host":"10.0.0.%d","port":5520}]}`, v)
+			case <-r.Context().Done():
+			}
+			return
+		}
+		w.Header().Set("ETag", `"1"`)
+		_, _ = io.WriteString(w, `{"backends":[{"host":"10.0.0.1","port":5520}]}`)
+	}))
+	defer srv.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("Bearer rotated\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	p := newTestHTTPProvider(t, config.HTTPDiscoveryConfig{
+		URL: srv.URL, Watch: "long_poll", Interval: "1h",
+		Auth: &config.HTTPDiscoveryAuthConfig{ValueFile: tokenFile},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := p.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if bs, _ := p.Resolve(ctx); len(bs) != 1 || bs[0].Host != "10.0.0.1" {
+		t.Fatalf("backends=%#v", bs)
+	}
+
+	// The held request returns as soon as the server has news, long before the interval.
+	version <- 2
+	waitForBackends(t, p, func(bs []routing.Backend) bool { return len(bs) == 1 && bs[0].Host == "10.0.0.2" })
+}
+
+func TestHTTPProviderSSE(t *testing.T) {
+	events := make(chan string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "text/event-stream" {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		_, _ = io.WriteString(w, ": connected\n\ndata: {\"backends\":[{\"host\":\"10.0.0.1\",\ndata: \"port\":5520}]}\n\n")
+		flusher.Flush()
+		for {
+			select {
+			case ev := <-events:
+				_, _ = io.WriteString(w, ev)
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	p := newTestHTTPProvider(t, config.HTTPDiscoveryConfig{URL: srv.URL, Watch: "sse", Interval: "1h"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := p.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	// Start waits for the first event.
+	if bs, _ := p.Resolve(ctx); len(bs) != 1 || bs[0].Host != "10.0.0.1" {
+		t.Fatalf("backends=%#v", bs)
+	}
+
+	// Events with another name are ignored.
+	events <- "event: ping\ndata: {}\n\n"
+	events <- "event: backends\ndata: [{\"host\":\"10.0.0.2\",\"port\":5520},{\"host\":\"10.0.0.3\",\"port\":5520}]\n\n"
+	waitForBackends(t, p, func(bs []routing.Backend) bool { return len(bs) == 2 })
+	if h := p.Health(); !h.Healthy {
+		t.Fatalf("health=%#v", h)
+	}
+}
+
+func TestHTTPProviderStartUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	p := newTestHTTPProvider(t, config.HTTPDiscoveryConfig{URL: url, Interval: "1h"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := p.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if h := p.Health(); h.Healthy || h.LastUpdate != nil || !strings.HasPrefix(h.Error, "not synced: ") {
+		t.Fatalf("health=%#v", h)
+	}
+}
+
+func TestParseBackendsJSON(t *testing.T) {
+	for name, body := range map[string]string{
+		"garbage":      `<html>`,
+		"missing port": `{"backends":[{"host":"a"}]}`,
+		"empty host":   `[{"port":1}]`,
+	} {
+		if _, err := parseBackendsJSON([]byte(body)); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+	if bs, err := parseBackendsJSON([]byte(` {"backends":[]} `)); err != nil || len(bs) != 0 {
+		t.Fatalf("backends=%#v err=%v", bs, err)
+	}
+}
+
+func TestNewHTTPProviderErrors(t *testing.T) {
+	for name, cfg := range map[string]config.HTTPDiscoveryConfig{
+		"empty url":      {},
+		"bad watch":      {URL: "http://x", Watch: "push"},
+		"bad interval":   {URL: "http://x", Interval: "often"},
+		"unset env":      {URL: "http://x", Auth: &config.HTTPDiscoveryAuthConfig{ValueEnv: "HYROUTER_TEST_UNSET"}},
+		"missing file":   {URL: "http://x", Auth: &config.HTTPDiscoveryAuthConfig{ValueFile: filepath.Join(t.TempDir(), "nope")}},
+		"no auth source": {URL: "http://x", Auth: &config.HTTPDiscoveryAuthConfig{}},
+	} {
+		if _, err := newHTTPProvider("http", &cfg, nil); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -35,6 +35,7 @@ type kubernetesProvider struct {
 
 	rebuildMu sync.Mutex
 	snapshot  atomic.Value
+	updatedAt atomic.Int64
 
 	startOnce sync.Once
 	startErr  error
@@ -125,6 +126,10 @@ func (p *kubernetesProvider) Resolve(_ context.Context) ([]routing.Backend, erro
 	return out, nil
 }
 
+func (p *kubernetesProvider) Health() ProviderHealth {
+	return snapshotHealth(p.name, "kubernetes", p.startErr, &p.snapshot, &p.updatedAt)
+}
+
 func (p *kubernetesProvider) rebuild() {
 	p.rebuildMu.Lock()
 	defer p.rebuildMu.Unlock()
@@ -179,6 +184,7 @@ func (p *kubernetesProvider) rebuild() {
 					b := routing.Backend{Host: pod.Status.PodIP, Port: port, Meta: map[string]string{}}
 					fillK8sMeta(b.Meta, pod.Namespace, pod.Name, pod.Spec.NodeName)
 					copySelectedLabels(b.Meta, pod.Labels, p.cfg.Metadata.IncludeLabels)
+					copyHostnameLabel(b.Meta, pod.Labels)
 					copySelectedAnnotations(b.Meta, pod.Annotations, p.cfg.Metadata.IncludeAnnotations)
 					applyWeightFromMaps(&b, pod.Labels, pod.Annotations)
 					out = append(out, b)
@@ -237,6 +243,7 @@ func (p *kubernetesProvider) rebuild() {
 							b := routing.Backend{Host: addr, Port: port, Meta: map[string]string{}}
 							fillK8sMeta(b.Meta, es.Namespace, es.Name, "")
 							copySelectedLabels(b.Meta, es.Labels, p.cfg.Metadata.IncludeLabels)
+							copyHostnameLabel(b.Meta, es.Labels)
 							copySelectedAnnotations(b.Meta, es.Annotations, p.cfg.Metadata.IncludeAnnotations)
 							applyWeightFromMaps(&b, es.Labels, es.Annotations)
 							out = append(out, b)
@@ -248,6 +255,7 @@ func (p *kubernetesProvider) rebuild() {
 	}
 
 	p.snapshot.Store(out)
+	p.updatedAt.Store(time.Now().UnixNano())
 }
 
 func (p *kubernetesProvider) podAllowed(pod *corev1.Pod, sel *config.KubernetesSelector) bool {
@@ -374,6 +382,14 @@ func copySelectedLabels(meta map[string]string, labelsMap map[string]string, inc
 	}
 }
 
+// copyHostnameLabel always exports the hyrouter/hostname label, so pools can look up a backend by SNI
+// with a discovery selector on label.hyrouter/hostname.
+func copyHostnameLabel(meta map[string]string, labelsMap map[string]string) {
+	if v := labelsMap["hyrouter/hostname"]; v != "" {
+		meta["label.hyrouter/hostname"] = v
+	}
+}
+
 func copySelectedAnnotations(meta map[string]string, ann map[string]string, include []string) {
 	for _, k := range include {
 		if v, ok := ann[k]; ok {
@@ -53,8 +53,38 @@ func New(cfg *config.DiscoveryConfig, logger *slog.Logger) (*Manager, error) {
 				return nil, err
 			}
 			m.providers[p.Name] = prov
+		case "file":
+			prov, err := newFileProvider(p.Name, p.File, logger)
+			if err != nil {
+				return nil, err
+			}
+			m.providers[p.Name] = prov
+		case "dns":
+			prov, err := newDNSProvider(p.Name, p.DNS, logger)
+			if err != nil {
+				return nil, err
+			}
+			m.providers[p.Name] = prov
+		case "http":
+			prov, err := newHTTPProvider(p.Name, p.HTTP, logger)
+			if err != nil {
+				return nil, err
+			}
+			m.providers[p.Name] = prov
+		case "grpc":
+			prov, err := newGRPCProvider(p.Name, p.GRPC, logger)
+			if err != nil {
+				return nil, err
+			}
+			m.providers[p.Name] = prov
+		case "consul":
+			prov, err := newConsulProvider(p.Name, p.Consul, logger)
+			if err != nil {
+				return nil, err
+			}
+			m.providers[p.Name] = prov
 		default:
-			return nil, fmt.Errorf("discovery.providers[%d].type must be one of: kubernetes, agones", i)
+			return nil, fmt.Errorf("discovery.providers[%d].type must be one of: kubernetes, agones, file, dns, http, grpc, consul", i)
 		}
 	}
 	return m, nil
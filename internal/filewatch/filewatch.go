@@ -0,0 +1,48 @@
+package filewatch
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func stat(path string) fileState {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{exists: true, size: fi.Size(), modTime: fi.ModTime()}
+}
+
+// Poll calls fn every time the size or modification time of path changes.
+// Polling (instead of inotify) keeps working across atomic symlink swaps such as Kubernetes ConfigMap updates.
+// Poll blocks until ctx is done.
+func Poll(ctx context.Context, path string, interval time.Duration, fn func()) {
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	last := stat(path)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		cur := stat(path)
+		if cur == last {
+			continue
+		}
+		last = cur
+		if cur.exists {
+			fn()
+		}
+	}
+}
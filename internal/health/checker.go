@@ -0,0 +1,233 @@
+package health
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/routing"
+	"github.com/quic-go/quic-go"
+)
+
+const (
+	defaultInterval           = 10 * time.Second
+	defaultTimeout            = 2 * time.Second
+	defaultUnhealthyThreshold = 3
+	defaultHealthyThreshold   = 2
+
+	// maxConcurrentProbes bounds the number of QUIC handshakes in flight per round.
+	maxConcurrentProbes = 32
+)
+
+var defaultALPN = []string{"hytale/2", "hytale/1"}
+
+// ProbeFunc checks a single backend and returns the measured round-trip time.
+type ProbeFunc func(ctx context.Context, t routing.Target) (time.Duration, error)
+
+// Checker actively probes backends and tracks their health with hysteresis:
+// a backend turns unhealthy after UnhealthyThreshold consecutive failures and
+// healthy again after HealthyThreshold consecutive successes.
+type Checker struct {
+	logger *slog.Logger
+
+	interval       time.Duration
+	timeout        time.Duration
+	unhealthyAfter int
+	healthyAfter   int
+	failOpen       bool
+	probe          ProbeFunc
+
+	mu     sync.RWMutex
+	states map[routing.Target]*state
+}
+
+type state struct {
+	status    string
+	rtt       time.Duration
+	successes int
+	failures  int
+	lastError string
+}
+
+func New(cfg *config.HealthCheckConfig, logger *slog.Logger) (*Checker, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("health_check must be set")
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	c := &Checker{
+		logger:         logger,
+		interval:       defaultInterval,
+		timeout:        defaultTimeout,
+		unhealthyAfter: defaultUnhealthyThreshold,
+		healthyAfter:   defaultHealthyThreshold,
+		failOpen:       cfg.FailOpen,
+		states:         map[routing.Target]*state{},
+	}
+	if cfg.Interval != "" {
+		d, err := time.ParseDuration(cfg.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid health_check.interval: %w", err)
+		}
+		c.interval = d
+	}
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid health_check.timeout: %w", err)
+		}
+		c.timeout = d
+	}
+	if cfg.UnhealthyThreshold > 0 {
+		c.unhealthyAfter = cfg.UnhealthyThreshold
+	}
+	if cfg.HealthyThreshold > 0 {
+		c.healthyAfter = cfg.HealthyThreshold
+	}
+	alpn := defaultALPN
+	if len(cfg.ALPN) > 0 {
+		alpn = cfg.ALPN
+	}
+	c.probe = quicProbe(alpn, c.timeout)
+	return c, nil
+}
+
+// SetProbe replaces the QUIC probe (used by tests).
+func (c *Checker) SetProbe(fn ProbeFunc) {
+	c.probe = fn
+}
+
+func (c *Checker) FailOpen() bool {
+	if c == nil {
+		return false
+	}
+	return c.failOpen
+}
+
+// Run probes the backends returned by targets every interval until ctx is done.
+func (c *Checker) Run(ctx context.Context, targets func(ctx context.Context) []routing.Target) {
+	t := time.NewTicker(c.interval)
+	defer t.Stop()
+	for {
+		c.CheckOnce(ctx, targets(ctx))
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// CheckOnce probes every target concurrently and forgets the state of targets that are no longer known.
+func (c *Checker) CheckOnce(ctx context.Context, targets []routing.Target) {
+	c.mu.Lock()
+	known := make(map[routing.Target]struct{}, len(targets))
+	for _, t := range targets {
+		known[t] = struct{}{}
+	}
+	for t := range c.states {
+		if _, ok := known[t]; !ok {
+			delete(c.states, t)
+		}
+	}
+	c.mu.Unlock()
+
+	sem := make(chan struct{}, maxConcurrentProbes)
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(t routing.Target) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			pctx, cancel := context.WithTimeout(ctx, c.timeout)
+			rtt, err := c.probe(pctx, t)
+			cancel()
+			if ctx.Err() != nil {
+				return
+			}
+			c.record(t, rtt, err)
+		}(t)
+	}
+	wg.Wait()
+}
+
+func (c *Checker) record(t routing.Target, rtt time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, ok := c.states[t]
+	if !ok {
+		st = &state{status: routing.HealthUnknown}
+		c.states[t] = st
+	}
+	prev := st.status
+	if err != nil {
+		st.failures++
+		st.successes = 0
+		st.lastError = err.Error()
+		if st.failures >= c.unhealthyAfter {
+			st.status = routing.HealthUnhealthy
+		}
+	} else {
+		st.successes++
+		st.failures = 0
+		st.lastError = ""
+		st.rtt = rtt
+		if st.successes >= c.healthyAfter || st.status == routing.HealthUnknown {
+			st.status = routing.HealthHealthy
+		}
+	}
+	if prev != st.status && (prev != routing.HealthUnknown || st.status != routing.HealthHealthy) {
+		c.logger.Info("backend health changed", "host", t.Host, "port", t.Port, "status", st.status, "error", st.lastError)
+	}
+}
+
+// Status reports the health of t. ok is false for targets that were not probed yet.
+func (c *Checker) Status(t routing.Target) (routing.Health, bool) {
+	if c == nil {
+		return routing.Health{}, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	st, ok := c.states[t]
+	if !ok {
+		return routing.Health{}, false
+	}
+	return routing.Health{Status: st.status, RTT: st.rtt}, true
+}
+
+func quicProbe(alpn []string, timeout time.Duration) ProbeFunc {
+	return func(ctx context.Context, t routing.Target) (time.Duration, error) {
+		tlsConf := &tls.Config{
+			// Backends commonly use self-signed certificates; the probe only checks that the
+			// server completes a handshake for the Hytale ALPN.
+			InsecureSkipVerify: true, // nolint:gosec
+			NextProtos:         alpn,
+			ServerName:         strings.TrimSuffix(t.Host, "."),
+		}
+		start := time.Now()
+		conn, err := quic.DialAddr(ctx, net.JoinHostPort(t.Host, strconv.Itoa(t.Port)), tlsConf, &quic.Config{HandshakeIdleTimeout: timeout})
+		if err != nil {
+			return 0, err
+		}
+		rtt := conn.ConnectionStats().SmoothedRTT
+		if rtt <= 0 {
+			rtt = time.Since(start)
+		}
+		_ = conn.CloseWithError(0, "health check")
+		return rtt, nil
+	}
+}
@@ -0,0 +1,141 @@
+package health
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/routing"
+	"github.com/quic-go/quic-go"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+}
+
+func TestCheckerThresholds(t *testing.T) {
+	c, err := New(&config.HealthCheckConfig{UnhealthyThreshold: 2, HealthyThreshold: 2}, testLogger())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	a := routing.Target{Host: "a", Port: 1}
+	up := true
+	c.SetProbe(func(ctx context.Context, tgt routing.Target) (time.Duration, error) {
+		if up {
+			return 3 * time.Millisecond, nil
+		}
+		return 0, errors.New("timeout")
+	})
+
+	if _, ok := c.Status(a); ok {
+		t.Fatalf("expected no status before the first probe")
+	}
+	step := func(want string) {
+		t.Helper()
+		c.CheckOnce(context.Background(), []routing.Target{a})
+		h, ok := c.Status(a)
+		if !ok || h.Status != want {
+			t.Fatalf("status=%q ok=%v, want %q", h.Status, ok, want)
+		}
+	}
+
+	step(routing.HealthHealthy)
+	up = false
+	step(routing.HealthHealthy)
+	step(routing.HealthUnhealthy)
+	up = true
+	step(routing.HealthUnhealthy)
+	step(routing.HealthHealthy)
+	if h, _ := c.Status(a); h.RTT != 3*time.Millisecond {
+		t.Fatalf("rtt=%v", h.RTT)
+	}
+
+	c.CheckOnce(context.Background(), nil)
+	if _, ok := c.Status(a); ok {
+		t.Fatalf("expected state of removed target to be forgotten")
+	}
+}
+
+func TestCheckerStartsUnknownUntilThreshold(t *testing.T) {
+	c, err := New(&config.HealthCheckConfig{UnhealthyThreshold: 2}, testLogger())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	c.SetProbe(func(ctx context.Context, tgt routing.Target) (time.Duration, error) {
+		return 0, errors.New("refused")
+	})
+	a := routing.Target{Host: "a", Port: 1}
+	c.CheckOnce(context.Background(), []routing.Target{a})
+	if h, _ := c.Status(a); h.Status != routing.HealthUnknown {
+		t.Fatalf("status=%q", h.Status)
+	}
+	c.CheckOnce(context.Background(), []routing.Target{a})
+	if h, _ := c.Status(a); h.Status != routing.HealthUnhealthy {
+		t.Fatalf("status=%q", h.Status)
+	}
+}
+
+func testTLSConfig(t *testing.T) *tls.Config {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	tmpl := x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, pub, priv)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: priv}},
+		NextProtos:   []string{"hytale/2"},
+	}
+}
+
+func TestQUICProbe(t *testing.T) {
+	ln, err := quic.ListenAddr("127.0.0.1:0", testTLSConfig(t), nil)
+	if err != nil {
+		t.Fatalf("ListenAddr: %v", err)
+	}
+	defer ln.Close() // nolint:errcheck
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for {
+			conn, err := ln.Accept(ctx)
+			if err != nil {
+				return
+			}
+			go func() {
+				<-conn.Context().Done()
+			}()
+		}
+	}()
+
+	port := ln.Addr().(*net.UDPAddr).Port
+	probe := quicProbe([]string{"hytale/2"}, time.Second)
+	rtt, err := probe(ctx, routing.Target{Host: "127.0.0.1", Port: port})
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if rtt <= 0 {
+		t.Fatalf("rtt=%v", rtt)
+	}
+
+	// The listener only speaks hytale/2, so a probe for another ALPN must fail.
+	pctx, pcancel := context.WithTimeout(ctx, time.Second)
+	defer pcancel()
+	if _, err := quicProbe([]string{"other/1"}, time.Second)(pctx, routing.Target{Host: "127.0.0.1", Port: port}); err == nil {
+		t.Fatalf("expected ALPN mismatch to fail")
+	}
+}
@@ -0,0 +1,94 @@
+package inflight
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/routing"
+)
+
+const defaultWindow = 10 * time.Second
+
+// Tracker counts the referrals this instance sent to each backend within a sliding window.
+// Strategies add the count to the load reported by discovery, which lags behind when counters
+// are updated slowly. All methods are safe to call on a nil *Tracker, which disables tracking.
+type Tracker struct {
+	now    func() time.Time
+	window time.Duration
+
+	mu        sync.Mutex
+	referrals map[routing.Target][]time.Time
+	lastSweep time.Time
+}
+
+func New(cfg *config.ReferralAccountingConfig) (*Tracker, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("referral_accounting must be set")
+	}
+	t := &Tracker{
+		now:       time.Now,
+		window:    defaultWindow,
+		referrals: map[routing.Target][]time.Time{},
+	}
+	if cfg.Window != "" {
+		d, err := time.ParseDuration(cfg.Window)
+		if err != nil {
+			return nil, fmt.Errorf("invalid referral_accounting.window: %w", err)
+		}
+		t.window = d
+	}
+	return t, nil
+}
+
+// Referred records a referral to target.
+func (t *Tracker) Referred(target routing.Target) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	t.sweep(now)
+	t.referrals[target] = append(t.prune(t.referrals[target], now), now)
+}
+
+// Recent returns the number of referrals to target within the window.
+func (t *Tracker) Recent(target routing.Target) int {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	times := t.prune(t.referrals[target], t.now())
+	if len(times) == 0 {
+		delete(t.referrals, target)
+		return 0
+	}
+	t.referrals[target] = times
+	return len(times)
+}
+
+// prune drops the referrals that left the window. Times are in ascending order.
+func (t *Tracker) prune(times []time.Time, now time.Time) []time.Time {
+	cutoff := now.Add(-t.window)
+	i := 0
+	for i < len(times) && !times[i].After(cutoff) {
+		i++
+	}
+	return times[i:]
+}
+
+// sweep forgets backends without recent referrals, at most once per window.
+func (t *Tracker) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < t.window {
+		return
+	}
+	t.lastSweep = now
+	for target, times := range t.referrals {
+		if len(t.prune(times, now)) == 0 {
+			delete(t.referrals, target)
+		}
+	}
+}
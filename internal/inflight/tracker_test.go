@@ -0,0 +1,51 @@
+package inflight
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/routing"
+)
+
+func TestTrackerWindow(t *testing.T) {
+	tr, err := New(&config.ReferralAccountingConfig{Window: "10s"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	now := time.Unix(1000, 0)
+	tr.now = func() time.Time { return now }
+	a := routing.Target{Host: "a", Port: 1}
+	b := routing.Target{Host: "b", Port: 1}
+
+	tr.Referred(a)
+	now = now.Add(5 * time.Second)
+	tr.Referred(a)
+	tr.Referred(b)
+	if got := tr.Recent(a); got != 2 {
+		t.Fatalf("Recent(a)=%d want 2", got)
+	}
+	now = now.Add(6 * time.Second)
+	if got := tr.Recent(a); got != 1 {
+		t.Fatalf("Recent(a)=%d want 1", got)
+	}
+	now = now.Add(10 * time.Second)
+	tr.Referred(a)
+	if got := tr.Recent(b); got != 0 {
+		t.Fatalf("Recent(b)=%d want 0", got)
+	}
+	if _, ok := tr.referrals[b]; ok {
+		t.Fatalf("expected b to be swept")
+	}
+}
+
+func TestTrackerNil(t *testing.T) {
+	var tr *Tracker
+	tr.Referred(routing.Target{Host: "a", Port: 1})
+	if got := tr.Recent(routing.Target{Host: "a", Port: 1}); got != 0 {
+		t.Fatalf("Recent=%d", got)
+	}
+	if _, err := New(&config.ReferralAccountingConfig{Window: "soon"}); err == nil {
+		t.Fatalf("expected error")
+	}
+}
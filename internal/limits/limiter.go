@@ -0,0 +1,132 @@
+package limits
+
+import (
+	"fmt"
+	"math"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultIPv4Prefix = 32
+	defaultIPv6Prefix = 64
+
+	// sweepInterval is how often idle per-IP buckets are dropped.
+	sweepInterval = time.Minute
+)
+
+// Reasons returned by Allow.
+const (
+	ReasonPerIP  = "per_ip"
+	ReasonGlobal = "global"
+)
+
+// Limiter applies token-bucket rate limits to new connections, per source prefix and globally.
+// All methods are safe to call on a nil *Limiter, which allows everything.
+type Limiter struct {
+	now func() time.Time
+
+	global *rate.Limiter
+
+	perIPRate  rate.Limit
+	perIPBurst int
+	v4Bits     int
+	v6Bits     int
+	// idleAfter is the time after which an unused bucket is full again and can be dropped.
+	idleAfter time.Duration
+
+	mu        sync.Mutex
+	buckets   map[netip.Prefix]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func New(cfg *config.LimitsConfig) (*Limiter, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("limits must be set")
+	}
+	l := &Limiter{now: time.Now, buckets: map[netip.Prefix]*bucket{}}
+	if cfg.Global != nil {
+		l.global = rate.NewLimiter(rate.Limit(cfg.Global.Rate), burst(cfg.Global.Rate, cfg.Global.Burst))
+	}
+	if cfg.PerIP != nil {
+		l.perIPRate = rate.Limit(cfg.PerIP.Rate)
+		l.perIPBurst = burst(cfg.PerIP.Rate, cfg.PerIP.Burst)
+		l.v4Bits = defaultIPv4Prefix
+		if cfg.PerIP.IPv4Prefix > 0 {
+			l.v4Bits = cfg.PerIP.IPv4Prefix
+		}
+		l.v6Bits = defaultIPv6Prefix
+		if cfg.PerIP.IPv6Prefix > 0 {
+			l.v6Bits = cfg.PerIP.IPv6Prefix
+		}
+		l.idleAfter = time.Duration(float64(l.perIPBurst) / cfg.PerIP.Rate * float64(time.Second))
+		if l.idleAfter < sweepInterval {
+			l.idleAfter = sweepInterval
+		}
+	}
+	return l, nil
+}
+
+// burst defaults to the rate (rounded up), so that one second worth of connections can arrive at once.
+func burst(r float64, b int) int {
+	if b > 0 {
+		return b
+	}
+	return int(math.Max(1, math.Ceil(r)))
+}
+
+// Allow reports whether a new connection from addr may proceed. When it may not, reason says which limit was hit.
+func (l *Limiter) Allow(addr netip.Addr) (bool, string) {
+	if l == nil {
+		return true, ""
+	}
+	now := l.now()
+	if l.perIPRate > 0 && addr.IsValid() {
+		if !l.bucketFor(addr, now).AllowN(now, 1) {
+			return false, ReasonPerIP
+		}
+	}
+	if l.global != nil && !l.global.AllowN(now, 1) {
+		return false, ReasonGlobal
+	}
+	return true, ""
+}
+
+func (l *Limiter) bucketFor(addr netip.Addr, now time.Time) *rate.Limiter {
+	addr = addr.Unmap()
+	bits := l.v6Bits
+	if addr.Is4() {
+		bits = l.v4Bits
+	}
+	p, err := addr.Prefix(bits)
+	if err != nil {
+		p = netip.PrefixFrom(addr, addr.BitLen())
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.lastSweep = now
+		for k, b := range l.buckets {
+			if now.Sub(b.lastSeen) >= l.idleAfter {
+				delete(l.buckets, k)
+			}
+		}
+	}
+	b, ok := l.buckets[p]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(l.perIPRate, l.perIPBurst)}
+		l.buckets[p] = b
+	}
+	b.lastSeen = now
+	return b.limiter
+}
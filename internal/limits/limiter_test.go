@@ -0,0 +1,87 @@
+package limits
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+)
+
+func newLimiterForTest(t *testing.T, cfg config.LimitsConfig) (*Limiter, *time.Time) {
+	t.Helper()
+	l, err := New(&cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestLimiterPerIP(t *testing.T) {
+	l, now := newLimiterForTest(t, config.LimitsConfig{PerIP: &config.PerIPLimitConfig{Rate: 1, Burst: 2, IPv4Prefix: 24}})
+	a := netip.MustParseAddr("198.51.100.7")
+	sameNet := netip.MustParseAddr("198.51.100.99")
+	other := netip.MustParseAddr("203.0.113.1")
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow(a); !ok {
+			t.Fatalf("expected burst to allow connection %d", i)
+		}
+	}
+	if ok, reason := l.Allow(sameNet); ok || reason != ReasonPerIP {
+		t.Fatalf("expected same /24 to be limited, ok=%v reason=%q", ok, reason)
+	}
+	if ok, _ := l.Allow(other); !ok {
+		t.Fatalf("expected other prefix to be allowed")
+	}
+
+	*now = now.Add(time.Second)
+	if ok, _ := l.Allow(a); !ok {
+		t.Fatalf("expected a token after one second")
+	}
+}
+
+func TestLimiterIPv6PrefixAndMappedIPv4(t *testing.T) {
+	l, _ := newLimiterForTest(t, config.LimitsConfig{PerIP: &config.PerIPLimitConfig{Rate: 1, Burst: 1}})
+	if ok, _ := l.Allow(netip.MustParseAddr("2001:db8:1:2::1")); !ok {
+		t.Fatalf("expected first connection to be allowed")
+	}
+	if ok, _ := l.Allow(netip.MustParseAddr("2001:db8:1:2::ffff")); ok {
+		t.Fatalf("expected same /64 to be limited")
+	}
+	if ok, _ := l.Allow(netip.MustParseAddr("192.0.2.1")); !ok {
+		t.Fatalf("expected first connection to be allowed")
+	}
+	if ok, _ := l.Allow(netip.MustParseAddr("::ffff:192.0.2.1")); ok {
+		t.Fatalf("expected IPv4-mapped address to share the IPv4 bucket")
+	}
+}
+
+func TestLimiterGlobal(t *testing.T) {
+	l, _ := newLimiterForTest(t, config.LimitsConfig{Global: &config.RateLimitConfig{Rate: 2}})
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow(netip.MustParseAddr("192.0.2.1")); !ok {
+			t.Fatalf("expected connection %d to be allowed", i)
+		}
+	}
+	if ok, reason := l.Allow(netip.MustParseAddr("192.0.2.2")); ok || reason != ReasonGlobal {
+		t.Fatalf("ok=%v reason=%q", ok, reason)
+	}
+}
+
+func TestLimiterDropsIdleBuckets(t *testing.T) {
+	l, now := newLimiterForTest(t, config.LimitsConfig{PerIP: &config.PerIPLimitConfig{Rate: 10}})
+	l.Allow(netip.MustParseAddr("192.0.2.1"))
+	*now = now.Add(2 * sweepInterval)
+	l.Allow(netip.MustParseAddr("192.0.2.2"))
+	if len(l.buckets) != 1 {
+		t.Fatalf("buckets=%d", len(l.buckets))
+	}
+
+	var nilLimiter *Limiter
+	if ok, _ := nilLimiter.Allow(netip.Addr{}); !ok {
+		t.Fatalf("nil limiter must allow")
+	}
+}
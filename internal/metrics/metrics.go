@@ -0,0 +1,214 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "hyrouter"
+
+// Metrics holds the Prometheus collectors for the referral data plane.
+// All methods are safe to call on a nil *Metrics, which disables recording.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	connectionsAccepted prometheus.Counter
+	connectionsRejected *prometheus.CounterVec
+	connectionsInFlight prometheus.Gauge
+	connectPackets      *prometheus.CounterVec
+	referrals           *prometheus.CounterVec
+	disconnects         *prometheus.CounterVec
+	decideDuration      prometheus.Histogram
+	pluginDuration      *prometheus.HistogramVec
+	pluginErrors        *prometheus.CounterVec
+	configReloads       *prometheus.CounterVec
+	queueLength         prometheus.Gauge
+	queueWait           *prometheus.HistogramVec
+}
+
+func New() *Metrics {
+	reg := prometheus.NewRegistry()
+	m := &Metrics{
+		registry: reg,
+		connectionsAccepted: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "connections_accepted_total",
+			Help:      "Number of accepted QUIC connections.",
+		}),
+		connectionsRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "connections_rejected_total",
+			Help:      "Number of QUIC connections rejected by limits, by reason.",
+		}, []string{"reason"}),
+		connectionsInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "connections_in_flight",
+			Help:      "Number of QUIC connections currently being handled.",
+		}),
+		connectPackets: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "connect_packets_total",
+			Help:      "Number of received Connect packets by decode result.",
+		}, []string{"result"}),
+		referrals: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "referrals_total",
+			Help:      "Number of ClientReferral packets sent.",
+		}, []string{"route_index", "strategy"}),
+		disconnects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "disconnects_total",
+			Help:      "Number of Disconnect packets sent by reason.",
+		}, []string{"reason"}),
+		decideDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "routing_decide_duration_seconds",
+			Help:      "Latency of routing decisions.",
+			Buckets:   []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}),
+		pluginDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "plugin_on_connect_duration_seconds",
+			Help:      "Latency of plugin OnConnect calls.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"plugin"}),
+		pluginErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "plugin_on_connect_errors_total",
+			Help:      "Number of failed plugin OnConnect calls.",
+		}, []string{"plugin"}),
+		configReloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "config_reloads_total",
+			Help:      "Number of config reload attempts by result.",
+		}, []string{"result"}),
+		queueLength: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "queue_length",
+			Help:      "Number of players waiting for a backend with capacity.",
+		}),
+		queueWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "queue_wait_seconds",
+			Help:      "Time players spent in the queue, by result.",
+			Buckets:   []float64{1, 2.5, 5, 10, 30, 60, 120, 300, 600},
+		}, []string{"result"}),
+	}
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.connectionsAccepted,
+		m.connectionsRejected,
+		m.connectionsInFlight,
+		m.connectPackets,
+		m.referrals,
+		m.disconnects,
+		m.decideDuration,
+		m.pluginDuration,
+		m.pluginErrors,
+		m.configReloads,
+		m.queueLength,
+		m.queueWait,
+	)
+	return m
+}
+
+func (m *Metrics) Handler() http.Handler {
+	if m == nil {
+		return http.NotFoundHandler()
+	}
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) ConnectionAccepted() {
+	if m == nil {
+		return
+	}
+	m.connectionsAccepted.Inc()
+}
+
+func (m *Metrics) ConnectionRejected(reason string) {
+	if m == nil {
+		return
+	}
+	m.connectionsRejected.WithLabelValues(reason).Inc()
+}
+
+func (m *Metrics) SetConnectionsInFlight(n int64) {
+	if m == nil {
+		return
+	}
+	m.connectionsInFlight.Set(float64(n))
+}
+
+func (m *Metrics) ConnectDecoded(ok bool) {
+	if m == nil {
+		return
+	}
+	result := "decoded"
+	if !ok {
+		result = "undecodable"
+	}
+	m.connectPackets.WithLabelValues(result).Inc()
+}
+
+func (m *Metrics) ReferralSent(routeIndex int, strategy string) {
+	if m == nil {
+		return
+	}
+	m.referrals.WithLabelValues(strconv.Itoa(routeIndex), strategy).Inc()
+}
+
+func (m *Metrics) DisconnectSent(reason string) {
+	if m == nil {
+		return
+	}
+	m.disconnects.WithLabelValues(reason).Inc()
+}
+
+func (m *Metrics) ObserveDecide(d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.decideDuration.Observe(d.Seconds())
+}
+
+func (m *Metrics) ObservePluginCall(plugin string, d time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.pluginDuration.WithLabelValues(plugin).Observe(d.Seconds())
+	if err != nil {
+		m.pluginErrors.WithLabelValues(plugin).Inc()
+	}
+}
+
+func (m *Metrics) ConfigReloaded(ok bool) {
+	if m == nil {
+		return
+	}
+	result := "success"
+	if !ok {
+		result = "failure"
+	}
+	m.configReloads.WithLabelValues(result).Inc()
+}
+
+func (m *Metrics) SetQueueLength(n int) {
+	if m == nil {
+		return
+	}
+	m.queueLength.Set(float64(n))
+}
+
+func (m *Metrics) ObserveQueueWait(result string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.queueWait.WithLabelValues(result).Observe(d.Seconds())
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	b, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	return string(b)
+}
+
+func TestMetricsNilSafe(t *testing.T) {
+	var m *Metrics
+	m.ConnectionAccepted()
+	m.ConnectDecoded(true)
+	m.ReferralSent(0, "round_robin")
+	m.DisconnectSent("no_route")
+	m.ObserveDecide(time.Millisecond)
+	m.ObservePluginCall("p", time.Millisecond, nil)
+	m.ConfigReloaded(true)
+	m.ConnectionRejected("per_ip")
+	m.SetConnectionsInFlight(1)
+	m.SetQueueLength(1)
+	m.ObserveQueueWait("timeout", time.Second)
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("code=%d", rec.Code)
+	}
+}
+
+func TestMetricsExposition(t *testing.T) {
+	m := New()
+	m.ConnectionAccepted()
+	m.ConnectDecoded(true)
+	m.ConnectDecoded(false)
+	m.ReferralSent(2, "p2c")
+	m.DisconnectSent("no_backends")
+	m.ObserveDecide(time.Millisecond)
+	m.ObservePluginCall("deny", time.Millisecond, errors.New("boom"))
+	m.ConfigReloaded(false)
+	m.ConnectionRejected("max_in_flight")
+	m.SetConnectionsInFlight(3)
+	m.SetQueueLength(4)
+	m.ObserveQueueWait("referred", time.Second)
+
+	out := scrape(t, m)
+	for _, want := range []string{
+		"hyrouter_connections_accepted_total 1",
+		`hyrouter_connect_packets_total{result="decoded"} 1`,
+		`hyrouter_connect_packets_total{result="undecodable"} 1`,
+		`hyrouter_referrals_total{route_index="2",strategy="p2c"} 1`,
+		`hyrouter_disconnects_total{reason="no_backends"} 1`,
+		"hyrouter_routing_decide_duration_seconds_count 1",
+		`hyrouter_plugin_on_connect_duration_seconds_count{plugin="deny"} 1`,
+		`hyrouter_plugin_on_connect_errors_total{plugin="deny"} 1`,
+		`hyrouter_config_reloads_total{result="failure"} 1`,
+		`hyrouter_connections_rejected_total{reason="max_in_flight"} 1`,
+		"hyrouter_connections_in_flight 3",
+		"hyrouter_queue_length 4",
+		`hyrouter_queue_wait_seconds_count{result="referred"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package outlier
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/routing"
+)
+
+const (
+	defaultReturnWindow      = 30 * time.Second
+	defaultWindow            = time.Minute
+	defaultEjectionDuration  = 30 * time.Second
+	defaultFailureRatio      = 0.5
+	defaultMinReferrals      = 10
+	defaultMaxEjectedPercent = 50
+)
+
+// Detector infers failed referrals from clients that come back shortly after being referred,
+// and ejects backends whose failure ratio exceeds the threshold.
+// All methods are safe to call on a nil *Detector, which disables detection.
+type Detector struct {
+	logger *slog.Logger
+	now    func() time.Time
+	key    string
+
+	returnWindow      time.Duration
+	window            time.Duration
+	ejectionDuration  time.Duration
+	failureRatio      float64
+	minReferrals      int
+	maxEjectedPercent int
+
+	mu        sync.Mutex
+	pending   map[string]referral
+	stats     map[routing.Target]*stats
+	ejected   map[routing.Target]time.Time
+	lastSweep time.Time
+}
+
+type referral struct {
+	target routing.Target
+	at     time.Time
+}
+
+type stats struct {
+	since     time.Time
+	referrals int
+	failures  int
+}
+
+// Ejection describes a backend that is currently ejected, or the stats of one that is tracked.
+type Ejection struct {
+	Target    routing.Target `json:"target"`
+	Referrals int            `json:"referrals"`
+	Failures  int            `json:"failures"`
+	Ejected   bool           `json:"ejected"`
+	Until     *time.Time     `json:"until,omitempty"`
+}
+
+func New(cfg *config.OutlierDetectionConfig, logger *slog.Logger) (*Detector, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("outlier_detection must be set")
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	d := &Detector{
+		logger:            logger,
+		now:               time.Now,
+		key:               strings.ToLower(strings.TrimSpace(cfg.Key)),
+		returnWindow:      defaultReturnWindow,
+		window:            defaultWindow,
+		ejectionDuration:  defaultEjectionDuration,
+		failureRatio:      defaultFailureRatio,
+		minReferrals:      defaultMinReferrals,
+		maxEjectedPercent: defaultMaxEjectedPercent,
+		pending:           map[string]referral{},
+		stats:             map[routing.Target]*stats{},
+		ejected:           map[routing.Target]time.Time{},
+	}
+	for _, f := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"return_window", cfg.ReturnWindow, &d.returnWindow},
+		{"window", cfg.Window, &d.window},
+		{"ejection_duration", cfg.EjectionDuration, &d.ejectionDuration},
+	} {
+		if f.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(f.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid outlier_detection.%s: %w", f.name, err)
+		}
+		*f.dst = v
+	}
+	if cfg.FailureRatio > 0 {
+		d.failureRatio = cfg.FailureRatio
+	}
+	if cfg.MinReferrals > 0 {
+		d.minReferrals = cfg.MinReferrals
+	}
+	if cfg.MaxEjectedPercent > 0 {
+		d.maxEjectedPercent = cfg.MaxEjectedPercent
+	}
+	return d, nil
+}
+
+// ClientKey returns the key that identifies a client: its UUID (falling back to the IP when the
+// UUID is unknown) or, with key: client_ip, always its IP.
+func (d *Detector) ClientKey(uuid string, clientIP string) string {
+	if d == nil {
+		return ""
+	}
+	if d.key != "client_ip" && uuid != "" {
+		return "uuid:" + uuid
+	}
+	if clientIP == "" {
+		return ""
+	}
+	return "ip:" + clientIP
+}
+
+// Returned records that client connected again. If it was referred less than return_window ago,
+// the referral counts as failed for the backend it was sent to.
+func (d *Detector) Returned(client string) {
+	if d == nil || client == "" {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	ref, ok := d.pending[client]
+	if !ok {
+		return
+	}
+	delete(d.pending, client)
+	if now.Sub(ref.at) > d.returnWindow {
+		return
+	}
+	st := d.statsFor(ref.target, now)
+	st.failures++
+	d.evaluate(ref.target, st, now)
+}
+
+// Referred records that client was referred to t.
+func (d *Detector) Referred(client string, t routing.Target) {
+	if d == nil || client == "" {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	d.sweep(now)
+	d.pending[client] = referral{target: t, at: now}
+	st := d.statsFor(t, now)
+	st.referrals++
+	d.evaluate(t, st, now)
+}
+
+// Ejected reports whether t is currently ejected.
+func (d *Detector) Ejected(t routing.Target) bool {
+	if d == nil {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	until, ok := d.ejected[t]
+	if !ok {
+		return false
+	}
+	if !d.now().Before(until) {
+		delete(d.ejected, t)
+		d.logger.Info("outlier backend restored", "host", t.Host, "port", t.Port)
+		return false
+	}
+	return true
+}
+
+// Snapshot returns the stats of every tracked backend, sorted by target.
+func (d *Detector) Snapshot() []Ejection {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	seen := map[routing.Target]struct{}{}
+	var out []Ejection
+	for t, st := range d.stats {
+		seen[t] = struct{}{}
+		out = append(out, d.ejection(t, st, now))
+	}
+	for t := range d.ejected {
+		if _, ok := seen[t]; !ok {
+			out = append(out, d.ejection(t, &stats{}, now))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Target.Host != out[j].Target.Host {
+			return out[i].Target.Host < out[j].Target.Host
+		}
+		return out[i].Target.Port < out[j].Target.Port
+	})
+	return out
+}
+
+func (d *Detector) ejection(t routing.Target, st *stats, now time.Time) Ejection {
+	e := Ejection{Target: t, Referrals: st.referrals, Failures: st.failures}
+	if until, ok := d.ejected[t]; ok && now.Before(until) {
+		u := until
+		e.Ejected = true
+		e.Until = &u
+	}
+	return e
+}
+
+func (d *Detector) statsFor(t routing.Target, now time.Time) *stats {
+	st, ok := d.stats[t]
+	if !ok || now.Sub(st.since) > d.window {
+		st = &stats{since: now}
+		d.stats[t] = st
+	}
+	return st
+}
+
+func (d *Detector) evaluate(t routing.Target, st *stats, now time.Time) {
+	if st.referrals < d.minReferrals {
+		return
+	}
+	if float64(st.failures)/float64(st.referrals) < d.failureRatio {
+		return
+	}
+	if until, ok := d.ejected[t]; ok && now.Before(until) {
+		return
+	}
+	active := 0
+	for _, until := range d.ejected {
+		if now.Before(until) {
+			active++
+		}
+	}
+	// Never eject more than max_ejected_percent of the backends that received referrals.
+	if (active+1)*100 > d.maxEjectedPercent*len(d.stats) {
+		d.logger.Warn("outlier backend not ejected (max_ejected_percent reached)", "host", t.Host, "port", t.Port, "referrals", st.referrals, "failures", st.failures)
+		return
+	}
+	d.ejected[t] = now.Add(d.ejectionDuration)
+	d.logger.Warn("outlier backend ejected", "host", t.Host, "port", t.Port, "referrals", st.referrals, "failures", st.failures, "duration", d.ejectionDuration.String())
+	// Start a fresh window so the backend is judged on new referrals once it is back.
+	d.stats[t] = &stats{since: now}
+}
+
+func (d *Detector) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < d.returnWindow {
+		return
+	}
+	d.lastSweep = now
+	for k, ref := range d.pending {
+		if now.Sub(ref.at) > d.returnWindow {
+			delete(d.pending, k)
+		}
+	}
+	for t, st := range d.stats {
+		if now.Sub(st.since) > d.window {
+			delete(d.stats, t)
+		}
+	}
+	for t, until := range d.ejected {
+		if !now.Before(until) {
+			delete(d.ejected, t)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package outlier
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/routing"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newDetectorForTest(t *testing.T, cfg config.OutlierDetectionConfig) (*Detector, *fakeClock) {
+	t.Helper()
+	d, err := New(&cfg, slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{})))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	clock := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+	d.now = clock.now
+	return d, clock
+}
+
+func TestDetectorEjectsBackendWithManyReturns(t *testing.T) {
+	d, clock := newDetectorForTest(t, config.OutlierDetectionConfig{MinReferrals: 4, FailureRatio: 0.5, EjectionDuration: "30s"})
+	bad := routing.Target{Host: "bad", Port: 1}
+	good := routing.Target{Host: "good", Port: 1}
+
+	for i := 0; i < 4; i++ {
+		d.Referred(fmt.Sprintf("uuid:good-%d", i), good)
+	}
+	for i := 0; i < 4; i++ {
+		client := fmt.Sprintf("uuid:p%d", i)
+		d.Referred(client, bad)
+		clock.advance(time.Second)
+		if i%2 == 0 {
+			d.Returned(client)
+		}
+	}
+	if !d.Ejected(bad) {
+		t.Fatalf("expected %v to be ejected", bad)
+	}
+	if d.Ejected(good) {
+		t.Fatalf("expected %v not to be ejected", good)
+	}
+
+	clock.advance(31 * time.Second)
+	if d.Ejected(bad) {
+		t.Fatalf("expected %v to be restored after the ejection duration", bad)
+	}
+}
+
+func TestDetectorIgnoresLateReturnsAndLowVolume(t *testing.T) {
+	d, clock := newDetectorForTest(t, config.OutlierDetectionConfig{MinReferrals: 3, FailureRatio: 0.8, ReturnWindow: "10s"})
+	a := routing.Target{Host: "a", Port: 1}
+	d.Referred("uuid:x", routing.Target{Host: "b", Port: 1})
+
+	d.Referred("uuid:1", a)
+	d.Returned("uuid:1")
+	d.Referred("uuid:2", a)
+	d.Returned("uuid:2")
+	if d.Ejected(a) {
+		t.Fatalf("expected no ejection below min_referrals")
+	}
+
+	for i := 3; i <= 6; i++ {
+		d.Referred(fmt.Sprintf("uuid:%d", i), a)
+	}
+	clock.advance(11 * time.Second)
+	for i := 3; i <= 6; i++ {
+		d.Returned(fmt.Sprintf("uuid:%d", i))
+	}
+	if d.Ejected(a) {
+		t.Fatalf("expected late returns not to count")
+	}
+	snap := d.Snapshot()
+	if len(snap) != 2 || snap[0].Target != a || snap[0].Referrals != 6 || snap[0].Failures != 2 {
+		t.Fatalf("snapshot=%+v", snap)
+	}
+}
+
+func TestDetectorMaxEjectedPercent(t *testing.T) {
+	d, _ := newDetectorForTest(t, config.OutlierDetectionConfig{MinReferrals: 1, FailureRatio: 0.5})
+	a := routing.Target{Host: "a", Port: 1}
+	d.Referred("uuid:1", a)
+	d.Returned("uuid:1")
+	if d.Ejected(a) {
+		t.Fatalf("expected the only backend not to be ejected")
+	}
+}
+
+func TestDetectorClientKey(t *testing.T) {
+	d, _ := newDetectorForTest(t, config.OutlierDetectionConfig{})
+	if got := d.ClientKey("u", "1.2.3.4"); got != "uuid:u" {
+		t.Fatalf("got %q", got)
+	}
+	if got := d.ClientKey("", "1.2.3.4"); got != "ip:1.2.3.4" {
+		t.Fatalf("got %q", got)
+	}
+	d, _ = newDetectorForTest(t, config.OutlierDetectionConfig{Key: "client_ip"})
+	if got := d.ClientKey("u", "1.2.3.4"); got != "ip:1.2.3.4" {
+		t.Fatalf("got %q", got)
+	}
+	var nilDetector *Detector
+	nilDetector.Referred("x", routing.Target{})
+	nilDetector.Returned("x")
+	if nilDetector.Ejected(routing.Target{}) || nilDetector.ClientKey("u", "") != "" {
+		t.Fatalf("nil detector must be a no-op")
+	}
+}
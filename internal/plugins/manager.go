@@ -11,8 +11,9 @@ import (
 const pluginCallTimeout = 1 * time.Second
 
 type Manager struct {
-	plugins []Plugin
-	logger  *slog.Logger
+	plugins  []Plugin
+	logger   *slog.Logger
+	observer func(name string, d time.Duration, err error)
 }
 
 type ApplyResult struct {
@@ -23,13 +24,43 @@ type ApplyResult struct {
 	SelectedIndex   int
 	Backend         routing.Backend
 	ReferralContent []byte
+	QueuePriority   *int
+}
+
+// Step records one plugin call and the state after applying its response.
+type Step struct {
+	Plugin             string           `json:"plugin"`
+	Error              string           `json:"error,omitempty"`
+	Response           *ConnectResponse `json:"response,omitempty"`
+	Backend            routing.Backend  `json:"backend"`
+	SelectedIndex      int              `json:"selected_index"`
+	ReferralContentLen int              `json:"referral_content_len"`
 }
 
 func NewManager(logger *slog.Logger, plugins []Plugin) *Manager {
 	return &Manager{plugins: plugins, logger: logger}
 }
 
+// SetCallObserver registers a callback invoked after every plugin OnConnect call.
+func (m *Manager) SetCallObserver(fn func(name string, d time.Duration, err error)) {
+	if m == nil {
+		return
+	}
+	m.observer = fn
+}
+
 func (m *Manager) ApplyOnConnect(ctx context.Context, ev ConnectEvent, decision routing.Decision, referralContent []byte) ApplyResult {
+	return m.apply(ctx, ev, decision, referralContent, nil)
+}
+
+// ExplainOnConnect runs the plugin chain like ApplyOnConnect and returns every plugin's effect.
+func (m *Manager) ExplainOnConnect(ctx context.Context, ev ConnectEvent, decision routing.Decision, referralContent []byte) (ApplyResult, []Step) {
+	steps := []Step{}
+	res := m.apply(ctx, ev, decision, referralContent, &steps)
+	return res, steps
+}
+
+func (m *Manager) apply(ctx context.Context, ev ConnectEvent, decision routing.Decision, referralContent []byte, steps *[]Step) ApplyResult {
 	res := ApplyResult{
 		Strategy:        decision.Strategy,
 		Candidates:      decision.Candidates,
@@ -42,6 +73,7 @@ func (m *Manager) ApplyOnConnect(ctx context.Context, ev ConnectEvent, decision
 	}
 	for _, p := range m.plugins {
 		pctx, cancel := context.WithTimeout(ctx, pluginCallTimeout)
+		start := time.Now()
 		pr, err := p.OnConnect(pctx, ConnectRequest{
 			Event:           ev,
 			Strategy:        res.Strategy,
@@ -49,17 +81,27 @@ func (m *Manager) ApplyOnConnect(ctx context.Context, ev ConnectEvent, decision
 			SelectedIndex:   res.SelectedIndex,
 			Backend:         res.Backend,
 			ReferralContent: res.ReferralContent,
+			HostCaptures:    decision.Captures,
 		})
 		cancel()
+		if m.observer != nil {
+			m.observer(p.Name(), time.Since(start), err)
+		}
 		if err != nil {
 			if m.logger != nil {
 				m.logger.Info("plugin error", "plugin", p.Name(), "error", err)
 			}
+			if steps != nil {
+				*steps = append(*steps, Step{Plugin: p.Name(), Error: err.Error(), Backend: res.Backend, SelectedIndex: res.SelectedIndex, ReferralContentLen: len(res.ReferralContent)})
+			}
 			continue
 		}
 		if pr.Deny {
 			res.Denied = true
 			res.DenyReason = pr.DenyReason
+			if steps != nil {
+				*steps = append(*steps, Step{Plugin: p.Name(), Response: &pr, Backend: res.Backend, SelectedIndex: res.SelectedIndex, ReferralContentLen: len(res.ReferralContent)})
+			}
 			return res
 		}
 		if pr.Candidates != nil {
@@ -92,6 +134,12 @@ func (m *Manager) ApplyOnConnect(ctx context.Context, ev ConnectEvent, decision
 		if pr.ReferralContent != nil {
 			res.ReferralContent = pr.ReferralContent
 		}
+		if pr.QueuePriority != nil {
+			res.QueuePriority = pr.QueuePriority
+		}
+		if steps != nil {
+			*steps = append(*steps, Step{Plugin: p.Name(), Response: &pr, Backend: res.Backend, SelectedIndex: res.SelectedIndex, ReferralContentLen: len(res.ReferralContent)})
+		}
 	}
 	return res
 }
@@ -5,6 +5,7 @@ import (
 	"io"
 	"log/slog"
 	"testing"
+	"time"
 
 	"github.com/hybrowse/hyrouter/internal/routing"
 )
@@ -55,6 +56,25 @@ func TestManagerApplyOnConnect(t *testing.T) {
 	}
 }
 
+func TestManagerApplyOnConnect_QueuePriority(t *testing.T) {
+	high, low := 5, -1
+	m := NewManager(nil, []Plugin{
+		&testPlugin{name: "a", resp: ConnectResponse{QueuePriority: &high}},
+		&testPlugin{name: "b"},
+	})
+	out := m.ApplyOnConnect(context.Background(), ConnectEvent{}, routing.Decision{}, nil)
+	if out.QueuePriority == nil || *out.QueuePriority != 5 {
+		t.Fatalf("priority=%v", out.QueuePriority)
+	}
+	m = NewManager(nil, []Plugin{
+		&testPlugin{name: "a", resp: ConnectResponse{QueuePriority: &high}},
+		&testPlugin{name: "b", resp: ConnectResponse{QueuePriority: &low}},
+	})
+	if out := m.ApplyOnConnect(context.Background(), ConnectEvent{}, routing.Decision{}, nil); *out.QueuePriority != -1 {
+		t.Fatalf("priority=%d", *out.QueuePriority)
+	}
+}
+
 func TestManagerApplyOnConnect_Deny(t *testing.T) {
 	m := NewManager(nil, []Plugin{&testPlugin{name: "a", resp: ConnectResponse{Deny: true, DenyReason: "no"}}})
 	out := m.ApplyOnConnect(context.Background(), ConnectEvent{}, routing.Decision{}, nil)
@@ -80,3 +100,55 @@ func TestManagerClose(t *testing.T) {
 		t.Fatalf("expected closed")
 	}
 }
+
+func TestManagerApplyOnConnect_CallObserver(t *testing.T) {
+	m := NewManager(nil, []Plugin{
+		&testPlugin{name: "a"},
+		&testPlugin{name: "b", err: context.Canceled},
+	})
+	var names []string
+	var errs int
+	m.SetCallObserver(func(name string, d time.Duration, err error) {
+		names = append(names, name)
+		if err != nil {
+			errs++
+		}
+	})
+	m.ApplyOnConnect(context.Background(), ConnectEvent{}, routing.Decision{}, nil)
+	if len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Fatalf("names=%v", names)
+	}
+	if errs != 1 {
+		t.Fatalf("errs=%d", errs)
+	}
+}
+
+func TestManagerExplainOnConnect(t *testing.T) {
+	m := NewManager(nil, []Plugin{
+		&testPlugin{name: "a", resp: ConnectResponse{ReferralContent: []byte("xy")}},
+		&testPlugin{name: "b", err: context.DeadlineExceeded},
+		&testPlugin{name: "c", resp: ConnectResponse{Backend: &routing.Backend{Host: "h", Port: 1}}},
+		&testPlugin{name: "d", resp: ConnectResponse{Deny: true, DenyReason: "no"}},
+	})
+	out, steps := m.ExplainOnConnect(context.Background(), ConnectEvent{}, routing.Decision{Backend: routing.Backend{Host: "d", Port: 2}}, nil)
+	if !out.Denied || len(steps) != 4 {
+		t.Fatalf("out=%#v steps=%#v", out, steps)
+	}
+	if steps[0].ReferralContentLen != 2 || steps[0].Backend.Host != "d" {
+		t.Fatalf("step a=%#v", steps[0])
+	}
+	if steps[1].Error == "" || steps[1].Response != nil {
+		t.Fatalf("step b=%#v", steps[1])
+	}
+	if steps[2].Backend.Host != "h" || steps[2].Response.Backend == nil {
+		t.Fatalf("step c=%#v", steps[2])
+	}
+	if !steps[3].Response.Deny {
+		t.Fatalf("step d=%#v", steps[3])
+	}
+
+	var nilMgr *Manager
+	if _, steps := nilMgr.ExplainOnConnect(context.Background(), ConnectEvent{}, routing.Decision{}, nil); len(steps) != 0 {
+		t.Fatalf("steps=%#v", steps)
+	}
+}
@@ -24,6 +24,7 @@ type ConnectRequest struct {
 	SelectedIndex   int               `json:"selected_index"`
 	Backend         routing.Backend   `json:"backend"`
 	ReferralContent []byte            `json:"referral_content,omitempty"`
+	HostCaptures    map[string]string `json:"host_captures,omitempty"`
 }
 
 type ConnectResponse struct {
@@ -33,6 +34,8 @@ type ConnectResponse struct {
 	SelectedIndex   *int              `json:"selected_index,omitempty"`
 	Backend         *routing.Backend  `json:"backend,omitempty"`
 	ReferralContent []byte            `json:"referral_content,omitempty"`
+	// QueuePriority ranks the player in the waiting room if no backend has capacity (higher first).
+	QueuePriority *int `json:"queue_priority,omitempty"`
 }
 
 type Plugin interface {
@@ -105,3 +105,25 @@ func (p *wasmPlugin) Close(ctx context.Context) error {
 	}
 	return p.rt.Close(ctx)
 }
+
+// CheckWASM compiles the module at path and checks that it exports the functions hyrouter calls.
+func CheckWASM(ctx context.Context, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	rt := wazero.NewRuntime(ctx)
+	defer rt.Close(ctx) // nolint:errcheck
+
+	compiled, err := rt.CompileModule(ctx, b)
+	if err != nil {
+		return fmt.Errorf("compile: %w", err)
+	}
+	exports := compiled.ExportedFunctions()
+	for _, name := range []string{"alloc", "on_connect"} {
+		if _, ok := exports[name]; !ok {
+			return fmt.Errorf("missing export: %s", name)
+		}
+	}
+	return nil
+}
@@ -139,3 +139,37 @@ func TestWASMPlugin_CloseNilRuntime(t *testing.T) {
 		t.Fatalf("Close: %v", err)
 	}
 }
+
+func TestCheckWASM(t *testing.T) {
+	dir := t.TempDir()
+	header := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	full := append(append([]byte(nil), header...),
+		0x01, 0x04, 0x01, 0x60, 0x00, 0x00, // type section: () -> ()
+		0x03, 0x03, 0x02, 0x00, 0x00, // function section: two functions
+		0x07, 0x16, 0x02, // export section: two exports
+		0x05, 'a', 'l', 'l', 'o', 'c', 0x00, 0x00,
+		0x0a, 'o', 'n', '_', 'c', 'o', 'n', 'n', 'e', 'c', 't', 0x00, 0x01,
+		0x0a, 0x07, 0x02, 0x02, 0x00, 0x0b, 0x02, 0x00, 0x0b, // code section
+	)
+
+	write := func(name string, b []byte) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, b, 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		return p
+	}
+
+	if err := CheckWASM(context.Background(), write("ok.wasm", full)); err != nil {
+		t.Fatalf("CheckWASM: %v", err)
+	}
+	if err := CheckWASM(context.Background(), write("empty.wasm", header)); err == nil || err.Error() != "missing export: alloc" {
+		t.Fatalf("err=%v", err)
+	}
+	if err := CheckWASM(context.Background(), write("bad.wasm", []byte("nope"))); err == nil {
+		t.Fatalf("expected compile error")
+	}
+	if err := CheckWASM(context.Background(), filepath.Join(dir, "missing.wasm")); err == nil {
+		t.Fatalf("expected read error")
+	}
+}
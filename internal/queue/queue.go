@@ -0,0 +1,289 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+)
+
+const (
+	defaultMaxLength     = 1000
+	defaultMaxWait       = 5 * time.Minute
+	defaultRetryInterval = 2 * time.Second
+)
+
+var (
+	// ErrFull is returned by Join when max_length players are already waiting.
+	ErrFull = errors.New("queue full")
+	// ErrTimeout is returned by Wait when a player waited max_wait without getting a backend.
+	ErrTimeout = errors.New("queue wait timed out")
+	// ErrClosed is returned by Wait when the queue was disabled by a config reload.
+	ErrClosed = errors.New("queue closed")
+)
+
+// Queue holds players for whom no backend had capacity. Run retries them in queue order:
+// higher priority first, then first come, first served.
+// All methods are safe to call on a nil *Queue, which disables queueing.
+type Queue struct {
+	mu       sync.Mutex
+	settings settings
+	entries  []*Entry
+	closed   bool
+	onChange func(n int)
+}
+
+type settings struct {
+	maxLength     int
+	maxWait       time.Duration
+	retryInterval time.Duration
+	priority      map[string]struct{}
+}
+
+// Entry is a queued player.
+type Entry struct {
+	priority int
+	joined   time.Time
+	try      func() bool
+	done     chan struct{}
+	finished bool
+	err      error
+}
+
+func New(cfg *config.QueueConfig) (*Queue, error) {
+	st, err := parseSettings(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Queue{settings: st}, nil
+}
+
+func parseSettings(cfg *config.QueueConfig) (settings, error) {
+	if cfg == nil {
+		return settings{}, fmt.Errorf("queue must be set")
+	}
+	st := settings{
+		maxLength:     defaultMaxLength,
+		maxWait:       defaultMaxWait,
+		retryInterval: defaultRetryInterval,
+		priority:      map[string]struct{}{},
+	}
+	if cfg.MaxLength > 0 {
+		st.maxLength = cfg.MaxLength
+	}
+	if cfg.MaxWait != "" {
+		d, err := time.ParseDuration(cfg.MaxWait)
+		if err != nil {
+			return settings{}, fmt.Errorf("invalid queue.max_wait: %w", err)
+		}
+		st.maxWait = d
+	}
+	if cfg.RetryInterval != "" {
+		d, err := time.ParseDuration(cfg.RetryInterval)
+		if err != nil {
+			return settings{}, fmt.Errorf("invalid queue.retry_interval: %w", err)
+		}
+		st.retryInterval = d
+	}
+	for _, u := range cfg.PriorityUUIDs {
+		if u = strings.ToLower(strings.TrimSpace(u)); u != "" {
+			st.priority[u] = struct{}{}
+		}
+	}
+	return st, nil
+}
+
+// Update applies cfg to the queue while keeping the players that are already waiting.
+func (q *Queue) Update(cfg *config.QueueConfig) error {
+	if q == nil {
+		return nil
+	}
+	st, err := parseSettings(cfg)
+	if err != nil {
+		return err
+	}
+	q.mu.Lock()
+	q.settings = st
+	q.mu.Unlock()
+	return nil
+}
+
+// SetLengthObserver registers a callback invoked with the queue length whenever it changes.
+func (q *Queue) SetLengthObserver(fn func(n int)) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	q.onChange = fn
+	q.mu.Unlock()
+}
+
+// Priority returns the queue priority of a player: 1 for the configured priority UUIDs, else 0.
+func (q *Queue) Priority(uuid string) int {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.settings.priority[strings.ToLower(strings.TrimSpace(uuid))]; ok {
+		return 1
+	}
+	return 0
+}
+
+// Join queues a player. try is called by Run on every retry until it returns true; it must not
+// block for long, as all players are retried one after the other.
+func (q *Queue) Join(priority int, try func() bool) (*Entry, error) {
+	if q == nil {
+		return nil, ErrClosed
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil, ErrClosed
+	}
+	if len(q.entries) >= q.settings.maxLength {
+		return nil, ErrFull
+	}
+	e := &Entry{priority: priority, joined: time.Now(), try: try, done: make(chan struct{})}
+	i := len(q.entries)
+	for i > 0 && q.entries[i-1].priority < priority {
+		i--
+	}
+	q.entries = append(q.entries, nil)
+	copy(q.entries[i+1:], q.entries[i:])
+	q.entries[i] = e
+	q.changed()
+	return e, nil
+}
+
+// Waited returns how long e has been queued.
+func (e *Entry) Waited() time.Duration {
+	return time.Since(e.joined)
+}
+
+// Position returns the 1-based position of e in the queue, or 0 if it is no longer queued.
+func (q *Queue) Position(e *Entry) int {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, x := range q.entries {
+		if x == e {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Len returns the number of waiting players.
+func (q *Queue) Len() int {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries)
+}
+
+// Wait blocks until try succeeded for e (nil), max_wait passed (ErrTimeout), the queue was
+// closed (ErrClosed) or ctx is done. In every case e has left the queue on return.
+func (q *Queue) Wait(ctx context.Context, e *Entry) error {
+	q.mu.Lock()
+	remaining := q.settings.maxWait - time.Since(e.joined)
+	q.mu.Unlock()
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+	var err error
+	select {
+	case <-e.done:
+		return e.err
+	case <-timer.C:
+		err = ErrTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if !q.finish(e, err) {
+		// try succeeded concurrently.
+		return e.err
+	}
+	return err
+}
+
+// Run retries the queued players every retry_interval until ctx is done.
+func (q *Queue) Run(ctx context.Context) {
+	for {
+		q.mu.Lock()
+		interval := q.settings.retryInterval
+		q.mu.Unlock()
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		q.Retry()
+	}
+}
+
+// Retry gives every queued player one attempt, in queue order.
+func (q *Queue) Retry() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	entries := append([]*Entry(nil), q.entries...)
+	q.mu.Unlock()
+	for _, e := range entries {
+		if e.try() {
+			q.finish(e, nil)
+		}
+	}
+}
+
+// Close removes every player from the queue; their Wait returns ErrClosed.
+func (q *Queue) Close() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	q.closed = true
+	entries := append([]*Entry(nil), q.entries...)
+	q.mu.Unlock()
+	for _, e := range entries {
+		q.finish(e, ErrClosed)
+	}
+}
+
+// finish removes e and wakes its Wait. It returns false if e had already finished.
+func (q *Queue) finish(e *Entry, err error) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if e.finished {
+		return false
+	}
+	e.finished = true
+	e.err = err
+	for i, x := range q.entries {
+		if x == e {
+			q.entries = append(q.entries[:i], q.entries[i+1:]...)
+			break
+		}
+	}
+	close(e.done)
+	q.changed()
+	return true
+}
+
+// changed reports the queue length; q.mu must be held.
+func (q *Queue) changed() {
+	if q.onChange != nil {
+		q.onChange(len(q.entries))
+	}
+}
@@ -0,0 +1,116 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+)
+
+func TestQueueOrder(t *testing.T) {
+	q, err := New(&config.QueueConfig{MaxLength: 3, PriorityUUIDs: []string{"VIP"}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if q.Priority("vip") != 1 || q.Priority("other") != 0 || q.Priority("") != 0 {
+		t.Fatalf("unexpected priorities")
+	}
+	var order []string
+	capacity := 0
+	join := func(name string, priority int) *Entry {
+		e, err := q.Join(priority, func() bool {
+			if capacity == 0 {
+				return false
+			}
+			capacity--
+			order = append(order, name)
+			return true
+		})
+		if err != nil {
+			t.Fatalf("Join(%s): %v", name, err)
+		}
+		return e
+	}
+	first := join("first", 0)
+	second := join("second", 0)
+	vip := join("vip", 1)
+	if _, err := q.Join(0, func() bool { return true }); !errors.Is(err, ErrFull) {
+		t.Fatalf("expected ErrFull, got %v", err)
+	}
+	if q.Position(vip) != 1 || q.Position(first) != 2 || q.Position(second) != 3 {
+		t.Fatalf("positions: vip=%d first=%d second=%d", q.Position(vip), q.Position(first), q.Position(second))
+	}
+
+	q.Retry()
+	if q.Len() != 3 {
+		t.Fatalf("len=%d", q.Len())
+	}
+	capacity = 2
+	q.Retry()
+	if len(order) != 2 || order[0] != "vip" || order[1] != "first" {
+		t.Fatalf("order=%v", order)
+	}
+	if err := q.Wait(context.Background(), first); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if q.Len() != 1 || q.Position(second) != 1 {
+		t.Fatalf("len=%d position=%d", q.Len(), q.Position(second))
+	}
+}
+
+func TestQueueWaitEnds(t *testing.T) {
+	q, err := New(&config.QueueConfig{MaxWait: "10ms"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	lengths := []int{}
+	q.SetLengthObserver(func(n int) { lengths = append(lengths, n) })
+	never := func() bool { return false }
+
+	e, _ := q.Join(0, never)
+	if err := q.Wait(context.Background(), e); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+
+	if err := q.Update(&config.QueueConfig{MaxWait: "1m"}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e, _ = q.Join(0, never)
+	cancel()
+	if err := q.Wait(ctx, e); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	e, _ = q.Join(0, never)
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		q.Close()
+	}()
+	if err := q.Wait(context.Background(), e); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+	if _, err := q.Join(0, never); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+	if q.Len() != 0 || len(lengths) != 6 || lengths[5] != 0 {
+		t.Fatalf("len=%d lengths=%v", q.Len(), lengths)
+	}
+}
+
+func TestQueueNil(t *testing.T) {
+	var q *Queue
+	if _, err := q.Join(0, func() bool { return true }); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+	if q.Len() != 0 || q.Priority("x") != 0 {
+		t.Fatalf("unexpected nil queue state")
+	}
+	q.Retry()
+	q.Close()
+	if _, err := New(&config.QueueConfig{MaxWait: "soon"}); err == nil {
+		t.Fatalf("expected error")
+	}
+}
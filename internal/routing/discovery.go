@@ -7,11 +7,18 @@ import (
 	"strings"
 )
 
-func (e *StaticEngine) resolveCandidates(ctx context.Context, pool Pool) ([]Backend, error) {
+func (e *StaticEngine) resolveCandidates(ctx context.Context, pool Pool, req Request) ([]Backend, error) {
 	strategy := normalizeStrategy(pool.Strategy)
 	static := pool.Backends
+	if pool.Target != nil {
+		static = nil
+		if b, ok := templatedBackend(*pool.Target, req); ok {
+			static = []Backend{b}
+		}
+	}
 
 	if pool.Discovery == nil {
+		static = e.withRecentReferrals(e.withHealth(e.withoutExcluded(static)))
 		if len(static) == 0 {
 			return nil, fmt.Errorf("%w", ErrNoBackends)
 		}
@@ -27,6 +34,7 @@ func (e *StaticEngine) resolveCandidates(ctx context.Context, pool Pool) ([]Back
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrDiscovery, err)
 	}
+	disc = selectBackends(disc, pool.Discovery.Selector, req)
 
 	mode := normalizeStrategy(pool.Discovery.Mode)
 	if mode == "" {
@@ -46,7 +54,7 @@ func (e *StaticEngine) resolveCandidates(ctx context.Context, pool Pool) ([]Back
 		return nil, fmt.Errorf("%w %q", ErrInvalidDiscoveryMode, pool.Discovery.Mode)
 	}
 
-	merged = dedupeBackends(merged)
+	merged = e.withRecentReferrals(e.withHealth(e.withoutExcluded(dedupeBackends(merged))))
 	if strategy == "weighted" {
 		for i := range merged {
 			if merged[i].Weight <= 0 {
@@ -60,6 +68,65 @@ func (e *StaticEngine) resolveCandidates(ctx context.Context, pool Pool) ([]Back
 	return merged, nil
 }
 
+// selectBackends keeps the backends whose meta matches every selector entry.
+func selectBackends(in []Backend, selector map[string]string, req Request) []Backend {
+	if len(selector) == 0 {
+		return in
+	}
+	var out []Backend
+	for _, b := range in {
+		ok := true
+		for k, v := range selector {
+			want, _ := expandTemplate(v, req)
+			if metaGet(b.Meta, k) != want {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// templatedBackend computes the backend of a pool target for req. ok is false if a referenced
+// value is missing or the host is not a valid hostname.
+func templatedBackend(t TargetTemplate, req Request) (Backend, bool) {
+	host, ok := expandTemplate(t.Host, req)
+	if !ok || !validHostname(host) {
+		return Backend{}, false
+	}
+	return Backend{Host: host, Port: t.Port}, true
+}
+
+// validHostname reports whether s only contains letters, digits, dots and hyphens.
+func validHostname(s string) bool {
+	if s == "" || len(s) > 253 || strings.HasPrefix(s, ".") || strings.HasPrefix(s, "-") {
+		return false
+	}
+	for _, c := range s {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '.' && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *StaticEngine) withoutExcluded(in []Backend) []Backend {
+	if e.excluded == nil {
+		return in
+	}
+	var out []Backend
+	for _, b := range in {
+		if e.excluded(b.Target()) {
+			continue
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
 func dedupeBackends(in []Backend) []Backend {
 	seen := map[string]struct{}{}
 	out := make([]Backend, 0, len(in))
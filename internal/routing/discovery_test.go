@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 )
 
 func TestResolveCandidates_DiscoveryNotSet(t *testing.T) {
@@ -19,3 +20,109 @@ func TestResolveCandidates_DiscoveryNotSet(t *testing.T) {
 		t.Fatalf("expected ErrDiscoveryNotSet, got %v", err)
 	}
 }
+
+func TestResolveCandidates_ExcludedBackends(t *testing.T) {
+	e := NewStaticEngine(Config{Routes: []Route{{
+		Match: Match{Hostname: "x"},
+		Pool:  Pool{Strategy: "round_robin", Backends: []Backend{{Host: "a", Port: 1}}, Discovery: &Discovery{Provider: "p", Mode: "union"}},
+	}}, Default: &Pool{Strategy: "round_robin", Backends: []Backend{{Host: "a", Port: 1}}}})
+	e.SetDiscovery(func(ctx context.Context, provider string) ([]Backend, error) {
+		return []Backend{{Host: "d", Port: 1}}, nil
+	})
+	e.SetExcluded(func(t Target) bool { return t == Target{Host: "d", Port: 1} })
+
+	cands, err := e.Candidates(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Candidates: %v", err)
+	}
+	if len(cands) != 1 || cands[0].Host != "a" {
+		t.Fatalf("cands=%#v", cands)
+	}
+
+	e.SetExcluded(func(t Target) bool { return t.Host == "a" })
+	if _, err := e.Candidates(context.Background(), -1); !errors.Is(err, ErrNoBackends) {
+		t.Fatalf("expected ErrNoBackends, got %v", err)
+	}
+	if _, err := e.Candidates(context.Background(), 5); err == nil {
+		t.Fatalf("expected error for unknown route")
+	}
+}
+
+func TestWithDryRun(t *testing.T) {
+	if IsDryRun(context.Background()) {
+		t.Fatalf("expected no dry run")
+	}
+	if !IsDryRun(WithDryRun(context.Background())) {
+		t.Fatalf("expected dry run")
+	}
+}
+
+func TestResolveCandidates_Health(t *testing.T) {
+	e := NewStaticEngine(Config{Default: &Pool{Strategy: "round_robin", Backends: []Backend{
+		{Host: "a", Port: 1, Meta: map[string]string{"label.tier": "x"}},
+		{Host: "b", Port: 1},
+		{Host: "c", Port: 1},
+	}}})
+	states := map[Target]Health{
+		{Host: "a", Port: 1}: {Status: HealthHealthy, RTT: 1500 * time.Microsecond},
+		{Host: "b", Port: 1}: {Status: HealthUnhealthy},
+	}
+	e.SetHealth(func(t Target) (Health, bool) {
+		h, ok := states[t]
+		return h, ok
+	}, false)
+
+	cands, err := e.Candidates(context.Background(), -1)
+	if err != nil {
+		t.Fatalf("Candidates: %v", err)
+	}
+	if len(cands) != 2 || cands[0].Host != "a" || cands[1].Host != "c" {
+		t.Fatalf("cands=%#v", cands)
+	}
+	if cands[0].Meta["health.status"] != "healthy" || cands[0].Meta["health.rtt_ms"] != "1.500" || cands[0].Meta["label.tier"] != "x" {
+		t.Fatalf("meta=%#v", cands[0].Meta)
+	}
+	if cands[1].Meta["health.status"] != "unknown" {
+		t.Fatalf("meta=%#v", cands[1].Meta)
+	}
+	if _, ok := e.cfg.Default.Backends[0].Meta["health.status"]; ok {
+		t.Fatalf("config backend meta was mutated")
+	}
+
+	states[Target{Host: "a", Port: 1}] = Health{Status: HealthUnhealthy}
+	states[Target{Host: "c", Port: 1}] = Health{Status: HealthUnhealthy}
+	if _, err := e.Candidates(context.Background(), -1); !errors.Is(err, ErrNoBackends) {
+		t.Fatalf("expected ErrNoBackends, got %v", err)
+	}
+
+	e.SetHealth(func(t Target) (Health, bool) { return states[t], true }, true)
+	cands, err = e.Candidates(context.Background(), -1)
+	if err != nil || len(cands) != 3 {
+		t.Fatalf("fail_open: cands=%d err=%v", len(cands), err)
+	}
+}
+
+func TestStaticEngineTargets(t *testing.T) {
+	e := NewStaticEngine(Config{Routes: []Route{{
+		Match: Match{Hostname: "x"},
+		Pool:  Pool{Strategy: "round_robin", Backends: []Backend{{Host: "b", Port: 1}}, Discovery: &Discovery{Provider: "p"}},
+	}}, Default: &Pool{Strategy: "round_robin", Backends: []Backend{{Host: "a", Port: 1}, {Host: "b", Port: 1}}}})
+	e.SetDiscovery(func(ctx context.Context, provider string) ([]Backend, error) {
+		if !IsDryRun(ctx) {
+			t.Fatalf("expected dry-run context")
+		}
+		return []Backend{{Host: "d", Port: 2}}, nil
+	})
+	e.SetExcluded(func(t Target) bool { return true })
+
+	got := e.Targets(context.Background())
+	want := []Target{{Host: "a", Port: 1}, {Host: "b", Port: 1}, {Host: "d", Port: 2}}
+	if len(got) != len(want) {
+		t.Fatalf("got %v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v", got)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package routing
+
+import "context"
+
+type dryRunKey struct{}
+
+// WithDryRun marks ctx as a dry run (admin inspection, explain).
+// Discovery providers must not allocate and strategies must not advance shared state.
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+func IsDryRun(ctx context.Context) bool {
+	v, _ := ctx.Value(dryRunKey{}).(bool)
+	return v
+}
@@ -10,42 +10,101 @@ import (
 )
 
 func (e *StaticEngine) Decide(ctx context.Context, req Request) (Decision, error) {
-	sni := canonicalHost(req.SNI)
+	return e.decide(ctx, req, nil)
+}
 
-	for i, r := range e.cfg.Routes {
-		patterns := matchPatterns(r.Match)
-		for _, p := range patterns {
-			if hostnameMatches(p, sni) {
-				cands, err := e.resolveCandidates(ctx, r.Pool)
-				if err != nil {
-					return Decision{}, err
-				}
-				cands, idx, err := e.selectCandidates(req, r.Pool, cands, &e.rr[i])
-				if err != nil {
-					return Decision{}, err
-				}
-				b := Backend{}
-				if idx >= 0 && idx < len(cands) {
-					b = cands[idx]
+// Explain runs a dry-run decision and records every step in a Trace.
+// Round-robin counters are not advanced and discovery providers are asked not to allocate.
+func (e *StaticEngine) Explain(ctx context.Context, req Request) (Decision, *Trace, error) {
+	tr := &Trace{SNI: canonicalHost(req.SNI), RouteIndex: -1}
+	d, err := e.decide(WithDryRun(ctx), req, tr)
+	if err != nil {
+		tr.Error = err.Error()
+	}
+	return d, tr, err
+}
+
+func (e *StaticEngine) decide(ctx context.Context, req Request, tr *Trace) (Decision, error) {
+	if i, p, captures := e.matchRoute(req); i >= 0 {
+		r := e.cfg.Routes[i]
+		req.Captures = captures
+		pool, arm, rr, ok := e.routePool(i, req)
+		if tr != nil {
+			tr.Route = fmt.Sprintf("routing.routes[%d]", i)
+			tr.Matched = true
+			tr.RouteIndex = i
+			tr.Pattern = p
+			tr.Captures = captures
+			tr.SplitArm = arm
+		}
+		if e.inMaintenance(i) {
+			if tr != nil {
+				tr.Maintenance = true
+			}
+			return Decision{Matched: true, RouteIndex: i, SelectedIndex: -1, Strategy: normalizeStrategy(pool.Strategy), Captures: captures, SplitArm: arm}, fmt.Errorf("%w", ErrMaintenance)
+		}
+		if r.Maintenance != nil {
+			if active, end := e.scheduleActive(&r.Maintenance.Schedule); active {
+				if tr != nil {
+					tr.Maintenance = true
 				}
-				return Decision{
-					Backend:       b,
-					Candidates:    cands,
-					SelectedIndex: idx,
-					Strategy:      normalizeStrategy(r.Pool.Strategy),
-					Matched:       true,
-					RouteIndex:    i,
-				}, nil
+				return Decision{Matched: true, RouteIndex: i, SelectedIndex: -1, Strategy: normalizeStrategy(pool.Strategy), Captures: captures, SplitArm: arm}, &MaintenanceError{End: end, Message: r.Maintenance.Message}
 			}
 		}
+		if !ok {
+			return Decision{}, fmt.Errorf("%w: no split arm is within its schedule", ErrNoBackends)
+		}
+		if err := e.checkPoolSchedule(pool); err != nil {
+			return Decision{}, err
+		}
+		cands, err := e.resolveCandidates(ctx, pool, req)
+		if err != nil {
+			return Decision{}, err
+		}
+		cands, idx, err := e.selectCandidates(req, pool, cands, tr.counter(rr), tr)
+		if err != nil {
+			return Decision{}, err
+		}
+		b := Backend{}
+		if idx >= 0 && idx < len(cands) {
+			b = cands[idx]
+		}
+		var content []byte
+		if r.ReferralContent != "" {
+			c, _ := expandTemplate(r.ReferralContent, req)
+			content = []byte(c)
+		}
+		return Decision{
+			Backend:         b,
+			Candidates:      cands,
+			SelectedIndex:   idx,
+			Strategy:        normalizeStrategy(pool.Strategy),
+			Matched:         true,
+			RouteIndex:      i,
+			Captures:        captures,
+			ReferralContent: content,
+			SplitArm:        arm,
+		}, nil
 	}
 
 	if e.cfg.Default != nil {
-		cands, err := e.resolveCandidates(ctx, *e.cfg.Default)
+		if tr != nil {
+			tr.Route = "routing.default"
+		}
+		if e.inMaintenance(-1) {
+			if tr != nil {
+				tr.Maintenance = true
+			}
+			return Decision{Matched: false, RouteIndex: -1, SelectedIndex: -1, Strategy: normalizeStrategy(e.cfg.Default.Strategy)}, fmt.Errorf("%w", ErrMaintenance)
+		}
+		if err := e.checkPoolSchedule(*e.cfg.Default); err != nil {
+			return Decision{}, err
+		}
+		cands, err := e.resolveCandidates(ctx, *e.cfg.Default, req)
 		if err != nil {
 			return Decision{}, err
 		}
-		cands, idx, err := e.selectCandidates(req, *e.cfg.Default, cands, &e.rrDefault)
+		cands, idx, err := e.selectCandidates(req, *e.cfg.Default, cands, tr.counter(&e.rrDefault), tr)
 		if err != nil {
 			return Decision{}, err
 		}
@@ -66,24 +125,77 @@ func (e *StaticEngine) Decide(ctx context.Context, req Request) (Decision, error
 	return Decision{Matched: false, RouteIndex: -1, SelectedIndex: -1}, nil
 }
 
-func (e *StaticEngine) selectCandidates(req Request, pool Pool, backends []Backend, rr *atomic.Uint64) ([]Backend, int, error) {
-	strategy := normalizeStrategy(pool.Strategy)
+// Candidates resolves the candidate list of a route (-1 for the default route) without selecting a backend.
+// Without a connection there is no SNI, so discovery selectors are not applied and templated targets yield no candidates.
+func (e *StaticEngine) Candidates(ctx context.Context, routeIndex int) ([]Backend, error) {
+	if routeIndex == -1 {
+		if e.cfg.Default == nil {
+			return nil, fmt.Errorf("%w", ErrNoBackends)
+		}
+		return e.resolveCandidates(ctx, *e.cfg.Default, Request{})
+	}
+	if routeIndex < 0 || routeIndex >= len(e.cfg.Routes) {
+		return nil, fmt.Errorf("route index %d out of range", routeIndex)
+	}
+	// The candidates of a split route are those of all its arms.
+	var out []Backend
+	for _, pp := range e.cfg.Routes[routeIndex].Pools("") {
+		pool := pp.Pool
+		if pool.Discovery != nil {
+			d := *pool.Discovery
+			d.Selector = nil
+			pool.Discovery = &d
+		}
+		cands, err := e.resolveCandidates(ctx, pool, Request{})
+		if err != nil && !errors.Is(err, ErrNoBackends) {
+			return nil, err
+		}
+		out = append(out, cands...)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%w", ErrNoBackends)
+	}
+	return out, nil
+}
+
+// routePool returns the pool that handles req on route i, the name of its split arm and its round-robin counter.
+// ok is false if the route has a split and none of its arms is within its schedule.
+func (e *StaticEngine) routePool(i int, req Request) (Pool, string, *atomic.Uint64, bool) {
+	r := e.cfg.Routes[i]
+	if r.Split == nil || len(r.Split.Arms) == 0 {
+		return r.Pool, "", &e.rr[i], true
+	}
+	a := r.Split.arm(req, func(j int) bool {
+		ok, _ := e.scheduleActive(r.Split.Arms[j].Pool.Schedule)
+		return ok
+	})
+	if a < 0 {
+		return Pool{}, "", &e.rr[i], false
+	}
+	return r.Split.Arms[a].Pool, r.Split.Arms[a].Name, &e.rrSplit[i][a], true
+}
+
+func (e *StaticEngine) inMaintenance(routeIndex int) bool {
+	return e.maintenance != nil && e.maintenance(routeIndex)
+}
+
+func (e *StaticEngine) selectCandidates(req Request, pool Pool, backends []Backend, rr *atomic.Uint64, tr *Trace) ([]Backend, int, error) {
+	tr.setCandidates(backends)
 	if len(backends) == 0 {
 		return nil, -1, fmt.Errorf("%w", ErrNoBackends)
 	}
 	base := selectionConfigFromPool(pool)
-	selected, idx, err := e.selectWithConfig(req, base, backends, rr)
+	selected, idx, err := e.selectWithConfig(req, base, backends, rr, tr, "pool")
 	if err == nil {
 		return selected, idx, nil
 	}
 	if !errors.Is(err, ErrNoBackends) {
 		return nil, -1, err
 	}
-	_ = strategy
-	for _, fb := range pool.Fallback {
+	for i, fb := range pool.Fallback {
 		cfg := base
 		mergeFallback(&cfg, fb)
-		selected, idx, err = e.selectWithConfig(req, cfg, backends, rr)
+		selected, idx, err = e.selectWithConfig(req, cfg, backends, rr, tr, fmt.Sprintf("fallback[%d]", i))
 		if err == nil {
 			return selected, idx, nil
 		}
@@ -95,22 +207,28 @@ func (e *StaticEngine) selectCandidates(req Request, pool Pool, backends []Backe
 }
 
 type selectionConfig struct {
-	Strategy string
-	Key      string
-	Sample   int
-	Sort     []SortKey
-	Limit    int
-	Filters  []Filter
+	Strategy     string
+	Key          string
+	Sample       int
+	VirtualNodes int
+	Utilization  *Utilization
+	CountRecent  bool
+	Sort         []SortKey
+	Limit        int
+	Filters      []Filter
 }
 
 func selectionConfigFromPool(p Pool) selectionConfig {
 	return selectionConfig{
-		Strategy: normalizeStrategy(p.Strategy),
-		Key:      strings.TrimSpace(p.Key),
-		Sample:   p.Sample,
-		Sort:     p.Sort,
-		Limit:    p.Limit,
-		Filters:  p.Filters,
+		Strategy:     normalizeStrategy(p.Strategy),
+		Key:          strings.TrimSpace(p.Key),
+		Sample:       p.Sample,
+		VirtualNodes: p.VirtualNodes,
+		Utilization:  p.Utilization,
+		CountRecent:  p.CountRecentReferrals,
+		Sort:         p.Sort,
+		Limit:        p.Limit,
+		Filters:      p.Filters,
 	}
 }
 
@@ -127,6 +245,15 @@ func mergeFallback(dst *selectionConfig, fb Fallback) {
 	if fb.Sample != nil {
 		dst.Sample = *fb.Sample
 	}
+	if fb.VirtualNodes != nil {
+		dst.VirtualNodes = *fb.VirtualNodes
+	}
+	if fb.Utilization != nil {
+		dst.Utilization = fb.Utilization
+	}
+	if fb.CountRecentReferrals != nil {
+		dst.CountRecent = *fb.CountRecentReferrals
+	}
 	if fb.Limit != nil {
 		dst.Limit = *fb.Limit
 	}
@@ -138,23 +265,28 @@ func mergeFallback(dst *selectionConfig, fb Fallback) {
 	}
 }
 
-func (e *StaticEngine) selectWithConfig(req Request, cfg selectionConfig, backends []Backend, rr *atomic.Uint64) ([]Backend, int, error) {
-	filtered := applyFilters(req, backends, cfg.Filters)
+func (e *StaticEngine) selectWithConfig(req Request, cfg selectionConfig, backends []Backend, rr *atomic.Uint64, tr *Trace, step string) ([]Backend, int, error) {
+	at := tr.beginAttempt(step, cfg)
+	at.filters(req, backends, cfg.Filters, e.filterMatches)
+	filtered := e.applyFilters(req, backends, cfg.Filters)
 	if len(filtered) == 0 {
-		return nil, -1, fmt.Errorf("%w", ErrNoBackends)
+		return nil, -1, at.fail(fmt.Errorf("%w", ErrNoBackends))
 	}
-	applySort(filtered, cfg.Sort)
+	e.applySort(req, filtered, cfg.Sort)
+	at.sorted(filtered)
 	if cfg.Limit > 0 && len(filtered) > cfg.Limit {
+		at.truncated(filtered[cfg.Limit:])
 		filtered = filtered[:cfg.Limit]
 	}
-	idx, err := e.selectIndex(cfg, filtered, rr)
+	idx, err := e.selectIndex(req, cfg, filtered, rr)
 	if err != nil {
-		return nil, -1, err
+		return nil, -1, at.fail(err)
 	}
+	at.selected(idx)
 	return filtered, idx, nil
 }
 
-func (e *StaticEngine) selectIndex(cfg selectionConfig, backends []Backend, rr *atomic.Uint64) (int, error) {
+func (e *StaticEngine) selectIndex(req Request, cfg selectionConfig, backends []Backend, rr *atomic.Uint64) (int, error) {
 	if len(backends) == 0 {
 		return -1, fmt.Errorf("%w", ErrNoBackends)
 	}
@@ -202,7 +334,7 @@ func (e *StaticEngine) selectIndex(cfg selectionConfig, backends []Backend, rr *
 		bestIdx := -1
 		best := math.Inf(1)
 		for i, b := range backends {
-			_, ok, n := sortValue(b, key, "number")
+			n, ok := loadOf(b, key, cfg.CountRecent)
 			if !ok {
 				continue
 			}
@@ -215,6 +347,8 @@ func (e *StaticEngine) selectIndex(cfg selectionConfig, backends []Backend, rr *
 			return bestIdx, nil
 		}
 		return 0, nil
+	case "least_utilization":
+		return leastUtilizationIndex(backends, cfg.Key, cfg.Utilization, cfg.CountRecent, rr), nil
 	case "p2c":
 		key := strings.TrimSpace(cfg.Key)
 		if key == "" {
@@ -241,7 +375,7 @@ func (e *StaticEngine) selectIndex(cfg selectionConfig, backends []Backend, rr *
 				continue
 			}
 			chosen[idx] = struct{}{}
-			_, ok, n := sortValue(backends[idx], key, "number")
+			n, ok := loadOf(backends[idx], key, cfg.CountRecent)
 			if !ok {
 				n = math.Inf(1)
 			}
@@ -254,6 +388,19 @@ func (e *StaticEngine) selectIndex(cfg selectionConfig, backends []Backend, rr *
 			return bestIdx, nil
 		}
 		return 0, nil
+	case "consistent_hash", "rendezvous":
+		value := hashKeyValue(req, cfg.Key)
+		if value == "" {
+			// Without a key (e.g. an undecodable Connect) there is nothing to stick to.
+			e.rngMu.Lock()
+			idx := e.rng.Intn(len(backends))
+			e.rngMu.Unlock()
+			return idx, nil
+		}
+		if cfg.Strategy == "rendezvous" {
+			return rendezvousIndex(value, backends), nil
+		}
+		return e.consistentHashIndex(value, backends, cfg.VirtualNodes), nil
 	default:
 		return -1, fmt.Errorf("%w %q", ErrUnknownStrategy, cfg.Strategy)
 	}
@@ -9,4 +9,5 @@ var (
 	ErrDiscovery            = errors.New("discovery error")
 	ErrDiscoveryNotSet      = errors.New("discovery resolver not set")
 	ErrInvalidDiscoveryMode = errors.New("invalid discovery mode")
+	ErrMaintenance          = errors.New("route in maintenance")
 )
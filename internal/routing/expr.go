@@ -0,0 +1,182 @@
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+)
+
+// exprCostLimit bounds the work of a single evaluation, so a filter cannot stall routing.
+const exprCostLimit = 10000
+
+// exprProgram is a compiled CEL expression of an expr filter or sort key.
+type exprProgram struct {
+	prg    cel.Program
+	number bool // the result is numeric (sort keys only)
+}
+
+// exprKey identifies a compiled expression by its trimmed source and kind.
+type exprKey struct {
+	src     string
+	sortKey bool
+}
+
+var (
+	exprEnvOnce sync.Once
+	exprEnv     *cel.Env
+	exprEnvErr  error
+)
+
+func celEnv() (*cel.Env, error) {
+	exprEnvOnce.Do(func() {
+		exprEnv, exprEnvErr = cel.NewEnv(
+			cel.Variable("backend.host", cel.StringType),
+			cel.Variable("backend.port", cel.IntType),
+			cel.Variable("backend.weight", cel.IntType),
+			cel.Variable("backend.meta", cel.MapType(cel.StringType, cel.StringType)),
+			cel.Variable("request.uuid", cel.StringType),
+			cel.Variable("request.username", cel.StringType),
+			cel.Variable("request.language", cel.StringType),
+			cel.Variable("request.sni", cel.StringType),
+			cel.Variable("request.client_ip", cel.StringType),
+			cel.Variable("now", cel.TimestampType),
+		)
+	})
+	return exprEnv, exprEnvErr
+}
+
+// compileExpr compiles src as a filter (which must return a bool) or, if sortKey is set, as a
+// sort key (which must return a number or a string).
+func compileExpr(src string, sortKey bool) (*exprProgram, error) {
+	src = strings.TrimSpace(src)
+	if src == "" {
+		return nil, errors.New("must not be empty")
+	}
+	env, err := celEnv()
+	if err != nil {
+		return nil, err
+	}
+	ast, iss := env.Compile(src)
+	if iss.Err() != nil {
+		return nil, iss.Err()
+	}
+	out := ast.OutputType()
+	p := &exprProgram{}
+	switch {
+	case !sortKey:
+		if !out.IsExactType(cel.BoolType) {
+			return nil, fmt.Errorf("must return a bool, not %s", out)
+		}
+	case out.IsExactType(cel.IntType) || out.IsExactType(cel.UintType) || out.IsExactType(cel.DoubleType):
+		p.number = true
+	case out.IsExactType(cel.StringType):
+	default:
+		return nil, fmt.Errorf("must return a number or a string, not %s", out)
+	}
+	p.prg, err = env.Program(ast, cel.CostLimit(exprCostLimit))
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func exprVars(req Request, b Backend) map[string]any {
+	meta := b.Meta
+	if meta == nil {
+		meta = map[string]string{}
+	}
+	return map[string]any{
+		"backend.host":      b.Host,
+		"backend.port":      int64(b.Port),
+		"backend.weight":    int64(b.Weight),
+		"backend.meta":      meta,
+		"request.uuid":      req.UUID,
+		"request.username":  req.Username,
+		"request.language":  req.Language,
+		"request.sni":       canonicalHost(req.SNI),
+		"request.client_ip": req.ClientIP,
+		"now":               time.Now(),
+	}
+}
+
+// exprFilterMatches evaluates a filter expression. Invalid expressions and evaluation errors
+// (e.g. a missing meta key) reject the backend.
+func (e *StaticEngine) exprFilterMatches(req Request, b Backend, src string) bool {
+	p := e.exprs[exprKey{src: strings.TrimSpace(src)}]
+	if p == nil {
+		return false
+	}
+	out, _, err := p.prg.Eval(exprVars(req, b))
+	if err != nil {
+		return false
+	}
+	v, ok := out.Value().(bool)
+	return ok && v
+}
+
+// exprSortValue evaluates a sort expression like sortValue. ok is false if evaluation fails.
+func (e *StaticEngine) exprSortValue(req Request, b Backend, src string) (string, bool, float64, bool) {
+	p := e.exprs[exprKey{src: strings.TrimSpace(src), sortKey: true}]
+	if p == nil {
+		return "", false, 0, false
+	}
+	out, _, err := p.prg.Eval(exprVars(req, b))
+	if err != nil {
+		return "", false, 0, p.number
+	}
+	switch v := out.(type) {
+	case types.Int:
+		return "", true, float64(v), true
+	case types.Uint:
+		return "", true, float64(v), true
+	case types.Double:
+		return "", true, float64(v), true
+	case types.String:
+		return string(v), true, 0, false
+	}
+	return "", false, 0, p.number
+}
+
+// compileExprs compiles every expression of cfg, including those nested in all, any and not
+// groups. Invalid expressions are skipped; Validate reports them.
+func compileExprs(cfg Config) map[exprKey]*exprProgram {
+	out := map[exprKey]*exprProgram{}
+	add := func(src string, sortKey bool) {
+		k := exprKey{src: strings.TrimSpace(src), sortKey: sortKey}
+		if _, ok := out[k]; ok || k.src == "" {
+			return
+		}
+		if p, err := compileExpr(k.src, sortKey); err == nil {
+			out[k] = p
+		}
+	}
+	addSort := func(p Pool) {
+		for _, k := range p.Sort {
+			add(k.Expr, true)
+		}
+		for _, fb := range p.Fallback {
+			for _, k := range fb.Sort {
+				add(k.Expr, true)
+			}
+		}
+	}
+	if cfg.Default != nil {
+		addSort(*cfg.Default)
+	}
+	for _, r := range cfg.Routes {
+		for _, pp := range r.Pools("") {
+			addSort(pp.Pool)
+		}
+	}
+	for _, f := range configFilters(cfg) {
+		if normalizeStrategy(f.Type) == "expr" {
+			add(f.Expr, false)
+		}
+	}
+	return out
+}
@@ -0,0 +1,83 @@
+package routing
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestExprFilter(t *testing.T) {
+	req := Request{UUID: "u1", Language: "de-DE", SNI: "Play.Example.com."}
+	b := Backend{Host: "a", Port: 5520, Weight: 2, Meta: map[string]string{"label.region": "eu", "counter.players": "12"}}
+	cases := []struct {
+		expr string
+		want bool
+	}{
+		{expr: `backend.meta["label.region"] == "eu" && backend.port == 5520`, want: true},
+		{expr: `int(backend.meta["counter.players"]) < 10`, want: false},
+		{expr: `request.language.startsWith("de") && request.sni == "play.example.com"`, want: true},
+		{expr: `"label.tier" in backend.meta && backend.meta["label.tier"] == "vip"`, want: false},
+		// A missing key is an evaluation error and rejects the backend.
+		{expr: `backend.meta["label.tier"] == "vip"`, want: false},
+		{expr: `backend.weight > 1 && now > timestamp("2020-01-01T00:00:00Z")`, want: true},
+	}
+	for _, tc := range cases {
+		if got := matchFilter(req, b, Filter{Type: "expr", Expr: tc.expr}); got != tc.want {
+			t.Fatalf("%s: got %v want %v", tc.expr, got, tc.want)
+		}
+	}
+
+	// Expressions nested in filter groups are compiled with the engine too.
+	nested := Filter{Type: "any", Filters: []Filter{
+		{Type: "exists", Key: "label.tier"},
+		{Type: "all", Filters: []Filter{{Type: "expr", Expr: `backend.meta["label.region"] == "eu"`}}},
+	}}
+	if !matchFilter(req, b, nested) {
+		t.Fatalf("expected nested expr filter to match")
+	}
+	// An expression the engine did not compile rejects the backend.
+	if NewStaticEngine(Config{}).filterMatches(req, b, Filter{Type: "expr", Expr: `true`}) {
+		t.Fatalf("expected uncompiled expr to be rejected")
+	}
+}
+
+func TestExprSort(t *testing.T) {
+	e := NewStaticEngine(Config{Default: &Pool{
+		Strategy: "round_robin",
+		Sort:     []SortKey{{Expr: `int(backend.meta["counter.players"]) * backend.weight`, Order: "desc"}},
+		Limit:    1,
+		Backends: []Backend{
+			{Host: "a", Port: 1, Weight: 1, Meta: map[string]string{"counter.players": "30"}},
+			{Host: "b", Port: 1, Weight: 3, Meta: map[string]string{"counter.players": "20"}},
+			{Host: "c", Port: 1, Weight: 1},
+		},
+	}})
+	d, err := e.Decide(context.Background(), Request{})
+	if err != nil || d.Backend.Host != "b" {
+		t.Fatalf("decision=%+v err=%v", d, err)
+	}
+}
+
+func TestValidateExpr(t *testing.T) {
+	cfg := Config{Default: &Pool{
+		Strategy: "round_robin",
+		Backends: []Backend{{Host: "a", Port: 1}},
+		Filters: []Filter{
+			{Type: "expr", Expr: `backend.port + "x"`},
+			{Type: "expr", Expr: `backend.host`},
+			{Type: "expr", Expr: `request.nope == ""`},
+		},
+		Sort: []SortKey{{Expr: `backend.port > 1`}},
+	}}
+	err := cfg.Validate()
+	for _, want := range []string{
+		"routing.default.filters[0]: expr:",
+		"routing.default.filters[1]: expr: must return a bool, not string",
+		"routing.default.filters[2]: expr:",
+		"routing.default.sort[0].expr: must return a number or a string, not bool",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in %v", want, err)
+		}
+	}
+}
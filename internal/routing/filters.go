@@ -2,12 +2,13 @@ package routing
 
 import (
 	"encoding/json"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 )
 
-func applyFilters(req Request, backends []Backend, filters []Filter) []Backend {
+func (e *StaticEngine) applyFilters(req Request, backends []Backend, filters []Filter) []Backend {
 	if len(filters) == 0 {
 		out := make([]Backend, len(backends))
 		copy(out, backends)
@@ -17,7 +18,7 @@ func applyFilters(req Request, backends []Backend, filters []Filter) []Backend {
 	for _, b := range backends {
 		ok := true
 		for _, f := range filters {
-			if !filterMatches(req, b, f) {
+			if !e.filterMatches(req, b, f) {
 				ok = false
 				break
 			}
@@ -29,34 +30,86 @@ func applyFilters(req Request, backends []Backend, filters []Filter) []Backend {
 	return out
 }
 
-func filterMatches(req Request, b Backend, f Filter) bool {
+func (e *StaticEngine) filterMatches(req Request, b Backend, f Filter) bool {
 	t := normalizeStrategy(f.Type)
 	switch t {
 	case "compare":
-		leftRaw, lok, ln := sortValue(b, f.Left, "number")
-		_ = leftRaw
-		rightRaw, rok, rn := sortValue(b, f.Right, "number")
-		_ = rightRaw
+		_, lok, ln := sortValue(b, f.Left, "number")
+		rok, rn := false, 0.0
+		if strings.TrimSpace(f.Right) != "" {
+			_, rok, rn = sortValue(b, f.Right, "number")
+		} else {
+			n, err := strconv.ParseFloat(strings.TrimSpace(f.Value), 64)
+			rok, rn = err == nil, n
+		}
 		if !lok || !rok {
 			return false
 		}
+		return compareNumbers(ln, normalizeStrategy(f.Op), rn)
+	case "exists", "not_exists":
+		_, ok, _ := sortValue(b, f.Key, "")
+		return ok == (t == "exists")
+	case "string", "equals":
+		v, ok, _ := sortValue(b, f.Key, "")
+		if !ok {
+			return false
+		}
 		op := normalizeStrategy(f.Op)
-		switch op {
-		case "lt":
-			return ln < rn
-		case "lte":
-			return ln <= rn
-		case "gt":
-			return ln > rn
-		case "gte":
-			return ln >= rn
-		case "eq":
-			return ln == rn
-		case "neq":
-			return ln != rn
+		if t == "equals" {
+			// equals predates the string filter and is kept as an alias of op: eq.
+			op = "eq"
+		}
+		return e.stringMatches(req, v, op, f)
+	case "headroom":
+		_, cok, count := sortValue(b, defaultString(f.Left, "counter:players.count"), "number")
+		_, capOK, capacity := sortValue(b, defaultString(f.Right, "counter:players.capacity"), "number")
+		min, err := strconv.ParseFloat(defaultString(f.Value, "0"), 64)
+		if !cok || !capOK || err != nil {
+			return false
+		}
+		return count < capacity-min
+	case "blacklist":
+		if enabledKey := strings.TrimSpace(f.EnabledKey); enabledKey != "" {
+			v := strings.ToLower(strings.TrimSpace(metaGet(b.Meta, enabledKey)))
+			if v != "true" && v != "1" && v != "yes" {
+				return true
+			}
+		}
+		want := ""
+		switch normalizeStrategy(f.Subject) {
+		case "", "uuid":
+			want = req.UUID
+		case "username":
+			want = req.Username
 		default:
 			return false
 		}
+		want = strings.TrimSpace(want)
+		if want == "" {
+			return true
+		}
+		return !listContains(metaGet(b.Meta, strings.TrimSpace(f.ListKey)), want)
+	case "all":
+		for _, sub := range f.Filters {
+			if !e.filterMatches(req, b, sub) {
+				return false
+			}
+		}
+		return true
+	case "any":
+		for _, sub := range f.Filters {
+			if e.filterMatches(req, b, sub) {
+				return true
+			}
+		}
+		return false
+	case "not":
+		for _, sub := range f.Filters {
+			if !e.filterMatches(req, b, sub) {
+				return true
+			}
+		}
+		return false
 	case "whitelist":
 		enabledKey := strings.TrimSpace(f.EnabledKey)
 		if enabledKey == "" {
@@ -93,6 +146,8 @@ func filterMatches(req Request, b Backend, f Filter) bool {
 			return false
 		}
 		return listContains(raw, want)
+	case "expr":
+		return e.exprFilterMatches(req, b, f.Expr)
 	case "game_start_not_past":
 		key := strings.TrimSpace(f.Key)
 		if key == "" {
@@ -120,6 +175,110 @@ func filterMatches(req Request, b Backend, f Filter) bool {
 	}
 }
 
+func compareNumbers(l float64, op string, r float64) bool {
+	switch op {
+	case "lt":
+		return l < r
+	case "lte":
+		return l <= r
+	case "gt":
+		return l > r
+	case "gte":
+		return l >= r
+	case "eq":
+		return l == r
+	case "neq":
+		return l != r
+	default:
+		return false
+	}
+}
+
+// stringMatches applies a string filter operator to v. Except for regex, value and values may
+// use ${sni} and ${host.<name>}; a template that cannot be expanded rejects the backend.
+func (e *StaticEngine) stringMatches(req Request, v string, op string, f Filter) bool {
+	switch op {
+	case "regex":
+		re := e.regexes[f.Value]
+		return re != nil && re.MatchString(v)
+	case "in":
+		for _, x := range f.Values {
+			if want, ok := expandTemplate(x, req); ok && v == want {
+				return true
+			}
+		}
+		return false
+	}
+	want, ok := expandTemplate(f.Value, req)
+	if !ok {
+		return false
+	}
+	switch op {
+	case "eq":
+		return v == want
+	case "neq":
+		return v != want
+	case "prefix":
+		return strings.HasPrefix(v, want)
+	case "suffix":
+		return strings.HasSuffix(v, want)
+	default:
+		return false
+	}
+}
+
+// configFilters returns every filter of cfg, including the members of all, any and not groups.
+func configFilters(cfg Config) []Filter {
+	var out []Filter
+	var add func(fs []Filter)
+	add = func(fs []Filter) {
+		for _, f := range fs {
+			out = append(out, f)
+			add(f.Filters)
+		}
+	}
+	addPool := func(p Pool) {
+		add(p.Filters)
+		for _, fb := range p.Fallback {
+			add(fb.Filters)
+		}
+	}
+	if cfg.Default != nil {
+		addPool(*cfg.Default)
+	}
+	for _, r := range cfg.Routes {
+		for _, pp := range r.Pools("") {
+			addPool(pp.Pool)
+		}
+	}
+	return out
+}
+
+// compileRegexes compiles the patterns of every regex string filter of cfg. Invalid patterns
+// are skipped; Validate reports them.
+func compileRegexes(cfg Config) map[string]*regexp.Regexp {
+	out := map[string]*regexp.Regexp{}
+	for _, f := range configFilters(cfg) {
+		if normalizeStrategy(f.Type) != "string" || normalizeStrategy(f.Op) != "regex" {
+			continue
+		}
+		if _, ok := out[f.Value]; ok {
+			continue
+		}
+		if re, err := regexp.Compile(f.Value); err == nil {
+			out[f.Value] = re
+		}
+	}
+	return out
+}
+
+func defaultString(s string, def string) string {
+	if s = strings.TrimSpace(s); s != "" {
+		return s
+	}
+	return def
+}
+
 func listContains(raw string, want string) bool {
 	raw = strings.TrimSpace(raw)
 	if raw == "" {
@@ -3,10 +3,18 @@ package routing
 import (
 	"context"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
 
+// matchFilter evaluates f with an engine whose config contains f, so its expressions and
+// patterns are compiled like they are for routing.
+func matchFilter(req Request, b Backend, f Filter) bool {
+	e := NewStaticEngine(Config{Default: &Pool{Filters: []Filter{f}}})
+	return e.filterMatches(req, b, f)
+}
+
 func TestFilters_Whitelist(t *testing.T) {
 	b := Backend{Host: "a", Port: 1, Meta: map[string]string{
 		"annotation.agones.dev/sdk-whitelist-enabled": "true",
@@ -15,10 +23,10 @@ func TestFilters_Whitelist(t *testing.T) {
 
 	f := Filter{Type: "whitelist", EnabledKey: "annotation.agones.dev/sdk-whitelist-enabled", ListKey: "list.whitelistedPlayers.values"}
 
-	if !filterMatches(Request{UUID: "u1"}, b, f) {
+	if !matchFilter(Request{UUID: "u1"}, b, f) {
 		t.Fatalf("expected whitelisted user to match")
 	}
-	if filterMatches(Request{UUID: "u3"}, b, f) {
+	if matchFilter(Request{UUID: "u3"}, b, f) {
 		t.Fatalf("expected non-whitelisted user to be rejected")
 	}
 }
@@ -31,10 +39,10 @@ func TestFilters_Whitelist_SubjectUsername(t *testing.T) {
 
 	f := Filter{Type: "whitelist", Subject: "username", EnabledKey: "annotation.agones.dev/sdk-whitelist-enabled", ListKey: "list.whitelistedPlayers.values"}
 
-	if !filterMatches(Request{Username: "alice"}, b, f) {
+	if !matchFilter(Request{Username: "alice"}, b, f) {
 		t.Fatalf("expected whitelisted username to match")
 	}
-	if filterMatches(Request{Username: "carol"}, b, f) {
+	if matchFilter(Request{Username: "carol"}, b, f) {
 		t.Fatalf("expected non-whitelisted username to be rejected")
 	}
 }
@@ -44,12 +52,12 @@ func TestFilters_GameStartNotPast(t *testing.T) {
 
 	bFuture := Backend{Host: "a", Port: 1, Meta: map[string]string{"annotation.agones.dev/sdk-game-start": ""}}
 	f := Filter{Type: "game_start_not_past", Key: "annotation.agones.dev/sdk-game-start"}
-	if !filterMatches(Request{}, bFuture, f) {
+	if !matchFilter(Request{}, bFuture, f) {
 		t.Fatalf("expected empty game start to pass")
 	}
 
 	bPast := Backend{Host: "b", Port: 2, Meta: map[string]string{"annotation.agones.dev/sdk-game-start": strconv.FormatInt(now-1000, 10)}}
-	if filterMatches(Request{}, bPast, f) {
+	if matchFilter(Request{}, bPast, f) {
 		t.Fatalf("expected past game start to be rejected")
 	}
 }
@@ -73,3 +81,91 @@ func TestEngine_FallbackWhenFiltersEliminateAll(t *testing.T) {
 		t.Fatalf("expected fallback to allow backend, got %#v", dec.Backend)
 	}
 }
+
+func TestFilters_Operators(t *testing.T) {
+	b := Backend{Host: "eu-1.internal", Port: 5520, Meta: map[string]string{
+		"label.region":             "eu-west",
+		"counter.players.count":    "90",
+		"counter.players.capacity": "100",
+		"list.banned.values":       "u1,u2",
+	}}
+	req := Request{UUID: "u3"}
+	cases := []struct {
+		name string
+		f    Filter
+		want bool
+	}{
+		{name: "exists", f: Filter{Type: "exists", Key: "label:region"}, want: true},
+		{name: "not_exists", f: Filter{Type: "not_exists", Key: "label.tier"}, want: true},
+		{name: "string eq", f: Filter{Type: "string", Key: "label.region", Op: "eq", Value: "eu-west"}, want: true},
+		{name: "string neq", f: Filter{Type: "string", Key: "label.region", Op: "neq", Value: "eu-west"}, want: false},
+		{name: "string in", f: Filter{Type: "string", Key: "label.region", Op: "in", Values: []string{"us-east", "eu-west"}}, want: true},
+		{name: "string prefix", f: Filter{Type: "string", Key: "host", Op: "prefix", Value: "eu-"}, want: true},
+		{name: "string regex", f: Filter{Type: "string", Key: "label.region", Op: "regex", Value: `^us-`}, want: false},
+		{name: "string regex nested", f: Filter{Type: "all", Filters: []Filter{
+			{Type: "string", Key: "label.region", Op: "regex", Value: `^eu-(west|east)$`},
+		}}, want: true},
+		{name: "string missing key", f: Filter{Type: "string", Key: "label.tier", Op: "neq", Value: "x"}, want: false},
+		{name: "compare literal", f: Filter{Type: "compare", Left: "counter:players.count", Op: "lt", Value: "95"}, want: true},
+		{name: "headroom", f: Filter{Type: "headroom", Value: "10"}, want: false},
+		{name: "headroom small", f: Filter{Type: "headroom", Value: "5"}, want: true},
+		{name: "blacklist", f: Filter{Type: "blacklist", ListKey: "list.banned.values"}, want: true},
+		{name: "any", f: Filter{Type: "any", Filters: []Filter{
+			{Type: "exists", Key: "label.tier"},
+			{Type: "string", Key: "label.region", Op: "prefix", Value: "eu"},
+		}}, want: true},
+		{name: "not", f: Filter{Type: "not", Filters: []Filter{{Type: "exists", Key: "label.region"}}}, want: false},
+	}
+	for _, tc := range cases {
+		if got := matchFilter(req, b, tc.f); got != tc.want {
+			t.Fatalf("%s: got %v want %v", tc.name, got, tc.want)
+		}
+	}
+	if matchFilter(Request{UUID: "u2"}, b, Filter{Type: "blacklist", ListKey: "list.banned.values"}) {
+		t.Fatalf("expected blacklisted user to be rejected")
+	}
+}
+
+func TestFilters_StringTemplates(t *testing.T) {
+	b := Backend{Host: "a", Port: 1, Meta: map[string]string{"label.tenant": "acme", "label.sni": "acme.play.example.com"}}
+	req := Request{SNI: "ACME.play.example.com.", Captures: map[string]string{"tenant": "acme"}}
+	cases := []struct {
+		name string
+		req  Request
+		f    Filter
+		want bool
+	}{
+		{name: "eq capture", req: req, f: Filter{Type: "string", Key: "label.tenant", Op: "eq", Value: "${host.tenant}"}, want: true},
+		{name: "in sni", req: req, f: Filter{Type: "string", Key: "label.sni", Op: "in", Values: []string{"x", "${sni}"}}, want: true},
+		{name: "prefix capture", req: req, f: Filter{Type: "string", Key: "label.sni", Op: "prefix", Value: "${host.tenant}."}, want: true},
+		{name: "missing capture", req: Request{}, f: Filter{Type: "string", Key: "label.tenant", Op: "neq", Value: "${host.tenant}"}, want: false},
+		{name: "equals alias", req: req, f: Filter{Type: "equals", Key: "label.tenant", Value: "${host.tenant}"}, want: true},
+	}
+	for _, tc := range cases {
+		if got := matchFilter(tc.req, b, tc.f); got != tc.want {
+			t.Fatalf("%s: got %v want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestValidateFilter_Operators(t *testing.T) {
+	cases := []struct {
+		f    Filter
+		want string
+	}{
+		{f: Filter{Type: "compare", Left: "port", Op: "lt"}, want: "right or value must be set"},
+		{f: Filter{Type: "compare", Left: "port", Op: "lt", Value: "x"}, want: "value must be a number"},
+		{f: Filter{Type: "string", Key: "host", Op: "like"}, want: "op must be one of"},
+		{f: Filter{Type: "string", Key: "host", Op: "in"}, want: "values must not be empty"},
+		{f: Filter{Type: "string", Key: "host", Op: "regex", Value: "("}, want: "invalid regex"},
+		{f: Filter{Type: "blacklist"}, want: "list_key must not be empty"},
+		{f: Filter{Type: "not"}, want: "filters must not be empty"},
+		{f: Filter{Type: "any", Filters: []Filter{{Type: "exists"}}}, want: "filters[0]: key must not be empty"},
+	}
+	for _, tc := range cases {
+		err := validateFilter(tc.f)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("validateFilter(%+v)=%v want %q", tc.f, err, tc.want)
+		}
+	}
+}
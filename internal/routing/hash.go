@@ -0,0 +1,217 @@
+package routing
+
+import (
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultVirtualNodes is the number of ring points per unit of backend weight for consistent_hash.
+const defaultVirtualNodes = 100
+
+// maxRingPoints bounds the size of a consistent_hash ring. Discovery passes weights through
+// unchanged and they may be large, so large weights are scaled down to fit.
+const maxRingPoints = 100_000
+
+// maxCachedRings bounds the ring cache. Discovery churn creates new candidate sets, so the cache is
+// simply reset once it grows past this size.
+const maxCachedRings = 64
+
+// hashKeyValue returns the request field that a hash strategy is keyed on.
+func hashKeyValue(req Request, key string) string {
+	switch normalizeStrategy(key) {
+	case "", "uuid":
+		return req.UUID
+	case "username":
+		return req.Username
+	case "sni":
+		return canonicalHost(req.SNI)
+	case "client_ip":
+		return req.ClientIP
+	default:
+		return ""
+	}
+}
+
+func isHashStrategy(strategy string) bool {
+	switch normalizeStrategy(strategy) {
+	case "consistent_hash", "rendezvous":
+		return true
+	}
+	return false
+}
+
+func validHashKey(key string) bool {
+	switch normalizeStrategy(key) {
+	case "", "uuid", "username", "sni", "client_ip":
+		return true
+	}
+	return false
+}
+
+func hash64(parts ...string) uint64 {
+	h := fnv.New64a()
+	for i, p := range parts {
+		if i > 0 {
+			_, _ = h.Write([]byte{0})
+		}
+		_, _ = h.Write([]byte(p))
+	}
+	// FNV alone clusters similar inputs (e.g. "a:1#1", "a:1#2"); finish with the splitmix64 mixer.
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+func targetKey(b Backend) string {
+	return b.Host + ":" + strconv.Itoa(b.Port)
+}
+
+func backendWeight(b Backend) int {
+	if b.Weight <= 0 {
+		return 1
+	}
+	return b.Weight
+}
+
+// rendezvousIndex picks the backend with the highest weighted score for value
+// (weighted rendezvous hashing: score = weight / -ln(u), u uniform in (0,1)).
+func rendezvousIndex(value string, backends []Backend) int {
+	bestIdx := -1
+	best := math.Inf(-1)
+	for i, b := range backends {
+		u := (float64(hash64(value, targetKey(b))>>11) + 0.5) / (1 << 53)
+		score := float64(backendWeight(b)) / -math.Log(u)
+		if score > best {
+			best = score
+			bestIdx = i
+		}
+	}
+	return bestIdx
+}
+
+type ringPoint struct {
+	hash   uint64
+	target string
+}
+
+type hashRing struct {
+	points []ringPoint
+}
+
+func newHashRing(backends []Backend, virtualNodes int) *hashRing {
+	if virtualNodes <= 0 {
+		virtualNodes = defaultVirtualNodes
+	}
+	r := &hashRing{}
+	counts := ringPointCounts(backends, virtualNodes)
+	for i, b := range backends {
+		t := targetKey(b)
+		for v := 0; v < counts[i]; v++ {
+			r.points = append(r.points, ringPoint{hash: hash64(t, strconv.Itoa(v)), target: t})
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool {
+		if r.points[i].hash != r.points[j].hash {
+			return r.points[i].hash < r.points[j].hash
+		}
+		return r.points[i].target < r.points[j].target
+	})
+	return r
+}
+
+// ringPointCounts returns the number of ring points of each backend: virtualNodes per unit of
+// weight, after dividing the weights by their greatest common divisor. If that exceeds
+// maxRingPoints, the counts are scaled down proportionally, keeping at least one point each.
+func ringPointCounts(backends []Backend, virtualNodes int) []int {
+	g := 0
+	for _, b := range backends {
+		g = gcd(g, backendWeight(b))
+	}
+	counts := make([]int, len(backends))
+	total := 0
+	for i, b := range backends {
+		counts[i] = virtualNodes * (backendWeight(b) / g)
+		total += counts[i]
+	}
+	if total > maxRingPoints {
+		scale := float64(maxRingPoints) / float64(total)
+		for i := range counts {
+			counts[i] = max(1, int(float64(counts[i])*scale))
+		}
+	}
+	return counts
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// lookup returns the target owning the first ring point at or after the hash of value.
+func (r *hashRing) lookup(value string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	h := hash64(value)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].target
+}
+
+// ringCache reuses rings across decisions while the candidate set stays the same.
+type ringCache struct {
+	mu    sync.Mutex
+	rings map[string]*hashRing
+}
+
+func (c *ringCache) get(backends []Backend, virtualNodes int) *hashRing {
+	id := ringID(backends, virtualNodes)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r, ok := c.rings[id]; ok {
+		return r
+	}
+	if c.rings == nil || len(c.rings) >= maxCachedRings {
+		c.rings = map[string]*hashRing{}
+	}
+	r := newHashRing(backends, virtualNodes)
+	c.rings[id] = r
+	return r
+}
+
+func ringID(backends []Backend, virtualNodes int) string {
+	keys := make([]string, 0, len(backends))
+	for _, b := range backends {
+		keys = append(keys, targetKey(b)+"*"+strconv.Itoa(backendWeight(b)))
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	sb.WriteString(strconv.Itoa(virtualNodes))
+	for _, k := range keys {
+		sb.WriteByte('|')
+		sb.WriteString(k)
+	}
+	return sb.String()
+}
+
+func (e *StaticEngine) consistentHashIndex(value string, backends []Backend, virtualNodes int) int {
+	target := e.rings.get(backends, virtualNodes).lookup(value)
+	for i, b := range backends {
+		if targetKey(b) == target {
+			return i
+		}
+	}
+	return 0
+}
@@ -0,0 +1,102 @@
+package routing
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Health states reported as the health.status meta key.
+const (
+	HealthUnknown   = "unknown"
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+)
+
+// Health is the result of active health checking for one backend.
+type Health struct {
+	Status string
+	RTT    time.Duration
+}
+
+// SetHealth registers the active health check state. Candidates are annotated with
+// health.status and health.rtt_ms, and unhealthy ones are dropped unless every candidate is
+// unhealthy and failOpen is set.
+func (e *StaticEngine) SetHealth(fn func(t Target) (Health, bool), failOpen bool) {
+	e.health = fn
+	e.healthFailOpen = failOpen
+}
+
+func (e *StaticEngine) withHealth(in []Backend) []Backend {
+	if e.health == nil || len(in) == 0 {
+		return in
+	}
+	all := make([]Backend, 0, len(in))
+	healthy := make([]Backend, 0, len(in))
+	for _, b := range in {
+		h, ok := e.health(b.Target())
+		if !ok || h.Status == "" {
+			h = Health{Status: HealthUnknown}
+		}
+		meta := make(map[string]string, len(b.Meta)+2)
+		for k, v := range b.Meta {
+			meta[k] = v
+		}
+		meta["health.status"] = h.Status
+		if h.RTT > 0 {
+			meta["health.rtt_ms"] = strconv.FormatFloat(float64(h.RTT)/float64(time.Millisecond), 'f', 3, 64)
+		}
+		b.Meta = meta
+		all = append(all, b)
+		if h.Status != HealthUnhealthy {
+			healthy = append(healthy, b)
+		}
+	}
+	if len(healthy) == 0 && e.healthFailOpen {
+		return all
+	}
+	return healthy
+}
+
+// Targets returns every backend known to the routes (static and discovered), without
+// excluding drained or unhealthy ones. Discovery is resolved in dry-run mode.
+func (e *StaticEngine) Targets(ctx context.Context) []Target {
+	ctx = WithDryRun(ctx)
+	seen := map[Target]struct{}{}
+	add := func(p Pool) {
+		for _, b := range p.Backends {
+			seen[b.Target()] = struct{}{}
+		}
+		if p.Discovery == nil || e.discovery == nil {
+			return
+		}
+		disc, err := e.discovery(ctx, strings.TrimSpace(p.Discovery.Provider))
+		if err != nil {
+			return
+		}
+		for _, b := range disc {
+			seen[b.Target()] = struct{}{}
+		}
+	}
+	if e.cfg.Default != nil {
+		add(*e.cfg.Default)
+	}
+	for _, r := range e.cfg.Routes {
+		for _, pp := range r.Pools("") {
+			add(pp.Pool)
+		}
+	}
+	out := make([]Target, 0, len(seen))
+	for t := range seen {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Host != out[j].Host {
+			return out[i].Host < out[j].Host
+		}
+		return out[i].Port < out[j].Port
+	})
+	return out
+}
@@ -0,0 +1,77 @@
+package routing
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultRouteKey identifies routing.default in RouteKeys and StaticEngine.RouteKey.
+const DefaultRouteKey = "default"
+
+// RouteKeys returns a stable identity for each route: its match conditions in canonical form.
+// Unlike the index, the key survives reloads that insert, remove or reorder other routes, so
+// state attached to a route (maintenance, round-robin positions) stays with it. Routes with
+// identical match conditions are told apart by their order among themselves.
+func RouteKeys(routes []Route) []string {
+	keys := make([]string, len(routes))
+	seen := map[string]int{}
+	for i, r := range routes {
+		k := canonicalMatchKey(r.Match)
+		if n := seen[k]; n > 0 {
+			keys[i] = k + "#" + strconv.Itoa(n)
+		} else {
+			keys[i] = k
+		}
+		seen[k]++
+	}
+	return keys
+}
+
+func canonicalMatchKey(m Match) string {
+	m = canonicalMatch(m)
+	b, err := json.Marshal(m)
+	if err != nil {
+		return ""
+	}
+	return "match:" + string(b)
+}
+
+// canonicalMatch folds hostname case and the order of hostname lists, which do not change
+// what a route matches.
+func canonicalMatch(m Match) Match {
+	hosts := make([]string, 0, len(m.Hostnames)+1)
+	if m.Hostname != "" {
+		hosts = append(hosts, strings.ToLower(m.Hostname))
+	}
+	for _, h := range m.Hostnames {
+		hosts = append(hosts, strings.ToLower(h))
+	}
+	slices.Sort(hosts)
+	m.Hostname = ""
+	m.Hostnames = slices.Compact(hosts)
+	m.HostnameRegexes = slices.Sorted(slices.Values(m.HostnameRegexes))
+	m.All = canonicalMatches(m.All)
+	m.Any = canonicalMatches(m.Any)
+	return m
+}
+
+func canonicalMatches(ms []Match) []Match {
+	if len(ms) == 0 {
+		return nil
+	}
+	out := make([]Match, len(ms))
+	for i, m := range ms {
+		out[i] = canonicalMatch(m)
+	}
+	return out
+}
+
+// RouteKey returns the identity of route i as computed by RouteKeys; -1 is the default route.
+func (e *StaticEngine) RouteKey(routeIndex int) string {
+	if routeIndex < 0 || routeIndex >= len(e.keys) {
+		return DefaultRouteKey
+	}
+	return e.keys[routeIndex]
+}
@@ -0,0 +1,265 @@
+package routing
+
+import (
+	"net/netip"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hybrowse/hyrouter/internal/access"
+)
+
+// hostSet is the precompiled form of the hostname conditions of a Match.
+// Exact hostnames and "*.suffix" patterns are hash lookups; other glob patterns and regexes are scanned.
+type hostSet struct {
+	exact    map[string]string // canonical hostname -> pattern
+	suffixes map[string]string // ".example.com" -> pattern
+	globs    []string
+	regexes  []hostRegex
+}
+
+type hostRegex struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// compileHostRegex anchors pattern so it must match the whole hostname.
+func compileHostRegex(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile(`^(?:` + pattern + `)$`)
+}
+
+// suffixPattern returns ".example.com" for "*.example.com", or false if p is not a plain suffix pattern.
+func suffixPattern(p string) (string, bool) {
+	if !strings.HasPrefix(p, "*.") {
+		return "", false
+	}
+	rest := p[1:]
+	if strings.ContainsAny(rest, `*?[\`) {
+		return "", false
+	}
+	return rest, true
+}
+
+// newHostSet compiles the hostname conditions of m. It returns nil if m has none.
+// Invalid regexes are skipped; Validate reports them.
+func newHostSet(m Match) *hostSet {
+	patterns := matchPatterns(m)
+	regexes := hostRegexPatterns(m)
+	if len(patterns) == 0 && len(regexes) == 0 {
+		return nil
+	}
+	h := &hostSet{exact: map[string]string{}, suffixes: map[string]string{}}
+	for _, p := range patterns {
+		c := canonicalHost(p)
+		if c == "" {
+			continue
+		}
+		if !strings.ContainsAny(c, `*?[\`) {
+			if _, ok := h.exact[c]; !ok {
+				h.exact[c] = p
+			}
+			continue
+		}
+		if s, ok := suffixPattern(c); ok {
+			if _, ok := h.suffixes[s]; !ok {
+				h.suffixes[s] = p
+			}
+			continue
+		}
+		h.globs = append(h.globs, p)
+	}
+	for _, p := range regexes {
+		re, err := compileHostRegex(p)
+		if err != nil {
+			continue
+		}
+		h.regexes = append(h.regexes, hostRegex{pattern: p, re: re})
+	}
+	return h
+}
+
+func hostRegexPatterns(m Match) []string {
+	if len(m.HostnameRegexes) > 0 {
+		return m.HostnameRegexes
+	}
+	if m.HostnameRegex != "" {
+		return []string{m.HostnameRegex}
+	}
+	return nil
+}
+
+// indexable reports whether every hostname condition is an exact or suffix lookup.
+func (h *hostSet) indexable() bool {
+	return h != nil && len(h.globs) == 0 && len(h.regexes) == 0
+}
+
+// match returns the pattern that matched the canonical hostname host and, for regexes, the named capture groups.
+func (h *hostSet) match(host string) (string, map[string]string, bool) {
+	if host == "" {
+		return "", nil, false
+	}
+	if p, ok := h.exact[host]; ok {
+		return p, nil, true
+	}
+	for i := 0; i < len(host); i++ {
+		if host[i] != '.' {
+			continue
+		}
+		if p, ok := h.suffixes[host[i:]]; ok {
+			return p, nil, true
+		}
+	}
+	for _, p := range h.globs {
+		if hostnameMatches(p, host) {
+			return p, nil, true
+		}
+	}
+	for _, r := range h.regexes {
+		sub := r.re.FindStringSubmatch(host)
+		if sub == nil {
+			continue
+		}
+		var captures map[string]string
+		for i, name := range r.re.SubexpNames() {
+			if i == 0 || name == "" {
+				continue
+			}
+			if captures == nil {
+				captures = map[string]string{}
+			}
+			captures[name] = sub[i]
+		}
+		return r.pattern, captures, true
+	}
+	return "", nil, false
+}
+
+// compiledMatch is a Match with precompiled hostname and source conditions, including those of nested matches.
+type compiledMatch struct {
+	Match
+	hosts   *hostSet
+	sources []netip.Prefix
+	all     []compiledMatch
+	any     []compiledMatch
+}
+
+func compileMatch(m Match) compiledMatch {
+	cm := compiledMatch{Match: m, hosts: newHostSet(m)}
+	// Invalid prefixes are skipped; Validate reports them.
+	for _, s := range m.SourceCIDRs {
+		if p, err := access.ParsePrefix(s); err == nil {
+			cm.sources = append(cm.sources, p)
+		}
+	}
+	for _, sub := range m.All {
+		cm.all = append(cm.all, compileMatch(sub))
+	}
+	for _, sub := range m.Any {
+		cm.any = append(cm.any, compileMatch(sub))
+	}
+	return cm
+}
+
+// routeIndex finds the routes that may match a hostname without scanning every route.
+// Routes whose top-level hostnames are all exact or suffix patterns are indexed by them;
+// all other routes are always candidates.
+type routeIndex struct {
+	routes []compiledMatch
+	rank   []int // evaluation position of each route
+	exact  map[string][]int
+	suffix map[string][]int
+	scan   []int
+}
+
+// newRouteIndex compiles routes. Routes are evaluated by descending priority, then in config order.
+func newRouteIndex(routes []Route) *routeIndex {
+	x := &routeIndex{
+		routes: make([]compiledMatch, len(routes)),
+		rank:   make([]int, len(routes)),
+		exact:  map[string][]int{},
+		suffix: map[string][]int{},
+	}
+	order := make([]int, len(routes))
+	for i := range routes {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return routes[order[a]].Priority > routes[order[b]].Priority
+	})
+	for pos, i := range order {
+		x.rank[i] = pos
+	}
+	for _, i := range order {
+		cm := compileMatch(routes[i].Match)
+		x.routes[i] = cm
+		if !cm.hosts.indexable() {
+			x.scan = append(x.scan, i)
+			continue
+		}
+		for h := range cm.hosts.exact {
+			x.exact[h] = append(x.exact[h], i)
+		}
+		for s := range cm.hosts.suffixes {
+			x.suffix[s] = append(x.suffix[s], i)
+		}
+	}
+	return x
+}
+
+// candidates returns the routes that may match the canonical hostname host, in evaluation order.
+func (x *routeIndex) candidates(host string) []int {
+	out := append([]int(nil), x.scan...)
+	out = append(out, x.exact[host]...)
+	for i := 0; i < len(host); i++ {
+		if host[i] == '.' {
+			out = append(out, x.suffix[host[i:]]...)
+		}
+	}
+	sort.Slice(out, func(a, b int) bool { return x.rank[out[a]] < x.rank[out[b]] })
+	// A route can be found through several suffixes.
+	uniq := out[:0]
+	for i, r := range out {
+		if i == 0 || r != out[i-1] {
+			uniq = append(uniq, r)
+		}
+	}
+	return uniq
+}
+
+// expandTemplate replaces ${sni} with the canonical SNI of req and ${host.<name>} with a hostname capture.
+// ok is false if a referenced value is empty.
+func expandTemplate(s string, req Request) (string, bool) {
+	if !strings.Contains(s, "${") {
+		return s, true
+	}
+	ok := true
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), ok
+		}
+		j := strings.IndexByte(s[i:], '}')
+		if j < 0 {
+			b.WriteString(s)
+			return b.String(), ok
+		}
+		b.WriteString(s[:i])
+		name := s[i+2 : i+j]
+		v := ""
+		switch {
+		case name == "sni":
+			v = canonicalHost(req.SNI)
+		case strings.HasPrefix(name, "host."):
+			v = req.Captures[strings.TrimPrefix(name, "host.")]
+		default:
+			v = s[i : i+j+1]
+		}
+		if v == "" {
+			ok = false
+		}
+		b.WriteString(v)
+		s = s[i+j+1:]
+	}
+}
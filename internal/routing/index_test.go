@@ -0,0 +1,169 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestHostSetMatch(t *testing.T) {
+	h := newHostSet(Match{Hostnames: []string{"Play.Example.com", "*.example.com", "a?.test.com"}})
+	cases := []struct {
+		host    string
+		pattern string
+		ok      bool
+	}{
+		{host: "play.example.com", pattern: "Play.Example.com", ok: true},
+		{host: "x.y.example.com", pattern: "*.example.com", ok: true},
+		{host: "example.com", ok: false},
+		{host: "ab.test.com", pattern: "a?.test.com", ok: true},
+		{host: "abc.test.com", ok: false},
+	}
+	for _, tc := range cases {
+		p, _, ok := h.match(tc.host)
+		if ok != tc.ok || p != tc.pattern {
+			t.Fatalf("match(%q)=%q,%v want %q,%v", tc.host, p, ok, tc.pattern, tc.ok)
+		}
+	}
+	if h.indexable() {
+		t.Fatalf("expected glob pattern to make the set non-indexable")
+	}
+}
+
+func TestHostSetRegexCaptures(t *testing.T) {
+	h := newHostSet(Match{HostnameRegex: `(?P<tenant>[a-z0-9]+)\.play\.example\.com`})
+	p, captures, ok := h.match("acme42.play.example.com")
+	if !ok || p == "" || captures["tenant"] != "acme42" {
+		t.Fatalf("match=%q,%v,%v", p, captures, ok)
+	}
+	if _, _, ok := h.match("x.acme42.play.example.com.evil.com"); ok {
+		t.Fatalf("expected regex to be anchored")
+	}
+}
+
+func TestRouteIndexCandidatesAndPriority(t *testing.T) {
+	routes := []Route{
+		{Match: Match{Hostname: "*.example.com"}},
+		{Match: Match{Hostname: "vip.example.com"}, Priority: 10},
+		{Match: Match{Hostname: "other.com"}},
+		{Match: Match{Languages: []string{"de"}}},
+	}
+	x := newRouteIndex(routes)
+	if got := x.candidates("vip.example.com"); !reflect.DeepEqual(got, []int{1, 0, 3}) {
+		t.Fatalf("candidates=%v", got)
+	}
+	if got := x.candidates("nothing.net"); !reflect.DeepEqual(got, []int{3}) {
+		t.Fatalf("candidates=%v", got)
+	}
+}
+
+func TestStaticEngineDecide_ManyExactHostnames(t *testing.T) {
+	var routes []Route
+	for i := 0; i < 5000; i++ {
+		routes = append(routes, Route{
+			Match: Match{Hostname: fmt.Sprintf("tenant%d.play.example.com", i)},
+			Pool:  Pool{Strategy: "round_robin", Backends: []Backend{{Host: fmt.Sprintf("b%d", i), Port: 1}}},
+		})
+	}
+	e := NewStaticEngine(Config{Routes: routes})
+	d, err := e.Decide(context.Background(), Request{SNI: "TENANT4321.play.example.com."})
+	if err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+	if d.RouteIndex != 4321 || d.Backend.Host != "b4321" {
+		t.Fatalf("decision=%+v", d)
+	}
+}
+
+func TestStaticEngineDecide_CapturesInFiltersSelectorsAndReferral(t *testing.T) {
+	e := NewStaticEngine(Config{Routes: []Route{{
+		Match:           Match{HostnameRegex: `(?P<tenant>[a-z0-9]+)\.play\.example\.com`},
+		ReferralContent: "tenant=${host.tenant}",
+		Pool: Pool{
+			Strategy:  "round_robin",
+			Discovery: &Discovery{Provider: "p", Selector: map[string]string{"label.tenant": "${host.tenant}"}},
+			Filters:   []Filter{{Type: "equals", Key: "label.region", Value: "eu"}},
+		},
+	}}})
+	e.SetDiscovery(func(ctx context.Context, provider string) ([]Backend, error) {
+		return []Backend{
+			{Host: "acme-us", Port: 1, Meta: map[string]string{"label.tenant": "acme", "label.region": "us"}},
+			{Host: "acme-eu", Port: 1, Meta: map[string]string{"label.tenant": "acme", "label.region": "eu"}},
+			{Host: "other-eu", Port: 1, Meta: map[string]string{"label.tenant": "other", "label.region": "eu"}},
+		}, nil
+	})
+	d, err := e.Decide(context.Background(), Request{SNI: "acme.play.example.com"})
+	if err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+	if d.Backend.Host != "acme-eu" || len(d.Candidates) != 1 {
+		t.Fatalf("decision=%+v", d)
+	}
+	if d.Captures["tenant"] != "acme" || string(d.ReferralContent) != "tenant=acme" {
+		t.Fatalf("captures=%v referral=%q", d.Captures, d.ReferralContent)
+	}
+
+	cands, err := e.Candidates(context.Background(), 0)
+	if err != nil || len(cands) != 3 {
+		t.Fatalf("Candidates=%v err=%v", cands, err)
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	req := Request{SNI: "Acme.Play.Example.com.", Captures: map[string]string{"tenant": "acme"}}
+	got, ok := expandTemplate("${sni}|${host.tenant}|${other}|${host.tenant", req)
+	if !ok || got != "acme.play.example.com|acme|${other}|${host.tenant" {
+		t.Fatalf("got %q,%v", got, ok)
+	}
+	if got, ok := expandTemplate("${host.missing}.svc", req); ok || got != ".svc" {
+		t.Fatalf("got %q,%v", got, ok)
+	}
+}
+
+func TestStaticEngineDecide_TargetTemplate(t *testing.T) {
+	e := NewStaticEngine(Config{Routes: []Route{{
+		Match: Match{HostnameRegex: `(?P<tenant>[a-z0-9-]+)\.play\.example\.com`},
+		Pool:  Pool{Strategy: "round_robin", Target: &TargetTemplate{Host: "${host.tenant}.svc.cluster.local", Port: 5520}},
+	}, {
+		Match: Match{Hostname: "*.raw.example.com"},
+		Pool:  Pool{Strategy: "round_robin", Target: &TargetTemplate{Host: "${sni}", Port: 5521}},
+	}}})
+	d, err := e.Decide(context.Background(), Request{SNI: "acme.play.example.com"})
+	if err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+	if d.Backend.Host != "acme.svc.cluster.local" || d.Backend.Port != 5520 {
+		t.Fatalf("decision=%+v", d)
+	}
+	if _, err := e.Decide(context.Background(), Request{SNI: "a_b.raw.example.com"}); !errors.Is(err, ErrNoBackends) {
+		t.Fatalf("expected ErrNoBackends for invalid host, got %v", err)
+	}
+	if _, err := e.Candidates(context.Background(), 0); !errors.Is(err, ErrNoBackends) {
+		t.Fatalf("expected ErrNoBackends without SNI, got %v", err)
+	}
+}
+
+func TestStaticEngineDecide_SelectorBySNI(t *testing.T) {
+	e := NewStaticEngine(Config{Routes: []Route{{
+		Match: Match{Hostname: "*.play.example.com"},
+		Pool: Pool{
+			Strategy:  "round_robin",
+			Discovery: &Discovery{Provider: "p", Selector: map[string]string{"label.hyrouter/hostname": "${sni}"}},
+		},
+	}}})
+	e.SetDiscovery(func(ctx context.Context, provider string) ([]Backend, error) {
+		return []Backend{
+			{Host: "a", Port: 1, Meta: map[string]string{"label.hyrouter/hostname": "a.play.example.com"}},
+			{Host: "b", Port: 1, Meta: map[string]string{"label.hyrouter/hostname": "b.play.example.com"}},
+		}, nil
+	})
+	d, err := e.Decide(context.Background(), Request{SNI: "B.play.example.com"})
+	if err != nil || d.Backend.Host != "b" {
+		t.Fatalf("decision=%+v err=%v", d, err)
+	}
+	if _, err := e.Decide(context.Background(), Request{SNI: "c.play.example.com"}); err == nil {
+		t.Fatalf("expected error for unknown hostname")
+	}
+}
@@ -0,0 +1,119 @@
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// metaPrefixes are the backend meta namespaces filled by discovery providers.
+var metaPrefixes = []string{"label.", "annotation.", "counter.", "list.", "k8s.", "gameserver.", "health.", "dns.", "consul.", "hyrouter."}
+
+// sortKeyPrefixes are the shorthand prefixes accepted by sortValue.
+var sortKeyPrefixes = []string{"label:", "annotation:", "counter:"}
+
+// LintKeys reports strategy, sort and filter keys that neither use a known meta prefix
+// nor appear in the meta of a static backend of the same pool. Such keys usually contain a typo.
+func (c *Config) LintKeys() error {
+	if c == nil {
+		return nil
+	}
+	var errs []error
+	if c.Default != nil {
+		errs = append(errs, lintPoolKeys("routing.default", *c.Default)...)
+	}
+	for i, r := range c.Routes {
+		for _, pp := range r.Pools(fmt.Sprintf("routing.routes[%d]", i)) {
+			errs = append(errs, lintPoolKeys(pp.Path, pp.Pool)...)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func lintPoolKeys(path string, p Pool) []error {
+	static := map[string]struct{}{}
+	for _, b := range p.Backends {
+		for k := range b.Meta {
+			static[k] = struct{}{}
+		}
+	}
+	var errs []error
+	checkValue := func(field string, key string) {
+		if key = strings.TrimSpace(key); key != "" && !knownValueKey(key, static) {
+			errs = append(errs, fmt.Errorf("%s.%s: unknown key %q (expected host, port, weight, %s<name> or a meta key)", path, field, key, strings.Join(sortKeyPrefixes, "<name>, ")))
+		}
+	}
+	checkMeta := func(field string, key string) {
+		if key = strings.TrimSpace(key); key != "" && !knownMetaKey(key, static) {
+			errs = append(errs, fmt.Errorf("%s.%s: unknown meta key %q (known prefixes: %s)", path, field, key, strings.Join(metaPrefixes, ", ")))
+		}
+	}
+	var lintFilters func(prefix string, filters []Filter)
+	lintFilters = func(prefix string, filters []Filter) {
+		for i, f := range filters {
+			fp := fmt.Sprintf("%sfilters[%d].", prefix, i)
+			switch normalizeStrategy(f.Type) {
+			case "compare", "headroom":
+				checkValue(fp+"left", f.Left)
+				checkValue(fp+"right", f.Right)
+			case "exists", "not_exists", "string", "equals":
+				checkValue(fp+"key", f.Key)
+			case "whitelist", "blacklist":
+				checkMeta(fp+"enabled_key", f.EnabledKey)
+				checkMeta(fp+"list_key", f.ListKey)
+			case "game_start_not_past":
+				checkMeta(fp+"key", f.Key)
+			case "all", "any", "not":
+				lintFilters(fp, f.Filters)
+			}
+		}
+	}
+	lint := func(prefix string, strategy string, key string, sort []SortKey, filters []Filter) {
+		if !isHashStrategy(strategy) && normalizeStrategy(strategy) != "least_utilization" {
+			checkValue(prefix+"key", key)
+		}
+		for i, s := range sort {
+			if strings.TrimSpace(s.Expr) == "" {
+				checkValue(fmt.Sprintf("%ssort[%d].key", prefix, i), s.Key)
+			}
+		}
+		lintFilters(prefix, filters)
+	}
+	lint("", p.Strategy, p.Key, p.Sort, p.Filters)
+	for i, fb := range p.Fallback {
+		strategy, key := p.Strategy, ""
+		if fb.Strategy != nil {
+			strategy = *fb.Strategy
+		}
+		if fb.Key != nil {
+			key = *fb.Key
+		}
+		lint(fmt.Sprintf("fallback[%d].", i), strategy, key, fb.Sort, fb.Filters)
+	}
+	return errs
+}
+
+func knownValueKey(key string, static map[string]struct{}) bool {
+	switch key {
+	case "host", "port", "weight":
+		return true
+	}
+	for _, p := range sortKeyPrefixes {
+		if strings.HasPrefix(key, p) {
+			return strings.TrimSpace(strings.TrimPrefix(key, p)) != ""
+		}
+	}
+	return knownMetaKey(key, static)
+}
+
+func knownMetaKey(key string, static map[string]struct{}) bool {
+	if _, ok := static[key]; ok {
+		return true
+	}
+	for _, p := range metaPrefixes {
+		if strings.HasPrefix(key, p) && len(key) > len(p) {
+			return true
+		}
+	}
+	return false
+}
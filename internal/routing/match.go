@@ -1,7 +1,9 @@
 package routing
 
 import (
+	"net/netip"
 	"path"
+	"strconv"
 	"strings"
 )
 
@@ -36,3 +38,294 @@ func hostnameMatches(pattern string, hostname string) bool {
 	}
 	return ok
 }
+
+// matchResult is the outcome of a match condition. Before the Connect packet is read, conditions on
+// its fields are unknown.
+type matchResult uint8
+
+const (
+	matchNo matchResult = iota
+	matchYes
+	matchUnknown
+)
+
+func boolResult(ok bool) matchResult {
+	if ok {
+		return matchYes
+	}
+	return matchNo
+}
+
+// and combines r with the next condition of an AND.
+func (r matchResult) and(next matchResult) matchResult {
+	if r == matchNo || next == matchNo {
+		return matchNo
+	}
+	if r == matchUnknown || next == matchUnknown {
+		return matchUnknown
+	}
+	return matchYes
+}
+
+// or combines r with the next alternative of an OR.
+func (r matchResult) or(next matchResult) matchResult {
+	if r == matchYes || next == matchYes {
+		return matchYes
+	}
+	if r == matchUnknown || next == matchUnknown {
+		return matchUnknown
+	}
+	return matchNo
+}
+
+// matcher evaluates Match conditions against a request. If early is set, only SNI, ALPN and the
+// client IP are known and conditions on Connect packet fields evaluate to matchUnknown.
+type matcher struct {
+	req   Request
+	sni   string
+	early bool
+}
+
+// eval returns whether m matches, the hostname pattern that matched and the regex captures, if any.
+// All conditions set in m must match; all of m.All and at least one of m.Any must match.
+func (mt matcher) eval(m *compiledMatch) (matchResult, string, map[string]string) {
+	res := matchYes
+	pattern := ""
+	var captures map[string]string
+	if m.hosts != nil {
+		p, c, ok := m.hosts.match(mt.sni)
+		res = boolResult(ok)
+		pattern, captures = p, c
+	}
+	if len(m.ALPN) > 0 {
+		res = res.and(boolResult(containsFold(m.ALPN, mt.req.ALPN)))
+	}
+	if len(m.SourceCIDRs) > 0 {
+		res = res.and(boolResult(sourceMatches(m.sources, mt.req.ClientIP)))
+	}
+	if len(m.Languages) > 0 {
+		res = res.and(mt.connect(func() bool { return languageMatches(m.Languages, mt.req.Language) }))
+	}
+	if len(m.ClientTypes) > 0 {
+		res = res.and(mt.connect(func() bool { return clientTypeMatches(m.ClientTypes, mt.req.ClientType) }))
+	}
+	if len(m.ProtocolHashes) > 0 {
+		res = res.and(mt.connect(func() bool { return containsFold(m.ProtocolHashes, strings.TrimSpace(mt.req.ProtocolHash)) }))
+	}
+	if m.ProtocolBuildNumber != nil {
+		res = res.and(mt.connect(func() bool {
+			return mt.req.ProtocolBuildNumber > 0 && m.ProtocolBuildNumber.contains(mt.req.ProtocolBuildNumber)
+		}))
+	}
+	if m.ClientVersion != nil {
+		res = res.and(mt.connect(func() bool {
+			return strings.TrimSpace(mt.req.ClientVersion) != "" && m.ClientVersion.contains(mt.req.ClientVersion)
+		}))
+	}
+	if len(m.Usernames) > 0 {
+		res = res.and(mt.connect(func() bool { return containsFold(m.Usernames, strings.TrimSpace(mt.req.Username)) }))
+	}
+	if len(m.UUIDs) > 0 {
+		res = res.and(mt.connect(func() bool { return containsFold(m.UUIDs, strings.TrimSpace(mt.req.UUID)) }))
+	}
+	use := func(p string, c map[string]string) {
+		if pattern == "" {
+			pattern = p
+		}
+		for k, v := range c {
+			if captures == nil {
+				captures = map[string]string{}
+			}
+			if _, ok := captures[k]; !ok {
+				captures[k] = v
+			}
+		}
+	}
+	for i := range m.all {
+		r, p, c := mt.eval(&m.all[i])
+		res = res.and(r)
+		if r == matchYes {
+			use(p, c)
+		}
+	}
+	if len(m.any) > 0 {
+		anyRes := matchNo
+		for i := range m.any {
+			r, p, c := mt.eval(&m.any[i])
+			anyRes = anyRes.or(r)
+			if r == matchYes {
+				use(p, c)
+				break
+			}
+		}
+		res = res.and(anyRes)
+	}
+	return res, pattern, captures
+}
+
+// connect evaluates a condition on a Connect packet field.
+func (mt matcher) connect(fn func() bool) matchResult {
+	if mt.early {
+		return matchUnknown
+	}
+	return boolResult(fn())
+}
+
+func containsFold(list []string, v string) bool {
+	if v == "" {
+		return false
+	}
+	for _, s := range list {
+		if strings.EqualFold(strings.TrimSpace(s), v) {
+			return true
+		}
+	}
+	return false
+}
+
+func sourceMatches(prefixes []netip.Prefix, clientIP string) bool {
+	addr, err := netip.ParseAddr(clientIP)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeLanguage lowercases a language tag and uses "-" as separator (de_DE -> de-de).
+func normalizeLanguage(s string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(s), "_", "-"))
+}
+
+// languageMatches reports whether language matches one of the tags. A base language tag
+// (e.g. "de") also matches its regional variants (e.g. "de-DE").
+func languageMatches(tags []string, language string) bool {
+	lang := normalizeLanguage(language)
+	if lang == "" {
+		return false
+	}
+	base := lang
+	if i := strings.Index(base, "-"); i >= 0 {
+		base = base[:i]
+	}
+	for _, t := range tags {
+		t = normalizeLanguage(t)
+		if t == lang || t == base {
+			return true
+		}
+	}
+	return false
+}
+
+func clientTypeMatches(types []int, clientType *uint8) bool {
+	if clientType == nil {
+		return false
+	}
+	for _, t := range types {
+		if t == int(*clientType) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether n is within the range. gte is inclusive, lt is exclusive; 0 means unbounded.
+func (r NumberRange) contains(n int) bool {
+	if r.GTE != 0 && n < r.GTE {
+		return false
+	}
+	if r.LT != 0 && n >= r.LT {
+		return false
+	}
+	return true
+}
+
+// contains reports whether v is within the range. gte is inclusive, lt is exclusive.
+func (r VersionRange) contains(v string) bool {
+	if strings.TrimSpace(r.GTE) != "" && compareVersions(v, r.GTE) < 0 {
+		return false
+	}
+	if strings.TrimSpace(r.LT) != "" && compareVersions(v, r.LT) >= 0 {
+		return false
+	}
+	return true
+}
+
+// compareVersions compares dotted versions part by part. Numeric parts are compared as numbers,
+// other parts as strings; missing parts count as 0 (so 1.2 == 1.2.0).
+func compareVersions(a string, b string) int {
+	pa := versionParts(a)
+	pb := versionParts(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		x, y := "0", "0"
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		nx, errX := strconv.ParseInt(x, 10, 64)
+		ny, errY := strconv.ParseInt(y, 10, 64)
+		switch {
+		case errX == nil && errY == nil:
+			if nx != ny {
+				if nx < ny {
+					return -1
+				}
+				return 1
+			}
+		case x != y:
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionParts(v string) []string {
+	v = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(v)), "v")
+	return strings.FieldsFunc(v, func(r rune) bool {
+		return r == '.' || r == '-' || r == '_' || r == '+'
+	})
+}
+
+// matchRoute returns the index of the first route matching req, the hostname pattern that matched
+// and the regex captures, or -1 if no route matches.
+func (e *StaticEngine) matchRoute(req Request) (int, string, map[string]string) {
+	mt := matcher{req: req, sni: canonicalHost(req.SNI)}
+	for _, i := range e.index.candidates(mt.sni) {
+		if !e.routeScheduled(i) {
+			continue
+		}
+		if res, p, c := mt.eval(&e.index.routes[i]); res == matchYes {
+			return i, p, c
+		}
+	}
+	return -1, "", nil
+}
+
+// EarlyRouteIndex returns the index of the route that will handle a connection before its Connect
+// packet is read (-1 for the default route), using only req.SNI, req.ALPN and req.ClientIP.
+// ok is false if the route depends on Connect packet fields.
+func (e *StaticEngine) EarlyRouteIndex(req Request) (int, bool) {
+	mt := matcher{req: req, sni: canonicalHost(req.SNI), early: true}
+	for _, i := range e.index.candidates(mt.sni) {
+		if !e.routeScheduled(i) {
+			continue
+		}
+		switch res, _, _ := mt.eval(&e.index.routes[i]); res {
+		case matchYes:
+			return i, true
+		case matchUnknown:
+			return -1, false
+		}
+	}
+	return -1, true
+}
@@ -0,0 +1,155 @@
+package routing
+
+import (
+	"context"
+	"testing"
+)
+
+func evalMatch(mt matcher, m Match) matchResult {
+	cm := compileMatch(m)
+	res, _, _ := mt.eval(&cm)
+	return res
+}
+
+func TestLanguageMatches(t *testing.T) {
+	cases := []struct {
+		tags     []string
+		language string
+		want     bool
+	}{
+		{tags: []string{"de"}, language: "de-DE", want: true},
+		{tags: []string{"de"}, language: "de_AT", want: true},
+		{tags: []string{"de-DE"}, language: "de-de", want: true},
+		{tags: []string{"de-DE"}, language: "de-AT", want: false},
+		{tags: []string{"de"}, language: "en-US", want: false},
+		{tags: []string{"de"}, language: "", want: false},
+	}
+	for _, tc := range cases {
+		if got := languageMatches(tc.tags, tc.language); got != tc.want {
+			t.Fatalf("languageMatches(%v,%q)=%v want %v", tc.tags, tc.language, got, tc.want)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{a: "1.2", b: "1.2.0", want: 0},
+		{a: "1.10.0", b: "1.9.9", want: 1},
+		{a: "v2026.01.15", b: "2026.1.16", want: -1},
+		{a: "1.2.0-beta", b: "1.2.0-alpha", want: 1},
+	}
+	for _, tc := range cases {
+		if got := compareVersions(tc.a, tc.b); got != tc.want {
+			t.Fatalf("compareVersions(%q,%q)=%d want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestMatcherEval(t *testing.T) {
+	ct := uint8(1)
+	req := Request{
+		SNI:                 "play.example.com",
+		Language:            "de-DE",
+		ClientIP:            "203.0.113.9",
+		ALPN:                "hytale/1",
+		ProtocolHash:        "ABC",
+		ProtocolBuildNumber: 1200,
+		ClientVersion:       "1.4.2",
+		ClientType:          &ct,
+		Username:            "Krymo",
+		UUID:                "d3e6ef90-e113-49a7-a845-1c11f24fe166",
+	}
+	cases := []struct {
+		name string
+		m    Match
+		want matchResult
+	}{
+		{name: "hostname and language", m: Match{Hostname: "*.example.com", Languages: []string{"de"}}, want: matchYes},
+		{name: "hostname mismatch", m: Match{Hostname: "other.example.com", Languages: []string{"de"}}, want: matchNo},
+		{name: "client type", m: Match{ClientTypes: []int{0}}, want: matchNo},
+		{name: "protocol hash", m: Match{ProtocolHashes: []string{"abc"}}, want: matchYes},
+		{name: "build number", m: Match{ProtocolBuildNumber: &NumberRange{GTE: 1000, LT: 1200}}, want: matchNo},
+		{name: "outdated client", m: Match{ClientVersion: &VersionRange{LT: "1.5"}}, want: matchYes},
+		{name: "alpn", m: Match{ALPN: []string{"hytale/2"}}, want: matchNo},
+		{name: "source cidr", m: Match{SourceCIDRs: []string{"203.0.113.0/24"}}, want: matchYes},
+		{name: "nested source address", m: Match{Any: []Match{{SourceCIDRs: []string{"10.0.0.0/8"}}, {SourceCIDRs: []string{"203.0.113.9"}}}}, want: matchYes},
+		{name: "usernames", m: Match{Usernames: []string{"krymo"}}, want: matchYes},
+		{name: "uuids", m: Match{UUIDs: []string{"D3E6EF90-E113-49A7-A845-1C11F24FE166"}}, want: matchYes},
+		{name: "any", m: Match{Any: []Match{{Languages: []string{"fr"}}, {Languages: []string{"de"}}}}, want: matchYes},
+		{name: "all", m: Match{All: []Match{{Languages: []string{"de"}}, {ALPN: []string{"hytale/2"}}}}, want: matchNo},
+	}
+	for _, tc := range cases {
+		got := evalMatch(matcher{req: req, sni: canonicalHost(req.SNI)}, tc.m)
+		if got != tc.want {
+			t.Fatalf("%s: got %v want %v", tc.name, got, tc.want)
+		}
+	}
+
+	if got := evalMatch(matcher{req: Request{SNI: "play.example.com"}, sni: "play.example.com"}, Match{ClientTypes: []int{0}}); got != matchNo {
+		t.Fatalf("expected unknown client type not to match")
+	}
+	early := matcher{req: Request{SNI: "play.example.com"}, sni: "play.example.com", early: true}
+	if got := evalMatch(early, Match{Hostname: "play.example.com", Languages: []string{"de"}}); got != matchUnknown {
+		t.Fatalf("expected Connect field to be unknown before Connect, got %v", got)
+	}
+	if got := evalMatch(early, Match{Hostname: "other.example.com", Languages: []string{"de"}}); got != matchNo {
+		t.Fatalf("expected hostname mismatch to decide early, got %v", got)
+	}
+}
+
+func TestStaticEngineDecide_ConnectFieldRoutes(t *testing.T) {
+	e := NewStaticEngine(Config{
+		Default: &Pool{Strategy: "round_robin", Backends: []Backend{{Host: "global", Port: 1}}},
+		Routes: []Route{
+			{Match: Match{Hostname: "play.example.com", ClientVersion: &VersionRange{LT: "1.5"}}, Pool: Pool{Strategy: "round_robin", Backends: []Backend{{Host: "update-lobby", Port: 1}}}},
+			{Match: Match{Hostname: "play.example.com", Languages: []string{"de"}}, Pool: Pool{Strategy: "round_robin", Backends: []Backend{{Host: "eu", Port: 1}}}},
+		},
+	})
+	cases := []struct {
+		req  Request
+		want string
+	}{
+		{req: Request{SNI: "play.example.com", ClientVersion: "1.4.9", Language: "de-DE"}, want: "update-lobby"},
+		{req: Request{SNI: "play.example.com", ClientVersion: "1.5.0", Language: "de-DE"}, want: "eu"},
+		{req: Request{SNI: "play.example.com", ClientVersion: "1.5.0", Language: "en-US"}, want: "global"},
+	}
+	for _, tc := range cases {
+		d, err := e.Decide(context.Background(), tc.req)
+		if err != nil {
+			t.Fatalf("Decide: %v", err)
+		}
+		if d.Backend.Host != tc.want {
+			t.Fatalf("req=%+v backend=%q want %q", tc.req, d.Backend.Host, tc.want)
+		}
+	}
+
+	if i, ok := e.EarlyRouteIndex(Request{SNI: "play.example.com"}); ok || i != -1 {
+		t.Fatalf("expected undetermined early route, got %d %v", i, ok)
+	}
+	if i, ok := e.EarlyRouteIndex(Request{SNI: "other.example.com"}); !ok || i != -1 {
+		t.Fatalf("expected default route early, got %d %v", i, ok)
+	}
+}
+
+func TestValidateMatch(t *testing.T) {
+	m := Match{
+		SourceCIDRs:         []string{"10.0.0.0/33"},
+		ClientTypes:         []int{256},
+		Languages:           []string{" "},
+		ProtocolBuildNumber: &NumberRange{GTE: 10, LT: 5},
+		ClientVersion:       &VersionRange{},
+		Any:                 []Match{{}},
+	}
+	if errs := validateMatch("routing.routes[0].match", m); len(errs) != 6 {
+		t.Fatalf("errs=%v", errs)
+	}
+	if errs := validateMatch("routing.routes[0].match", Match{HostnameRegexes: []string{"ok", "(unclosed"}}); len(errs) != 1 {
+		t.Fatalf("errs=%v", errs)
+	}
+	if errs := validateMatch("routing.routes[0].match", Match{Languages: []string{"de"}}); len(errs) != 0 {
+		t.Fatalf("errs=%v", errs)
+	}
+}
@@ -0,0 +1,51 @@
+package routing
+
+import (
+	"strconv"
+	"strings"
+)
+
+// RecentReferralsKey is the meta key holding the number of referrals this instance recently sent
+// to a backend.
+const RecentReferralsKey = "hyrouter.recent_referrals"
+
+// SetRecentReferrals registers the local referral counter. Candidates are annotated with
+// hyrouter.recent_referrals, which pools with count_recent_referrals add to their load.
+func (e *StaticEngine) SetRecentReferrals(fn func(t Target) int) {
+	e.recent = fn
+}
+
+func (e *StaticEngine) withRecentReferrals(in []Backend) []Backend {
+	if e.recent == nil || len(in) == 0 {
+		return in
+	}
+	out := make([]Backend, len(in))
+	for i, b := range in {
+		meta := make(map[string]string, len(b.Meta)+1)
+		for k, v := range b.Meta {
+			meta[k] = v
+		}
+		meta[RecentReferralsKey] = strconv.Itoa(e.recent(b.Target()))
+		b.Meta = meta
+		out[i] = b
+	}
+	return out
+}
+
+func recentReferrals(b Backend) int {
+	n, err := strconv.Atoi(strings.TrimSpace(metaGet(b.Meta, RecentReferralsKey)))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// loadOf returns the numeric value of key for the least_loaded and p2c strategies, plus the
+// recent referrals if countRecent is set.
+func loadOf(b Backend, key string, countRecent bool) (float64, bool) {
+	_, ok, n := sortValue(b, key, "number")
+	if ok && countRecent {
+		n += float64(recentReferrals(b))
+	}
+	return n, ok
+}
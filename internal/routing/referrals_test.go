@@ -0,0 +1,47 @@
+package routing
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRecentReferrals(t *testing.T) {
+	pool := func(strategy string, count bool) Config {
+		return Config{Default: &Pool{Strategy: strategy, Key: "counter:players.count", CountRecentReferrals: count, Backends: []Backend{
+			{Host: "a", Port: 1, Meta: map[string]string{"counter.players.count": "5", "counter.players.capacity": "20"}},
+			{Host: "b", Port: 1, Meta: map[string]string{"counter.players.count": "3", "counter.players.capacity": "20"}},
+		}}}
+	}
+	recent := func(t Target) int {
+		if t.Host == "b" {
+			return 4
+		}
+		return 0
+	}
+	for _, strategy := range []string{"least_loaded", "least_utilization"} {
+		for _, tc := range []struct {
+			count bool
+			want  string
+		}{{false, "b"}, {true, "a"}} {
+			cfg := pool(strategy, tc.count)
+			if strategy == "least_utilization" {
+				cfg.Default.Key = ""
+			}
+			e := NewStaticEngine(cfg)
+			e.SetRecentReferrals(recent)
+			d, err := e.Decide(context.Background(), Request{})
+			if err != nil {
+				t.Fatalf("%s: Decide: %v", strategy, err)
+			}
+			if d.Backend.Host != tc.want {
+				t.Fatalf("%s count=%v: backend=%q want %q", strategy, tc.count, d.Backend.Host, tc.want)
+			}
+			if got := d.Backend.Meta[RecentReferralsKey]; got != map[string]string{"a": "0", "b": "4"}[tc.want] {
+				t.Fatalf("%s: %s=%q", strategy, RecentReferralsKey, got)
+			}
+			if _, ok := cfg.Default.Backends[1].Meta[RecentReferralsKey]; ok {
+				t.Fatalf("config backend meta was mutated")
+			}
+		}
+	}
+}
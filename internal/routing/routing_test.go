@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"math/rand"
+	"strings"
 	"testing"
 )
 
@@ -294,3 +295,58 @@ func TestStaticEngineDecide_DiscoveryErrorWrapped(t *testing.T) {
 		t.Fatalf("expected ErrDiscovery wrapper, got %v", err)
 	}
 }
+
+func TestConfigValidate_ReportsAllProblems(t *testing.T) {
+	cfg := Config{
+		Default: &Pool{Strategy: "nope", Backends: []Backend{{Host: "a", Port: 1}}},
+		Routes: []Route{{
+			Pool: Pool{Strategy: "round_robin", Limit: -1, Filters: []Filter{{Type: "x"}}, Backends: []Backend{{Host: "", Port: 1}}},
+		}},
+	}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	msg := err.Error()
+	for _, want := range []string{
+		`routing.default: unknown strategy "nope"`,
+		"routing.routes[0].pool.limit must be >= 0",
+		`routing.routes[0].pool.filters[0]: unknown type "x"`,
+		"routing.routes[0].pool.backends[0]: host must not be empty",
+		"routing.routes[0].match must not be empty",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("missing %q in:\n%s", want, msg)
+		}
+	}
+}
+
+func TestConfigLintKeys(t *testing.T) {
+	key := "counter:players"
+	cfg := Config{Routes: []Route{{
+		Match: Match{Hostname: "x"},
+		Pool: Pool{
+			Strategy: "least_loaded",
+			Key:      "counter:players.count",
+			Sort:     []SortKey{{Key: "label:tier"}, {Key: "host"}, {Key: "custom"}, {Key: "lable:tier"}},
+			Filters: []Filter{
+				{Type: "whitelist", EnabledKey: "label.wl", ListKey: "anotation.wl"},
+				{Type: "compare", Left: "counter:players.count", Op: "lt", Right: "counter:players.capacity"},
+			},
+			Fallback: []Fallback{{Key: &key, Sort: []SortKey{{Key: "gameserver.state"}}}},
+			Backends: []Backend{{Host: "a", Port: 1, Meta: map[string]string{"custom": "1"}}},
+		},
+	}}}
+	problems := cfg.LintKeys()
+	if problems == nil {
+		t.Fatalf("expected problems")
+	}
+	msg := problems.Error()
+	if !strings.Contains(msg, `routing.routes[0].pool.sort[3].key: unknown key "lable:tier"`) ||
+		!strings.Contains(msg, `routing.routes[0].pool.filters[0].list_key: unknown meta key "anotation.wl"`) {
+		t.Fatalf("msg=%s", msg)
+	}
+	if strings.Contains(msg, "custom") || strings.Contains(msg, "fallback") || strings.Count(msg, "\n") != 1 {
+		t.Fatalf("unexpected problems:\n%s", msg)
+	}
+}
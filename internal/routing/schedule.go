@@ -0,0 +1,343 @@
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Schedules accept IANA time zones even on images without a zoneinfo database.
+	_ "time/tzdata"
+)
+
+// Schedule is a set of time windows. A window is either a cron expression for its start plus a
+// duration, or a fixed start and end.
+type Schedule struct {
+	// Timezone is an IANA time zone name for cron expressions (default: UTC).
+	Timezone string           `json:"timezone" yaml:"timezone"`
+	Windows  []ScheduleWindow `json:"windows" yaml:"windows"`
+}
+
+type ScheduleWindow struct {
+	// Cron is a 5-field cron expression (minute hour day-of-month month day-of-week).
+	Cron     string `json:"cron" yaml:"cron"`
+	Duration string `json:"duration" yaml:"duration"`
+	// Start and End are RFC 3339 timestamps.
+	Start string `json:"start" yaml:"start"`
+	End   string `json:"end" yaml:"end"`
+}
+
+// Maintenance denies every connection of a route while one of its windows is active.
+type Maintenance struct {
+	Schedule `json:",inline" yaml:",inline"`
+	// Message replaces messages.disconnect.maintenance; ${maintenance_end} is the end of the window.
+	Message string `json:"message" yaml:"message"`
+}
+
+// MaintenanceError is returned while a scheduled maintenance window is active. It matches ErrMaintenance.
+type MaintenanceError struct {
+	End     time.Time
+	Message string
+}
+
+func (e *MaintenanceError) Error() string {
+	return fmt.Sprintf("%s until %s", ErrMaintenance, e.End.UTC().Format(time.RFC3339))
+}
+
+func (e *MaintenanceError) Unwrap() error {
+	return ErrMaintenance
+}
+
+// FormatMaintenanceEnd formats the end of a maintenance window for Disconnect messages.
+func FormatMaintenanceEnd(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04 MST")
+}
+
+// compiledSchedule is a parsed Schedule.
+type compiledSchedule struct {
+	loc     *time.Location
+	windows []compiledWindow
+}
+
+type compiledWindow struct {
+	cron     *cronExpr
+	duration time.Duration
+	start    time.Time
+	end      time.Time
+}
+
+func compileSchedule(s Schedule) (*compiledSchedule, error) {
+	loc := time.UTC
+	if tz := strings.TrimSpace(s.Timezone); tz != "" {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("timezone: %w", err)
+		}
+		loc = l
+	}
+	if len(s.Windows) == 0 {
+		return nil, errors.New("windows must not be empty")
+	}
+	cs := &compiledSchedule{loc: loc}
+	for i, w := range s.Windows {
+		cw, err := compileWindow(w)
+		if err != nil {
+			return nil, fmt.Errorf("windows[%d].%w", i, err)
+		}
+		cs.windows = append(cs.windows, cw)
+	}
+	return cs, nil
+}
+
+func compileWindow(w ScheduleWindow) (compiledWindow, error) {
+	cronSet := strings.TrimSpace(w.Cron) != ""
+	fixedSet := strings.TrimSpace(w.Start) != "" || strings.TrimSpace(w.End) != ""
+	switch {
+	case cronSet && fixedSet:
+		return compiledWindow{}, errors.New("cron: cannot be combined with start/end")
+	case cronSet:
+		expr, err := parseCron(w.Cron)
+		if err != nil {
+			return compiledWindow{}, fmt.Errorf("cron: %w", err)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(w.Duration))
+		if err != nil || d <= 0 {
+			return compiledWindow{}, errors.New("duration: must be a positive duration")
+		}
+		return compiledWindow{cron: expr, duration: d}, nil
+	case fixedSet:
+		start, err := time.Parse(time.RFC3339, strings.TrimSpace(w.Start))
+		if err != nil {
+			return compiledWindow{}, fmt.Errorf("start: %w", err)
+		}
+		end, err := time.Parse(time.RFC3339, strings.TrimSpace(w.End))
+		if err != nil {
+			return compiledWindow{}, fmt.Errorf("end: %w", err)
+		}
+		if !end.After(start) {
+			return compiledWindow{}, errors.New("end: must be after start")
+		}
+		return compiledWindow{start: start, end: end}, nil
+	default:
+		return compiledWindow{}, errors.New("cron: either cron and duration or start and end must be set")
+	}
+}
+
+// active reports whether t lies within a window and returns the end of that window
+// (the latest end if windows overlap), in the schedule's time zone.
+func (s *compiledSchedule) active(t time.Time) (bool, time.Time) {
+	t = t.In(s.loc)
+	var end time.Time
+	for _, w := range s.windows {
+		if e, ok := w.activeAt(t); ok && e.After(end) {
+			end = e
+		}
+	}
+	return !end.IsZero(), end.In(s.loc)
+}
+
+func (w compiledWindow) activeAt(t time.Time) (time.Time, bool) {
+	if w.cron == nil {
+		return w.end, !t.Before(w.start) && t.Before(w.end)
+	}
+	// The window started at the first firing after t-duration, if that is not after t.
+	start := w.cron.next(t.Add(-w.duration))
+	if start.IsZero() || start.After(t) {
+		return time.Time{}, false
+	}
+	return start.Add(w.duration), true
+}
+
+// cronExpr is a parsed 5-field cron expression. Each field is a bit set of allowed values.
+type cronExpr struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar record unrestricted fields: if both day fields are restricted,
+	// a day matches if either matches (as in Vixie cron).
+	domStar, dowStar bool
+}
+
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+func parseCron(s string) (*cronExpr, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, got %d", len(fields))
+	}
+	var sets [5]uint64
+	for i, f := range fields {
+		set, err := parseCronField(f, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", cronFields[i].name, err)
+		}
+		sets[i] = set
+	}
+	// Sunday may be written as 0 or 7.
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+	return &cronExpr{
+		minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4],
+		domStar: fields[2] == "*" || fields[2] == "?",
+		dowStar: fields[4] == "*" || fields[4] == "?",
+	}, nil
+}
+
+// parseCronField parses a comma-separated list of "*", "n", "a-b", each optionally followed by "/step".
+func parseCronField(f string, min int, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(f, ",") {
+		rng, stepRaw, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepRaw)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepRaw)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		switch {
+		case rng == "*" || rng == "?":
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(a); err != nil {
+				return 0, fmt.Errorf("invalid value %q", a)
+			}
+			if hi, err = strconv.Atoi(b); err != nil {
+				return 0, fmt.Errorf("invalid value %q", b)
+			}
+		default:
+			n, err := strconv.Atoi(rng)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", rng)
+			}
+			lo, hi = n, n
+			if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func (c *cronExpr) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the first firing strictly after t, in t's location, or the zero time if there
+// is none within five years.
+func (c *cronExpr) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			// time.Date keeps zones with half-hour offsets aligned; around DST changes it may not advance.
+			n := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			if !n.After(t) {
+				n = t.Add(time.Hour)
+			}
+			t = n
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// compileSchedules compiles every schedule of cfg. Invalid schedules are skipped; Validate reports them.
+func compileSchedules(cfg Config) map[*Schedule]*compiledSchedule {
+	out := map[*Schedule]*compiledSchedule{}
+	add := func(s *Schedule) {
+		if s == nil {
+			return
+		}
+		if cs, err := compileSchedule(*s); err == nil {
+			out[s] = cs
+		}
+	}
+	if cfg.Default != nil {
+		add(cfg.Default.Schedule)
+	}
+	for _, r := range cfg.Routes {
+		add(r.Schedule)
+		if r.Maintenance != nil {
+			add(&r.Maintenance.Schedule)
+		}
+		for _, pp := range r.Pools("") {
+			add(pp.Pool.Schedule)
+		}
+	}
+	return out
+}
+
+// scheduleActive reports whether s is active now and when the current window ends.
+// A nil schedule is always active; an invalid one never is.
+func (e *StaticEngine) scheduleActive(s *Schedule) (bool, time.Time) {
+	if s == nil {
+		return true, time.Time{}
+	}
+	cs, ok := e.schedules[s]
+	if !ok {
+		return false, time.Time{}
+	}
+	return cs.active(e.now())
+}
+
+func (e *StaticEngine) routeScheduled(i int) bool {
+	ok, _ := e.scheduleActive(e.cfg.Routes[i].Schedule)
+	return ok
+}
+
+func (e *StaticEngine) checkPoolSchedule(p Pool) error {
+	if ok, _ := e.scheduleActive(p.Schedule); !ok {
+		return fmt.Errorf("%w: pool is outside its schedule", ErrNoBackends)
+	}
+	return nil
+}
+
+func validateSchedule(path string, s *Schedule) []error {
+	if s == nil {
+		return nil
+	}
+	if _, err := compileSchedule(*s); err != nil {
+		return []error{fmt.Errorf("%s.%w", path, err)}
+	}
+	return nil
+}
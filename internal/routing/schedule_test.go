@@ -0,0 +1,134 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	c, err := parseCron("0 18 * * 6")
+	if err != nil {
+		t.Fatalf("parseCron: %v", err)
+	}
+	from := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC) // Wednesday
+	if got, want := c.next(from), time.Date(2026, 10, 17, 18, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("next=%v want %v", got, want)
+	}
+
+	c, err = parseCron("*/15 9-17 1,15 * 1-5")
+	if err != nil {
+		t.Fatalf("parseCron: %v", err)
+	}
+	// Day of month and day of week are both restricted, so either matches.
+	from = time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC) // Saturday the 17th
+	if got, want := c.next(from), time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("next=%v want %v", got, want)
+	}
+
+	for _, bad := range []string{"* * * *", "60 * * * *", "* * * * 8", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		if _, err := parseCron(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestScheduleActive(t *testing.T) {
+	cs, err := compileSchedule(Schedule{
+		Timezone: "Europe/Berlin",
+		Windows: []ScheduleWindow{
+			{Cron: "0 18 * * 6", Duration: "4h"},
+			{Start: "2026-12-24T00:00:00Z", End: "2026-12-27T00:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("compileSchedule: %v", err)
+	}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	cases := []struct {
+		at     time.Time
+		active bool
+		end    time.Time
+	}{
+		{at: time.Date(2026, 10, 17, 17, 59, 0, 0, berlin)},
+		{at: time.Date(2026, 10, 17, 18, 0, 0, 0, berlin), active: true, end: time.Date(2026, 10, 17, 22, 0, 0, 0, berlin)},
+		{at: time.Date(2026, 10, 17, 21, 59, 0, 0, berlin), active: true, end: time.Date(2026, 10, 17, 22, 0, 0, 0, berlin)},
+		{at: time.Date(2026, 10, 17, 22, 0, 0, 0, berlin)},
+		{at: time.Date(2026, 12, 25, 3, 0, 0, 0, time.UTC), active: true, end: time.Date(2026, 12, 27, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range cases {
+		active, end := cs.active(tc.at)
+		if active != tc.active || (active && !end.Equal(tc.end)) {
+			t.Fatalf("active(%v)=%v,%v want %v,%v", tc.at, active, end, tc.active, tc.end)
+		}
+	}
+}
+
+func TestStaticEngineDecide_Schedules(t *testing.T) {
+	pool := func(host string) Pool {
+		return Pool{Strategy: "round_robin", Backends: []Backend{{Host: host, Port: 1}}}
+	}
+	e := NewStaticEngine(Config{
+		Default: func() *Pool { p := pool("lobby"); return &p }(),
+		Routes: []Route{
+			{
+				Match:    Match{Hostname: "event.example.com"},
+				Pool:     pool("event"),
+				Schedule: &Schedule{Windows: []ScheduleWindow{{Cron: "0 18 * * 6", Duration: "2h"}}},
+			},
+			{
+				Match: Match{Hostname: "play.example.com"},
+				Pool:  pool("play"),
+				Maintenance: &Maintenance{
+					Schedule: Schedule{Windows: []ScheduleWindow{{Start: "2026-10-16T10:00:00Z", End: "2026-10-16T12:00:00Z"}}},
+					Message:  "Back at ${maintenance_end}",
+				},
+			},
+		},
+	})
+	ctx := context.Background()
+
+	e.now = func() time.Time { return time.Date(2026, 10, 16, 11, 0, 0, 0, time.UTC) }
+	if d, err := e.Decide(ctx, Request{SNI: "event.example.com"}); err != nil || d.Backend.Host != "lobby" {
+		t.Fatalf("outside schedule: decision=%+v err=%v", d, err)
+	}
+	_, err := e.Decide(ctx, Request{SNI: "play.example.com"})
+	var me *MaintenanceError
+	if !errors.Is(err, ErrMaintenance) || !errors.As(err, &me) {
+		t.Fatalf("expected maintenance error, got %v", err)
+	}
+	if !me.End.Equal(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)) || !strings.Contains(me.Message, "${maintenance_end}") {
+		t.Fatalf("maintenance=%+v", me)
+	}
+
+	e.now = func() time.Time { return time.Date(2026, 10, 17, 19, 0, 0, 0, time.UTC) }
+	if d, err := e.Decide(ctx, Request{SNI: "event.example.com"}); err != nil || d.Backend.Host != "event" {
+		t.Fatalf("inside schedule: decision=%+v err=%v", d, err)
+	}
+	if d, err := e.Decide(ctx, Request{SNI: "play.example.com"}); err != nil || d.Backend.Host != "play" {
+		t.Fatalf("after maintenance: decision=%+v err=%v", d, err)
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	cfg := Config{Routes: []Route{{
+		Match:    Match{Hostname: "a"},
+		Pool:     Pool{Strategy: "round_robin", Backends: []Backend{{Host: "a", Port: 1}}, Schedule: &Schedule{}},
+		Schedule: &Schedule{Timezone: "Mars/Olympus", Windows: []ScheduleWindow{{Cron: "0 18 * * 6", Duration: "1h"}}},
+		Maintenance: &Maintenance{Schedule: Schedule{Windows: []ScheduleWindow{
+			{Cron: "0 18 * * 6", Duration: "0s"},
+		}}},
+	}}}
+	err := cfg.Validate()
+	for _, want := range []string{
+		"routing.routes[0].schedule.timezone",
+		"routing.routes[0].maintenance.windows[0].duration",
+		"routing.routes[0].pool.schedule.windows must not be empty",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in %v", want, err)
+		}
+	}
+}
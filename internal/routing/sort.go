@@ -6,42 +6,68 @@ import (
 	"strings"
 )
 
-func applySort(backends []Backend, keys []SortKey) {
+// sortCell is the value of one sort key for one backend.
+type sortCell struct {
+	s      string
+	ok     bool
+	n      float64
+	number bool
+}
+
+func (e *StaticEngine) applySort(req Request, backends []Backend, keys []SortKey) {
 	if len(keys) == 0 {
 		return
 	}
-	sort.SliceStable(backends, func(i, j int) bool {
-		a := backends[i]
-		b := backends[j]
-		for _, k := range keys {
-			order := normalizeStrategy(k.Order)
-			if order == "" {
-				order = "asc"
+	// Values are computed once per backend, so expressions are not evaluated per comparison.
+	cells := make([][]sortCell, len(backends))
+	for i, b := range backends {
+		cells[i] = make([]sortCell, len(keys))
+		for j, k := range keys {
+			if strings.TrimSpace(k.Expr) != "" {
+				s, ok, n, number := e.exprSortValue(req, b, k.Expr)
+				cells[i][j] = sortCell{s: s, ok: ok, n: n, number: number}
+				continue
 			}
 			typeHint := normalizeStrategy(k.Type)
-			av, aok, an := sortValue(a, k.Key, typeHint)
-			bv, bok, bn := sortValue(b, k.Key, typeHint)
-			if aok != bok {
-				return aok && !bok
+			s, ok, n := sortValue(b, k.Key, typeHint)
+			cells[i][j] = sortCell{s: s, ok: ok, n: n, number: typeHint == "number"}
+		}
+	}
+	order := make([]int, len(backends))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(x, y int) bool {
+		a := cells[order[x]]
+		b := cells[order[y]]
+		for j, k := range keys {
+			desc := normalizeStrategy(k.Order) == "desc"
+			if a[j].ok != b[j].ok {
+				return a[j].ok && !b[j].ok
 			}
-			if typeHint == "number" {
-				if an != bn {
-					if order == "desc" {
-						return an > bn
+			if a[j].number {
+				if a[j].n != b[j].n {
+					if desc {
+						return a[j].n > b[j].n
 					}
-					return an < bn
+					return a[j].n < b[j].n
 				}
 				continue
 			}
-			if av != bv {
-				if order == "desc" {
-					return av > bv
+			if a[j].s != b[j].s {
+				if desc {
+					return a[j].s > b[j].s
 				}
-				return av < bv
+				return a[j].s < b[j].s
 			}
 		}
 		return false
 	})
+	sorted := make([]Backend, len(backends))
+	for i, o := range order {
+		sorted[i] = backends[o]
+	}
+	copy(backends, sorted)
 }
 
 func sortValue(b Backend, key string, typeHint string) (string, bool, float64) {
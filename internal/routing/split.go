@@ -0,0 +1,110 @@
+package routing
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PoolPath is a pool of a route together with the YAML path of its config.
+type PoolPath struct {
+	Path string
+	Pool Pool
+}
+
+// Pools returns the pool of r, or the pools of its split arms. path is the YAML path of r.
+func (r Route) Pools(path string) []PoolPath {
+	if r.Split == nil {
+		return []PoolPath{{Path: path + ".pool", Pool: r.Pool}}
+	}
+	out := make([]PoolPath, 0, len(r.Split.Arms))
+	for i, a := range r.Split.Arms {
+		out = append(out, PoolPath{Path: fmt.Sprintf("%s.split.arms[%d].pool", path, i), Pool: a.Pool})
+	}
+	return out
+}
+
+// canary returns the index of the canary arm, or -1.
+func (s *Split) canary() int {
+	for i, a := range s.Arms {
+		if a.Canary {
+			return i
+		}
+	}
+	return -1
+}
+
+// arm returns the arm that handles req, or -1 if no arm is active. Players listed in CanaryUUIDs
+// go to the canary arm; everyone else is assigned by a hash of the UUID (the client IP if the UUID
+// is unknown), so a player stays in the same arm as long as the weights do not change.
+// Arms for which active returns false are skipped.
+func (s *Split) arm(req Request, active func(i int) bool) int {
+	uuid := strings.TrimSpace(req.UUID)
+	if c := s.canary(); c >= 0 && active(c) && containsFold(s.CanaryUUIDs, uuid) {
+		return c
+	}
+	total := 0
+	for i, a := range s.Arms {
+		if active(i) {
+			total += a.Weight
+		}
+	}
+	if total <= 0 {
+		return -1
+	}
+	key := strings.ToLower(uuid)
+	if key == "" {
+		key = req.ClientIP
+	}
+	n := int(hash64("split", key) % uint64(total))
+	for i, a := range s.Arms {
+		if !active(i) {
+			continue
+		}
+		if n < a.Weight {
+			return i
+		}
+		n -= a.Weight
+	}
+	return -1
+}
+
+func validateSplit(path string, r Route) []error {
+	s := r.Split
+	var errs []error
+	if len(r.Pool.Backends) > 0 || r.Pool.Discovery != nil || r.Pool.Target != nil || strings.TrimSpace(r.Pool.Strategy) != "" {
+		errs = append(errs, fmt.Errorf("%s.split and %s.pool are mutually exclusive", path, path))
+	}
+	if len(s.Arms) == 0 {
+		errs = append(errs, fmt.Errorf("%s.split.arms must not be empty", path))
+	}
+	names := map[string]struct{}{}
+	total, canaries := 0, 0
+	for i, a := range s.Arms {
+		p := fmt.Sprintf("%s.split.arms[%d]", path, i)
+		name := strings.TrimSpace(a.Name)
+		if name == "" {
+			errs = append(errs, fmt.Errorf("%s.name must not be empty", p))
+		} else if _, ok := names[name]; ok {
+			errs = append(errs, fmt.Errorf("%s.name %q is used by another arm", p, name))
+		}
+		names[name] = struct{}{}
+		if a.Weight < 0 {
+			errs = append(errs, fmt.Errorf("%s.weight must be >= 0", p))
+		}
+		total += a.Weight
+		if a.Canary {
+			canaries++
+		}
+		errs = append(errs, validatePool(p+".pool", a.Pool)...)
+	}
+	if len(s.Arms) > 0 && total <= 0 {
+		errs = append(errs, fmt.Errorf("%s.split.arms must have a total weight > 0", path))
+	}
+	if canaries > 1 {
+		errs = append(errs, fmt.Errorf("%s.split.arms must have at most one canary arm", path))
+	}
+	if len(s.CanaryUUIDs) > 0 && canaries == 0 {
+		errs = append(errs, fmt.Errorf("%s.split.canary_uuids requires an arm with canary: true", path))
+	}
+	return errs
+}
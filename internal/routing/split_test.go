@@ -0,0 +1,75 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func splitRoute() Route {
+	return Route{
+		Match: Match{Hostname: "play.example.com"},
+		Split: &Split{
+			CanaryUUIDs: []string{"AAAAAAAA-0000-0000-0000-000000000001"},
+			Arms: []SplitArm{
+				{Name: "stable", Weight: 90, Pool: Pool{Strategy: "round_robin", Backends: []Backend{{Host: "stable", Port: 1}}}},
+				{Name: "canary", Weight: 10, Canary: true, Pool: Pool{Strategy: "round_robin", Backends: []Backend{{Host: "canary", Port: 1}}}},
+			},
+		},
+	}
+}
+
+func TestStaticEngineDecide_Split(t *testing.T) {
+	e := NewStaticEngine(Config{Routes: []Route{splitRoute()}})
+	ctx := context.Background()
+
+	counts := map[string]int{}
+	for i := 0; i < 2000; i++ {
+		req := Request{SNI: "play.example.com", UUID: fmt.Sprintf("uuid-%d", i)}
+		d, err := e.Decide(ctx, req)
+		if err != nil {
+			t.Fatalf("Decide: %v", err)
+		}
+		if d.SplitArm != d.Backend.Host {
+			t.Fatalf("decision=%+v", d)
+		}
+		again, _ := e.Decide(ctx, req)
+		if again.SplitArm != d.SplitArm {
+			t.Fatalf("player moved from %q to %q", d.SplitArm, again.SplitArm)
+		}
+		counts[d.SplitArm]++
+	}
+	if c := counts["canary"]; c < 120 || c > 280 {
+		t.Fatalf("canary got %d of 2000 players", c)
+	}
+
+	d, err := e.Decide(ctx, Request{SNI: "play.example.com", UUID: "aaaaaaaa-0000-0000-0000-000000000001"})
+	if err != nil || d.SplitArm != "canary" {
+		t.Fatalf("override: decision=%+v err=%v", d, err)
+	}
+
+	cands, err := e.Candidates(ctx, 0)
+	if err != nil || len(cands) != 2 {
+		t.Fatalf("Candidates=%v err=%v", cands, err)
+	}
+}
+
+func TestValidateSplit(t *testing.T) {
+	cfg := Config{Routes: []Route{splitRoute()}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	r := splitRoute()
+	r.Pool = Pool{Strategy: "round_robin", Backends: []Backend{{Host: "a", Port: 1}}}
+	r.Split.Arms[0].Canary = true
+	r.Split.Arms[1].Name = "stable"
+	r.Split.Arms[1].Weight = -1
+	err := (&Config{Routes: []Route{r}}).Validate()
+	for _, want := range []string{"mutually exclusive", "used by another arm", "weight must be >= 0", "at most one canary"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in %v", want, err)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package routing
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 )
 
@@ -56,3 +58,267 @@ func TestStaticEngineDecide_P2C(t *testing.T) {
 		t.Fatalf("selected_index=%d candidates=%d", dec.SelectedIndex, len(dec.Candidates))
 	}
 }
+
+func TestStaticEngine_InheritCounters(t *testing.T) {
+	cfg := Config{Routes: []Route{{
+		Match: Match{Hostname: "x"},
+		Pool:  Pool{Strategy: "round_robin", Backends: []Backend{{Host: "a", Port: 1}, {Host: "b", Port: 2}, {Host: "c", Port: 3}}},
+	}}}
+	old := NewStaticEngine(cfg)
+	if _, err := old.Decide(context.Background(), Request{SNI: "x"}); err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+
+	e := NewStaticEngine(cfg)
+	e.InheritCounters(old)
+	dec, err := e.Decide(context.Background(), Request{SNI: "x"})
+	if err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+	if dec.Backend.Host != "b" {
+		t.Fatalf("expected rotation to continue at b, got %#v", dec.Backend)
+	}
+
+	e.InheritCounters(nil)
+	NewStaticEngine(Config{}).InheritCounters(old)
+}
+
+func TestStaticEngine_InheritCountersFollowsReorderedRoutes(t *testing.T) {
+	x := Route{
+		Match: Match{Hostname: "x"},
+		Pool:  Pool{Strategy: "round_robin", Backends: []Backend{{Host: "a", Port: 1}, {Host: "b", Port: 2}, {Host: "c", Port: 3}}},
+	}
+	y := Route{
+		Match: Match{Hostname: "y"},
+		Pool:  Pool{Strategy: "round_robin", Backends: []Backend{{Host: "d", Port: 1}, {Host: "e", Port: 2}, {Host: "f", Port: 3}}},
+	}
+	old := NewStaticEngine(Config{Routes: []Route{x, y}})
+	for range 2 {
+		if _, err := old.Decide(context.Background(), Request{SNI: "x"}); err != nil {
+			t.Fatalf("Decide: %v", err)
+		}
+	}
+
+	e := NewStaticEngine(Config{Routes: []Route{y, x}})
+	e.InheritCounters(old)
+	for sni, want := range map[string]string{"x": "c", "y": "d"} {
+		dec, err := e.Decide(context.Background(), Request{SNI: sni})
+		if err != nil {
+			t.Fatalf("Decide: %v", err)
+		}
+		if dec.Backend.Host != want {
+			t.Fatalf("%s: expected %s, got %#v", sni, want, dec.Backend)
+		}
+	}
+}
+
+func TestStaticEngineDecide_Maintenance(t *testing.T) {
+	e := NewStaticEngine(Config{
+		Routes:  []Route{{Match: Match{Hostname: "x"}, Pool: Pool{Strategy: "round_robin", Backends: []Backend{{Host: "a", Port: 1}}}}},
+		Default: &Pool{Strategy: "round_robin", Backends: []Backend{{Host: "b", Port: 1}}},
+	})
+	e.SetMaintenance(func(routeIndex int) bool { return routeIndex == 0 })
+
+	dec, err := e.Decide(context.Background(), Request{SNI: "x"})
+	if !errors.Is(err, ErrMaintenance) {
+		t.Fatalf("expected ErrMaintenance, got %v", err)
+	}
+	if !dec.Matched || dec.RouteIndex != 0 || dec.Backend.Host != "" {
+		t.Fatalf("dec=%#v", dec)
+	}
+
+	dec, err = e.Decide(context.Background(), Request{SNI: "other"})
+	if err != nil || dec.Backend.Host != "b" {
+		t.Fatalf("dec=%#v err=%v", dec, err)
+	}
+
+	e.SetMaintenance(func(routeIndex int) bool { return routeIndex == -1 })
+	if _, err := e.Decide(context.Background(), Request{SNI: "other"}); !errors.Is(err, ErrMaintenance) {
+		t.Fatalf("expected ErrMaintenance for default route, got %v", err)
+	}
+}
+
+func hashBackendsForTest(n int) []Backend {
+	out := make([]Backend, 0, n)
+	for i := 0; i < n; i++ {
+		out = append(out, Backend{Host: fmt.Sprintf("shard-%d", i), Port: 5520})
+	}
+	return out
+}
+
+func TestStaticEngineDecide_HashStrategiesAreSticky(t *testing.T) {
+	for _, strategy := range []string{"consistent_hash", "rendezvous"} {
+		t.Run(strategy, func(t *testing.T) {
+			e := NewStaticEngine(Config{Default: &Pool{Strategy: strategy, Backends: hashBackendsForTest(5)}})
+			first, err := e.Decide(context.Background(), Request{UUID: "player-1"})
+			if err != nil {
+				t.Fatalf("Decide: %v", err)
+			}
+			for i := 0; i < 20; i++ {
+				dec, err := e.Decide(context.Background(), Request{UUID: "player-1"})
+				if err != nil {
+					t.Fatalf("Decide: %v", err)
+				}
+				if dec.Backend.Target() != first.Backend.Target() {
+					t.Fatalf("expected %v, got %v", first.Backend.Target(), dec.Backend.Target())
+				}
+			}
+
+			// Candidate order must not matter.
+			reversed := hashBackendsForTest(5)
+			for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+				reversed[i], reversed[j] = reversed[j], reversed[i]
+			}
+			e2 := NewStaticEngine(Config{Default: &Pool{Strategy: strategy, Backends: reversed}})
+			dec, err := e2.Decide(context.Background(), Request{UUID: "player-1"})
+			if err != nil {
+				t.Fatalf("Decide: %v", err)
+			}
+			if dec.Backend.Target() != first.Backend.Target() {
+				t.Fatalf("expected %v, got %v", first.Backend.Target(), dec.Backend.Target())
+			}
+		})
+	}
+}
+
+func TestStaticEngineDecide_HashStrategiesMoveFewPlayers(t *testing.T) {
+	const players = 2000
+	for _, strategy := range []string{"consistent_hash", "rendezvous"} {
+		t.Run(strategy, func(t *testing.T) {
+			before := NewStaticEngine(Config{Default: &Pool{Strategy: strategy, Backends: hashBackendsForTest(10)}})
+			after := NewStaticEngine(Config{Default: &Pool{Strategy: strategy, Backends: hashBackendsForTest(11)}})
+			moved := 0
+			counts := map[Target]int{}
+			for i := 0; i < players; i++ {
+				req := Request{UUID: fmt.Sprintf("uuid-%d", i)}
+				a, err := before.Decide(context.Background(), req)
+				if err != nil {
+					t.Fatalf("Decide: %v", err)
+				}
+				b, err := after.Decide(context.Background(), req)
+				if err != nil {
+					t.Fatalf("Decide: %v", err)
+				}
+				counts[a.Backend.Target()]++
+				if a.Backend.Target() != b.Backend.Target() {
+					moved++
+					if b.Backend.Host != "shard-10" {
+						t.Fatalf("player moved between existing backends: %v -> %v", a.Backend.Target(), b.Backend.Target())
+					}
+				}
+			}
+			// Ideal is players/11 (~182).
+			if moved < 90 || moved > 320 {
+				t.Fatalf("moved=%d", moved)
+			}
+			for tgt, n := range counts {
+				if n < 100 || n > 320 {
+					t.Fatalf("unbalanced: %v has %d of %d players", tgt, n, players)
+				}
+			}
+		})
+	}
+}
+
+func TestStaticEngineDecide_HashStrategiesAreWeightAware(t *testing.T) {
+	for _, strategy := range []string{"consistent_hash", "rendezvous"} {
+		t.Run(strategy, func(t *testing.T) {
+			e := NewStaticEngine(Config{Default: &Pool{Strategy: strategy, Backends: []Backend{
+				{Host: "big", Port: 1, Weight: 3},
+				{Host: "small", Port: 1, Weight: 1},
+			}}})
+			big := 0
+			for i := 0; i < 2000; i++ {
+				dec, err := e.Decide(context.Background(), Request{UUID: fmt.Sprintf("uuid-%d", i)})
+				if err != nil {
+					t.Fatalf("Decide: %v", err)
+				}
+				if dec.Backend.Host == "big" {
+					big++
+				}
+			}
+			if big < 1300 || big > 1700 {
+				t.Fatalf("big=%d of 2000, expected ~1500", big)
+			}
+		})
+	}
+}
+
+func TestNewHashRing_BoundsLargeWeights(t *testing.T) {
+	// Weights are reduced by their common divisor: 200 and 100 build the same ring as 2 and 1.
+	scaled := newHashRing([]Backend{{Host: "a", Port: 1, Weight: 200}, {Host: "b", Port: 1, Weight: 100}}, 0)
+	small := newHashRing([]Backend{{Host: "a", Port: 1, Weight: 2}, {Host: "b", Port: 1, Weight: 1}}, 0)
+	if len(scaled.points) != 300 || len(small.points) != 300 {
+		t.Fatalf("points=%d/%d, want 300", len(scaled.points), len(small.points))
+	}
+
+	// A weight of 65535 next to weight 1 is scaled down to the ring limit, and the light
+	// backend keeps a point.
+	r := newHashRing([]Backend{{Host: "srv", Port: 1, Weight: 65535}, {Host: "light", Port: 1, Weight: 1}}, 0)
+	if len(r.points) > maxRingPoints {
+		t.Fatalf("points=%d, want at most %d", len(r.points), maxRingPoints)
+	}
+	light := 0
+	for _, p := range r.points {
+		if p.target == "light:1" {
+			light++
+		}
+	}
+	if light < 1 {
+		t.Fatalf("light backend lost its ring points")
+	}
+
+	// A fleet with uneven large weights is scaled as a whole and stays proportional.
+	fleet := make([]Backend, 0, 100)
+	for i := 0; i < 100; i++ {
+		fleet = append(fleet, Backend{Host: fmt.Sprintf("b%d", i), Port: 1, Weight: 100 + i%2})
+	}
+	counts := ringPointCounts(fleet, defaultVirtualNodes)
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	if total > maxRingPoints || counts[1] <= counts[0] {
+		t.Fatalf("total=%d counts[0]=%d counts[1]=%d", total, counts[0], counts[1])
+	}
+}
+
+func TestHashKeyValue(t *testing.T) {
+	req := Request{SNI: "Play.Example.com.", UUID: "u", Username: "n", ClientIP: "203.0.113.7"}
+	cases := map[string]string{"": "u", "uuid": "u", "username": "n", "sni": "play.example.com", "client_ip": "203.0.113.7", "client-ip": "203.0.113.7"}
+	for key, want := range cases {
+		if got := hashKeyValue(req, key); got != want {
+			t.Fatalf("key %q: got %q want %q", key, got, want)
+		}
+	}
+}
+
+func TestConfigValidate_HashStrategyKey(t *testing.T) {
+	cfg := Config{Default: &Pool{Strategy: "rendezvous", Key: "client_ip", Backends: []Backend{{Host: "a", Port: 1}}}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if err := cfg.LintKeys(); err != nil {
+		t.Fatalf("LintKeys: %v", err)
+	}
+	cfg.Default.Strategy = "consistent-hash"
+	cfg.Default.Key = "counter:players"
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected error for key %q", cfg.Default.Key)
+	}
+}
+
+func TestRouteKeys(t *testing.T) {
+	keys := RouteKeys([]Route{
+		{Match: Match{Hostname: "A.example.com", Hostnames: []string{"b.example.com"}}},
+		{Match: Match{Hostnames: []string{"b.example.com", "a.example.com"}}},
+		{Match: Match{Hostname: "c.example.com"}},
+	})
+	// Hostname case and order do not change the identity; duplicates are numbered.
+	if keys[1] != keys[0]+"#1" || keys[2] == keys[0] {
+		t.Fatalf("keys=%q", keys)
+	}
+	if e := NewStaticEngine(Config{Routes: []Route{{Match: Match{Hostname: "c.example.com"}}}}); e.RouteKey(0) != keys[2] || e.RouteKey(-1) != DefaultRouteKey {
+		t.Fatalf("RouteKey(0)=%q", e.RouteKey(0))
+	}
+}
@@ -0,0 +1,139 @@
+package routing
+
+import (
+	"sync/atomic"
+)
+
+// Trace records how a decision was made. It is filled by StaticEngine.Explain.
+type Trace struct {
+	SNI         string            `json:"sni"`
+	Route       string            `json:"route,omitempty"`
+	Matched     bool              `json:"matched"`
+	RouteIndex  int               `json:"route_index"`
+	Pattern     string            `json:"pattern,omitempty"`
+	Captures    map[string]string `json:"captures,omitempty"`
+	SplitArm    string            `json:"split_arm,omitempty"`
+	Maintenance bool              `json:"maintenance,omitempty"`
+	Candidates  []Backend         `json:"candidates,omitempty"`
+	Attempts    []TraceAttempt    `json:"attempts,omitempty"`
+	Step        string            `json:"step,omitempty"`
+	Error       string            `json:"error,omitempty"`
+}
+
+// TraceAttempt is one selection pass: the pool itself or one of its fallback steps.
+type TraceAttempt struct {
+	Step          string         `json:"step"`
+	Strategy      string         `json:"strategy"`
+	Filters       []Filter       `json:"filters,omitempty"`
+	Backends      []TraceBackend `json:"backends,omitempty"`
+	Sort          []SortKey      `json:"sort,omitempty"`
+	Sorted        []Target       `json:"sorted,omitempty"`
+	Limit         int            `json:"limit,omitempty"`
+	Truncated     []Target       `json:"truncated,omitempty"`
+	SelectedIndex int            `json:"selected_index"`
+	Error         string         `json:"error,omitempty"`
+}
+
+// TraceBackend holds the result of every filter (in order) for one candidate.
+type TraceBackend struct {
+	Target  Target `json:"target"`
+	Filters []bool `json:"filters,omitempty"`
+	Pass    bool   `json:"pass"`
+}
+
+// traceAttempt is a handle to the attempt being recorded. All methods are no-ops when tracing is off.
+type traceAttempt struct {
+	tr  *Trace
+	idx int
+}
+
+// counter returns the round-robin counter to use. Explain works on a copy so rotation is not advanced.
+func (tr *Trace) counter(rr *atomic.Uint64) *atomic.Uint64 {
+	if tr == nil {
+		return rr
+	}
+	c := &atomic.Uint64{}
+	c.Store(rr.Load())
+	return c
+}
+
+func (tr *Trace) setCandidates(backends []Backend) {
+	if tr == nil {
+		return
+	}
+	tr.Candidates = append([]Backend(nil), backends...)
+}
+
+func (tr *Trace) beginAttempt(step string, cfg selectionConfig) traceAttempt {
+	if tr == nil {
+		return traceAttempt{}
+	}
+	tr.Attempts = append(tr.Attempts, TraceAttempt{
+		Step:          step,
+		Strategy:      cfg.Strategy,
+		Filters:       cfg.Filters,
+		Sort:          cfg.Sort,
+		Limit:         cfg.Limit,
+		SelectedIndex: -1,
+	})
+	return traceAttempt{tr: tr, idx: len(tr.Attempts) - 1}
+}
+
+func (a traceAttempt) get() *TraceAttempt {
+	if a.tr == nil {
+		return nil
+	}
+	return &a.tr.Attempts[a.idx]
+}
+
+func (a traceAttempt) filters(req Request, backends []Backend, filters []Filter, match func(Request, Backend, Filter) bool) {
+	at := a.get()
+	if at == nil {
+		return
+	}
+	for _, b := range backends {
+		tb := TraceBackend{Target: b.Target(), Pass: true}
+		for _, f := range filters {
+			ok := match(req, b, f)
+			tb.Filters = append(tb.Filters, ok)
+			if !ok {
+				tb.Pass = false
+			}
+		}
+		at.Backends = append(at.Backends, tb)
+	}
+}
+
+func (a traceAttempt) sorted(backends []Backend) {
+	if at := a.get(); at != nil {
+		at.Sorted = targets(backends)
+	}
+}
+
+func (a traceAttempt) truncated(backends []Backend) {
+	if at := a.get(); at != nil {
+		at.Truncated = targets(backends)
+	}
+}
+
+func (a traceAttempt) selected(idx int) {
+	if at := a.get(); at != nil {
+		at.SelectedIndex = idx
+		a.tr.Step = at.Step
+	}
+}
+
+func (a traceAttempt) fail(err error) error {
+	if at := a.get(); at != nil {
+		at.Error = err.Error()
+	}
+	return err
+}
+
+func targets(backends []Backend) []Target {
+	out := make([]Target, 0, len(backends))
+	for _, b := range backends {
+		out = append(out, b.Target())
+	}
+	return out
+}
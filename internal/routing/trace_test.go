@@ -0,0 +1,73 @@
+package routing
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStaticEngineExplain(t *testing.T) {
+	strategy := "round_robin"
+	e := NewStaticEngine(Config{Routes: []Route{{
+		Match: Match{Hostname: "*.example.com"},
+		Pool: Pool{
+			Strategy: "round_robin",
+			Filters:  []Filter{{Type: "compare", Left: "counter:players.count", Op: "lt", Right: "counter:players.capacity"}},
+			Fallback: []Fallback{{Strategy: &strategy, Filters: []Filter{}}},
+			Sort:     []SortKey{{Key: "counter:players.count", Type: "number"}},
+			Limit:    2,
+			Backends: []Backend{
+				{Host: "a", Port: 1, Meta: map[string]string{"counter.players.count": "3", "counter.players.capacity": "3"}},
+				{Host: "b", Port: 2, Meta: map[string]string{"counter.players.count": "1", "counter.players.capacity": "1"}},
+				{Host: "c", Port: 3, Meta: map[string]string{"counter.players.count": "2", "counter.players.capacity": "2"}},
+			},
+		},
+	}}})
+
+	dec, tr, err := e.Explain(context.Background(), Request{SNI: "play.example.com"})
+	if err != nil {
+		t.Fatalf("Explain: %v", err)
+	}
+	if !tr.Matched || tr.RouteIndex != 0 || tr.Pattern != "*.example.com" || tr.Route != "routing.routes[0]" {
+		t.Fatalf("trace=%#v", tr)
+	}
+	if len(tr.Attempts) != 2 || tr.Step != "fallback[0]" {
+		t.Fatalf("attempts=%#v step=%q", tr.Attempts, tr.Step)
+	}
+	pool := tr.Attempts[0]
+	if pool.Error == "" || len(pool.Backends) != 3 || pool.Backends[0].Pass || pool.Backends[0].Filters[0] {
+		t.Fatalf("pool attempt=%#v", pool)
+	}
+	fb := tr.Attempts[1]
+	if len(fb.Sorted) != 3 || fb.Sorted[0].Host != "b" || fb.Sorted[1].Host != "c" {
+		t.Fatalf("sorted=%#v", fb.Sorted)
+	}
+	if len(fb.Truncated) != 1 || fb.Truncated[0].Host != "a" {
+		t.Fatalf("truncated=%#v", fb.Truncated)
+	}
+	if fb.SelectedIndex != 0 || dec.Backend.Host != "b" {
+		t.Fatalf("selected=%d backend=%#v", fb.SelectedIndex, dec.Backend)
+	}
+
+	// Explain must not advance round-robin rotation.
+	for i := 0; i < 2; i++ {
+		dec, _, _ = e.Explain(context.Background(), Request{SNI: "play.example.com"})
+		if dec.Backend.Host != "b" {
+			t.Fatalf("backend=%#v", dec.Backend)
+		}
+	}
+	dec, err = e.Decide(context.Background(), Request{SNI: "play.example.com"})
+	if err != nil || dec.Backend.Host != "b" {
+		t.Fatalf("dec=%#v err=%v", dec, err)
+	}
+}
+
+func TestStaticEngineExplain_NoRoute(t *testing.T) {
+	e := NewStaticEngine(Config{})
+	_, tr, err := e.Explain(context.Background(), Request{SNI: "x"})
+	if err != nil {
+		t.Fatalf("Explain: %v", err)
+	}
+	if tr.Matched || tr.Route != "" || len(tr.Attempts) != 0 {
+		t.Fatalf("trace=%#v", tr)
+	}
+}
@@ -3,9 +3,12 @@ package routing
 import (
 	"context"
 	"math/rand"
+	"regexp"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/hybrowse/hyrouter/internal/access"
 )
 
 type Target struct {
@@ -25,26 +28,44 @@ func (b Backend) Target() Target {
 }
 
 type Pool struct {
-	Strategy  string     `json:"strategy" yaml:"strategy"`
-	Key       string     `json:"key" yaml:"key"`
-	Sample    int        `json:"sample" yaml:"sample"`
-	Sort      []SortKey  `json:"sort" yaml:"sort"`
-	Limit     int        `json:"limit" yaml:"limit"`
-	Filters   []Filter   `json:"filters" yaml:"filters"`
-	Fallback  []Fallback `json:"fallback" yaml:"fallback"`
-	Backends  []Backend  `json:"backends" yaml:"backends"`
-	Discovery *Discovery `json:"discovery" yaml:"discovery"`
+	Strategy     string       `json:"strategy" yaml:"strategy"`
+	Key          string       `json:"key" yaml:"key"`
+	Sample       int          `json:"sample" yaml:"sample"`
+	VirtualNodes int          `json:"virtual_nodes" yaml:"virtual_nodes"`
+	Utilization  *Utilization `json:"utilization" yaml:"utilization"`
+	// CountRecentReferrals adds the referrals recently sent to a backend to its load.
+	CountRecentReferrals bool       `json:"count_recent_referrals" yaml:"count_recent_referrals"`
+	Sort                 []SortKey  `json:"sort" yaml:"sort"`
+	Limit                int        `json:"limit" yaml:"limit"`
+	Filters              []Filter   `json:"filters" yaml:"filters"`
+	Fallback             []Fallback `json:"fallback" yaml:"fallback"`
+	Backends             []Backend  `json:"backends" yaml:"backends"`
+	Discovery            *Discovery `json:"discovery" yaml:"discovery"`
+	// Target computes the backend from the connection instead of listing it.
+	Target *TargetTemplate `json:"target" yaml:"target"`
+	// Schedule limits the pool to time windows; outside them it has no backends.
+	Schedule *Schedule `json:"schedule" yaml:"schedule"`
+}
+
+// TargetTemplate is a backend whose host may use ${sni} and ${host.<name>} hostname captures.
+type TargetTemplate struct {
+	Host string `json:"host" yaml:"host"`
+	Port int    `json:"port" yaml:"port"`
 }
 
 type Discovery struct {
 	Provider string `json:"provider" yaml:"provider"`
 	Mode     string `json:"mode" yaml:"mode"`
+	// Selector keeps discovered backends whose meta values equal the given values (after ${host.<name>} expansion).
+	Selector map[string]string `json:"selector" yaml:"selector"`
 }
 
 type SortKey struct {
 	Key   string `json:"key" yaml:"key"`
 	Order string `json:"order" yaml:"order"`
 	Type  string `json:"type" yaml:"type"`
+	// Expr is a CEL expression used instead of Key; numeric results sort as numbers.
+	Expr string `json:"expr" yaml:"expr"`
 }
 
 type Filter struct {
@@ -56,25 +77,86 @@ type Filter struct {
 	EnabledKey string `json:"enabled_key" yaml:"enabled_key"`
 	ListKey    string `json:"list_key" yaml:"list_key"`
 	Key        string `json:"key" yaml:"key"`
+	Value      string `json:"value" yaml:"value"`
+	// Expr is the CEL expression of the expr filter.
+	Expr string `json:"expr" yaml:"expr"`
+	// Values is the list of the string filter's "in" operator.
+	Values []string `json:"values" yaml:"values"`
+	// Filters are the members of the not, any and all groups.
+	Filters []Filter `json:"filters" yaml:"filters"`
 }
 
 type Fallback struct {
-	Strategy *string   `json:"strategy" yaml:"strategy"`
-	Key      *string   `json:"key" yaml:"key"`
-	Sample   *int      `json:"sample" yaml:"sample"`
-	Sort     []SortKey `json:"sort" yaml:"sort"`
-	Limit    *int      `json:"limit" yaml:"limit"`
-	Filters  []Filter  `json:"filters" yaml:"filters"`
+	Strategy             *string      `json:"strategy" yaml:"strategy"`
+	Key                  *string      `json:"key" yaml:"key"`
+	Sample               *int         `json:"sample" yaml:"sample"`
+	VirtualNodes         *int         `json:"virtual_nodes" yaml:"virtual_nodes"`
+	Utilization          *Utilization `json:"utilization" yaml:"utilization"`
+	CountRecentReferrals *bool        `json:"count_recent_referrals" yaml:"count_recent_referrals"`
+	Sort                 []SortKey    `json:"sort" yaml:"sort"`
+	Limit                *int         `json:"limit" yaml:"limit"`
+	Filters              []Filter     `json:"filters" yaml:"filters"`
 }
 
+// Match selects the connections a route handles. All conditions that are set must match.
+// Hostnames are matched against the SNI; the other conditions (except alpn and source_cidrs)
+// need the Connect packet.
 type Match struct {
-	Hostname  string   `json:"hostname" yaml:"hostname"`
-	Hostnames []string `json:"hostnames" yaml:"hostnames"`
+	Hostname            string        `json:"hostname" yaml:"hostname"`
+	Hostnames           []string      `json:"hostnames" yaml:"hostnames"`
+	HostnameRegex       string        `json:"hostname_regex" yaml:"hostname_regex"`
+	HostnameRegexes     []string      `json:"hostname_regexes" yaml:"hostname_regexes"`
+	ALPN                []string      `json:"alpn" yaml:"alpn"`
+	SourceCIDRs         []string      `json:"source_cidrs" yaml:"source_cidrs"`
+	Languages           []string      `json:"languages" yaml:"languages"`
+	ClientTypes         []int         `json:"client_types" yaml:"client_types"`
+	ProtocolHashes      []string      `json:"protocol_hashes" yaml:"protocol_hashes"`
+	ProtocolBuildNumber *NumberRange  `json:"protocol_build_number" yaml:"protocol_build_number"`
+	ClientVersion       *VersionRange `json:"client_version" yaml:"client_version"`
+	Usernames           []string      `json:"usernames" yaml:"usernames"`
+	UUIDs               []string      `json:"uuids" yaml:"uuids"`
+	// All must all match; at least one of Any must match.
+	All []Match `json:"all" yaml:"all"`
+	Any []Match `json:"any" yaml:"any"`
+}
+
+type NumberRange struct {
+	GTE int `json:"gte" yaml:"gte"`
+	LT  int `json:"lt" yaml:"lt"`
+}
+
+type VersionRange struct {
+	GTE string `json:"gte" yaml:"gte"`
+	LT  string `json:"lt" yaml:"lt"`
 }
 
 type Route struct {
-	Match Match `json:"match" yaml:"match"`
-	Pool  Pool  `json:"pool" yaml:"pool"`
+	Match    Match         `json:"match" yaml:"match"`
+	Pool     Pool          `json:"pool" yaml:"pool"`
+	Access   *access.Rules `json:"access" yaml:"access"`
+	Priority int           `json:"priority" yaml:"priority"`
+	// ReferralContent is the initial referral content; ${host.<name>} is replaced with hostname captures.
+	ReferralContent string `json:"referral_content" yaml:"referral_content"`
+	// Split divides the connections of the route between several pools instead of using Pool.
+	Split *Split `json:"split" yaml:"split"`
+	// Schedule limits the route to time windows; outside them it does not match.
+	Schedule *Schedule `json:"schedule" yaml:"schedule"`
+	// Maintenance denies every connection with a maintenance Disconnect while a window is active.
+	Maintenance *Maintenance `json:"maintenance" yaml:"maintenance"`
+}
+
+// Split divides matched connections between arms by weight, keyed on a hash of the player UUID.
+type Split struct {
+	Arms []SplitArm `json:"arms" yaml:"arms"`
+	// CanaryUUIDs are always sent to the canary arm.
+	CanaryUUIDs []string `json:"canary_uuids" yaml:"canary_uuids"`
+}
+
+type SplitArm struct {
+	Name   string `json:"name" yaml:"name"`
+	Weight int    `json:"weight" yaml:"weight"`
+	Canary bool   `json:"canary" yaml:"canary"`
+	Pool   Pool   `json:"pool" yaml:"pool"`
 }
 
 type Config struct {
@@ -87,6 +169,16 @@ type Request struct {
 	UUID     string
 	Username string
 	Language string
+	ClientIP string
+	ALPN     string
+
+	ProtocolHash        string
+	ProtocolBuildNumber int
+	ClientVersion       string
+	ClientType          *uint8
+
+	// Captures holds the named groups of the hostname regex that matched. It is set by the engine.
+	Captures map[string]string
 }
 
 type Decision struct {
@@ -96,6 +188,11 @@ type Decision struct {
 	Candidates    []Backend `json:"candidates"`
 	SelectedIndex int       `json:"selected_index"`
 	Backend       Backend   `json:"backend"`
+
+	Captures        map[string]string `json:"captures,omitempty"`
+	ReferralContent []byte            `json:"referral_content,omitempty"`
+	// SplitArm is the name of the split arm that handled the connection, if the route has a split.
+	SplitArm string `json:"split_arm,omitempty"`
 }
 
 type Engine interface {
@@ -103,22 +200,93 @@ type Engine interface {
 }
 
 type StaticEngine struct {
-	cfg       Config
-	rr        []atomic.Uint64
-	rrDefault atomic.Uint64
-	rngMu     sync.Mutex
-	rng       *rand.Rand
-	discovery func(ctx context.Context, provider string) ([]Backend, error)
+	cfg         Config
+	rr          []atomic.Uint64
+	rrDefault   atomic.Uint64
+	rrSplit     [][]atomic.Uint64
+	rngMu       sync.Mutex
+	rng         *rand.Rand
+	discovery   func(ctx context.Context, provider string) ([]Backend, error)
+	excluded    func(t Target) bool
+	maintenance func(routeIndex int) bool
+	rings       ringCache
+	index       *routeIndex
+	keys        []string
+	now         func() time.Time
+	schedules   map[*Schedule]*compiledSchedule
+	exprs       map[exprKey]*exprProgram
+	regexes     map[string]*regexp.Regexp
+
+	health         func(t Target) (Health, bool)
+	healthFailOpen bool
+	recent         func(t Target) int
 }
 
 func NewStaticEngine(cfg Config) *StaticEngine {
-	return &StaticEngine{
-		cfg: cfg,
-		rr:  make([]atomic.Uint64, len(cfg.Routes)),
-		rng: rand.New(rand.NewSource(time.Now().UnixNano())),
+	e := &StaticEngine{
+		cfg:     cfg,
+		rr:      make([]atomic.Uint64, len(cfg.Routes)),
+		rrSplit: make([][]atomic.Uint64, len(cfg.Routes)),
+		rng:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		index:   newRouteIndex(cfg.Routes),
+		keys:    RouteKeys(cfg.Routes),
+		now:     time.Now,
+	}
+	for i, r := range cfg.Routes {
+		if r.Split != nil {
+			e.rrSplit[i] = make([]atomic.Uint64, len(r.Split.Arms))
+		}
 	}
+	e.schedules = compileSchedules(cfg)
+	e.exprs = compileExprs(cfg)
+	e.regexes = compileRegexes(cfg)
+	return e
 }
 
 func (e *StaticEngine) SetDiscovery(fn func(ctx context.Context, provider string) ([]Backend, error)) {
 	e.discovery = fn
 }
+
+// SetExcluded registers a predicate for backends that must never be selected (e.g. drained backends).
+func (e *StaticEngine) SetExcluded(fn func(t Target) bool) {
+	e.excluded = fn
+}
+
+// SetMaintenance registers a predicate for routes that deny every connection with ErrMaintenance.
+// The default route uses index -1.
+func (e *StaticEngine) SetMaintenance(fn func(routeIndex int) bool) {
+	e.maintenance = fn
+}
+
+// InheritCounters copies the round-robin positions of old into e so that a config reload
+// does not restart rotation from the first backend. Routes are matched by RouteKey, so
+// reordered routes keep their positions, and split arms are matched by name.
+func (e *StaticEngine) InheritCounters(old *StaticEngine) {
+	if e == nil || old == nil {
+		return
+	}
+	oldIndex := make(map[string]int, len(old.keys))
+	for i, k := range old.keys {
+		oldIndex[k] = i
+	}
+	for i := range e.rr {
+		j, ok := oldIndex[e.keys[i]]
+		if !ok {
+			continue
+		}
+		e.rr[i].Store(old.rr[j].Load())
+		if e.cfg.Routes[i].Split == nil || old.cfg.Routes[j].Split == nil {
+			continue
+		}
+		oldArms := make(map[string]int, len(old.cfg.Routes[j].Split.Arms))
+		for a, arm := range old.cfg.Routes[j].Split.Arms {
+			oldArms[arm.Name] = a
+		}
+		for a, arm := range e.cfg.Routes[i].Split.Arms {
+			if oa, ok := oldArms[arm.Name]; ok {
+				e.rrSplit[i][a].Store(old.rrSplit[j][oa].Load())
+			}
+		}
+	}
+	e.rrDefault.Store(old.rrDefault.Load())
+}
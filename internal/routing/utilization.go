@@ -0,0 +1,148 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// defaultUtilizationKey is the Agones counter used by least_utilization if the pool sets no key.
+const defaultUtilizationKey = "counter:players"
+
+// Utilization configures the least_utilization strategy.
+type Utilization struct {
+	// Mode is "spread" (lowest utilization, the default) or "fill_first" (highest utilization
+	// below Threshold, packing players onto as few backends as possible).
+	Mode string `json:"mode" yaml:"mode"`
+	// Threshold is the utilization (0-1] at which a backend counts as full (default: 1).
+	Threshold float64 `json:"threshold" yaml:"threshold"`
+}
+
+// utilizationOf returns count/capacity of b for key ("counter:<name>" or "list:<name>") and the
+// number of free slots. If countRecent is set, recent referrals count as used slots. ok is false if
+// the capacity is missing or not positive.
+func utilizationOf(b Backend, key string, countRecent bool) (float64, float64, bool) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		key = defaultUtilizationKey
+	}
+	var count, capacity float64
+	var err error
+	switch {
+	case strings.HasPrefix(key, "counter:"):
+		name := "counter." + strings.TrimSpace(strings.TrimPrefix(key, "counter:"))
+		if capacity, err = strconv.ParseFloat(metaGet(b.Meta, name+".capacity"), 64); err != nil {
+			return 0, 0, false
+		}
+		if count, err = strconv.ParseFloat(metaGet(b.Meta, name+".count"), 64); err != nil {
+			return 0, 0, false
+		}
+	case strings.HasPrefix(key, "list:"):
+		name := "list." + strings.TrimSpace(strings.TrimPrefix(key, "list:"))
+		if capacity, err = strconv.ParseFloat(metaGet(b.Meta, name+".capacity"), 64); err != nil {
+			return 0, 0, false
+		}
+		// Agones omits the values of empty lists.
+		count = float64(listLen(metaGet(b.Meta, name+".values")))
+	default:
+		return 0, 0, false
+	}
+	if capacity <= 0 {
+		return 0, 0, false
+	}
+	if countRecent {
+		count += float64(recentReferrals(b))
+	}
+	return count / capacity, capacity - count, true
+}
+
+// listLen counts the entries of a JSON array or comma-separated list.
+func listLen(raw string) int {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0
+	}
+	if strings.HasPrefix(raw, "[") {
+		var xs []string
+		if err := json.Unmarshal([]byte(raw), &xs); err != nil {
+			return 0
+		}
+		return len(xs)
+	}
+	return len(strings.Split(raw, ","))
+}
+
+// leastUtilizationIndex picks a backend by count/capacity. Backends at or above the threshold are
+// only used if every backend is; backends without capacity data only if none has any, in which
+// case the pick falls back to round robin.
+func leastUtilizationIndex(backends []Backend, key string, u *Utilization, countRecent bool, rr *atomic.Uint64) int {
+	mode, threshold := "spread", 1.0
+	if u != nil {
+		if m := normalizeStrategy(u.Mode); m != "" {
+			mode = m
+		}
+		if u.Threshold > 0 {
+			threshold = u.Threshold
+		}
+	}
+	below, lowest := -1, -1
+	var belowUtil, belowFree, lowestUtil, lowestFree float64
+	for i, b := range backends {
+		util, free, ok := utilizationOf(b, key, countRecent)
+		if !ok {
+			continue
+		}
+		// Spread prefers the lowest utilization and fill_first the highest; ties go to the
+		// backend with more free slots.
+		if lowest < 0 || util < lowestUtil || (util == lowestUtil && free > lowestFree) {
+			lowest, lowestUtil, lowestFree = i, util, free
+		}
+		if util >= threshold {
+			continue
+		}
+		better := util < belowUtil
+		if mode == "fill_first" {
+			better = util > belowUtil
+		}
+		if below < 0 || better || (util == belowUtil && free > belowFree) {
+			below, belowUtil, belowFree = i, util, free
+		}
+	}
+	switch {
+	case below >= 0:
+		return below
+	case lowest >= 0:
+		return lowest
+	default:
+		return int((rr.Add(1) - 1) % uint64(len(backends)))
+	}
+}
+
+func validateUtilization(key string, u *Utilization) error {
+	if k := strings.TrimSpace(key); k != "" {
+		name := ""
+		switch {
+		case strings.HasPrefix(k, "counter:"):
+			name = strings.TrimPrefix(k, "counter:")
+		case strings.HasPrefix(k, "list:"):
+			name = strings.TrimPrefix(k, "list:")
+		}
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("key must be counter:<name> or list:<name> for strategy least_utilization")
+		}
+	}
+	if u == nil {
+		return nil
+	}
+	switch normalizeStrategy(u.Mode) {
+	case "", "spread", "fill_first":
+	default:
+		return fmt.Errorf("utilization.mode must be one of: spread, fill_first")
+	}
+	if u.Threshold < 0 || u.Threshold > 1 {
+		return fmt.Errorf("utilization.threshold must be between 0 and 1")
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package routing
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func utilBackend(host string, count string, capacity string) Backend {
+	meta := map[string]string{}
+	if count != "" {
+		meta["counter.players.count"] = count
+	}
+	if capacity != "" {
+		meta["counter.players.capacity"] = capacity
+	}
+	return Backend{Host: host, Port: 1, Meta: meta}
+}
+
+func TestLeastUtilizationIndex(t *testing.T) {
+	backends := []Backend{
+		utilBackend("a", "10", "20"),  // 0.5
+		utilBackend("b", "10", "100"), // 0.1
+		utilBackend("c", "18", "20"),  // 0.9
+		utilBackend("d", "20", "20"),  // full
+		utilBackend("e", "5", ""),     // unknown
+	}
+	cases := []struct {
+		name string
+		u    *Utilization
+		want string
+	}{
+		{name: "spread", want: "b"},
+		{name: "fill_first", u: &Utilization{Mode: "fill_first"}, want: "c"},
+		{name: "fill_first threshold", u: &Utilization{Mode: "fill_first", Threshold: 0.8}, want: "a"},
+	}
+	for _, tc := range cases {
+		idx := leastUtilizationIndex(backends, "", tc.u, false, &atomic.Uint64{})
+		if backends[idx].Host != tc.want {
+			t.Fatalf("%s: picked %s want %s", tc.name, backends[idx].Host, tc.want)
+		}
+	}
+
+	// Every known backend is full: the least utilized one is still used.
+	full := []Backend{utilBackend("a", "25", "20"), utilBackend("b", "20", "20")}
+	if idx := leastUtilizationIndex(full, "", nil, false, &atomic.Uint64{}); full[idx].Host != "b" {
+		t.Fatalf("picked %s", full[idx].Host)
+	}
+
+	// No capacity data at all: round robin.
+	unknown := []Backend{utilBackend("a", "", ""), utilBackend("b", "1", "")}
+	rr := &atomic.Uint64{}
+	first := leastUtilizationIndex(unknown, "", nil, false, rr)
+	second := leastUtilizationIndex(unknown, "", nil, false, rr)
+	if first == second {
+		t.Fatalf("expected round robin fallback, got %d twice", first)
+	}
+}
+
+func TestLeastUtilization_List(t *testing.T) {
+	e := NewStaticEngine(Config{Default: &Pool{
+		Strategy: "least_utilization",
+		Key:      "list:players",
+		Backends: []Backend{
+			{Host: "a", Port: 1, Meta: map[string]string{"list.players.capacity": "4", "list.players.values": `["u1","u2","u3"]`}},
+			{Host: "b", Port: 1, Meta: map[string]string{"list.players.capacity": "4"}},
+		},
+	}})
+	d, err := e.Decide(context.Background(), Request{})
+	if err != nil || d.Backend.Host != "b" {
+		t.Fatalf("decision=%+v err=%v", d, err)
+	}
+}
+
+func TestValidateLeastUtilization(t *testing.T) {
+	cfg := Config{Default: &Pool{
+		Strategy:    "least_utilization",
+		Key:         "players",
+		Utilization: &Utilization{Mode: "pack", Threshold: 1.5},
+		Backends:    []Backend{{Host: "a", Port: 1}},
+	}}
+	err := cfg.Validate()
+	for _, want := range []string{"counter:<name> or list:<name>", "utilization.mode"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in %v", want, err)
+		}
+	}
+
+	cfg.Default.Key = "counter:players"
+	cfg.Default.Utilization = &Utilization{Threshold: 1.5}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "utilization.threshold") {
+		t.Fatalf("expected threshold error, got %v", err)
+	}
+}
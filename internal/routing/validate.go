@@ -1,28 +1,102 @@
 package routing
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
+
+	"github.com/hybrowse/hyrouter/internal/access"
 )
 
+// Validate reports every problem in c. The returned error joins one error per problem,
+// each prefixed with the YAML path of the offending field.
 func (c *Config) Validate() error {
 	if c == nil {
 		return nil
 	}
+	var errs []error
 	if c.Default != nil {
-		if err := validatePool(*c.Default); err != nil {
-			return fmt.Errorf("routing.default: %w", err)
-		}
+		errs = append(errs, validatePool("routing.default", *c.Default)...)
 	}
 	for i, r := range c.Routes {
-		if err := validatePool(r.Pool); err != nil {
-			return fmt.Errorf("routing.routes[%d].pool: %w", i, err)
+		path := fmt.Sprintf("routing.routes[%d]", i)
+		if r.Split != nil {
+			errs = append(errs, validateSplit(path, r)...)
+		} else {
+			errs = append(errs, validatePool(path+".pool", r.Pool)...)
 		}
-		if len(r.Match.Hostnames) == 0 && r.Match.Hostname == "" {
-			return fmt.Errorf("routing.routes[%d].match must not be empty", i)
+		errs = append(errs, validateMatch(path+".match", r.Match)...)
+		errs = append(errs, r.Access.Validate(path+".access")...)
+		errs = append(errs, validateSchedule(path+".schedule", r.Schedule)...)
+		if r.Maintenance != nil {
+			errs = append(errs, validateSchedule(path+".maintenance", &r.Maintenance.Schedule)...)
 		}
 	}
-	return nil
+	return errors.Join(errs...)
+}
+
+func validateMatch(path string, m Match) []error {
+	var errs []error
+	if matchEmpty(m) {
+		errs = append(errs, fmt.Errorf("%s must not be empty", path))
+	}
+	for i, p := range hostRegexPatterns(m) {
+		if _, err := compileHostRegex(p); err != nil {
+			field := "hostname_regex"
+			if len(m.HostnameRegexes) > 0 {
+				field = fmt.Sprintf("hostname_regexes[%d]", i)
+			}
+			errs = append(errs, fmt.Errorf("%s.%s: invalid regex: %w", path, field, err))
+		}
+	}
+	for i, c := range m.SourceCIDRs {
+		if _, err := access.ParsePrefix(c); err != nil {
+			errs = append(errs, fmt.Errorf("%s.source_cidrs[%d]: %w", path, i, err))
+		}
+	}
+	for i, t := range m.ClientTypes {
+		if t < 0 || t > 255 {
+			errs = append(errs, fmt.Errorf("%s.client_types[%d] must be between 0 and 255", path, i))
+		}
+	}
+	for i, l := range m.Languages {
+		if strings.TrimSpace(l) == "" {
+			errs = append(errs, fmt.Errorf("%s.languages[%d] must not be empty", path, i))
+		}
+	}
+	if r := m.ProtocolBuildNumber; r != nil {
+		if r.GTE < 0 || r.LT < 0 {
+			errs = append(errs, fmt.Errorf("%s.protocol_build_number bounds must be >= 0", path))
+		} else if r.GTE == 0 && r.LT == 0 {
+			errs = append(errs, fmt.Errorf("%s.protocol_build_number must set gte or lt", path))
+		} else if r.LT != 0 && r.GTE >= r.LT {
+			errs = append(errs, fmt.Errorf("%s.protocol_build_number.gte must be < lt", path))
+		}
+	}
+	if r := m.ClientVersion; r != nil {
+		gte, lt := strings.TrimSpace(r.GTE), strings.TrimSpace(r.LT)
+		if gte == "" && lt == "" {
+			errs = append(errs, fmt.Errorf("%s.client_version must set gte or lt", path))
+		} else if gte != "" && lt != "" && compareVersions(gte, lt) >= 0 {
+			errs = append(errs, fmt.Errorf("%s.client_version.gte must be < lt", path))
+		}
+	}
+	for i, sub := range m.All {
+		errs = append(errs, validateMatch(fmt.Sprintf("%s.all[%d]", path, i), sub)...)
+	}
+	for i, sub := range m.Any {
+		errs = append(errs, validateMatch(fmt.Sprintf("%s.any[%d]", path, i), sub)...)
+	}
+	return errs
+}
+
+func matchEmpty(m Match) bool {
+	return m.Hostname == "" && len(m.Hostnames) == 0 && m.HostnameRegex == "" && len(m.HostnameRegexes) == 0 && len(m.ALPN) == 0 && len(m.SourceCIDRs) == 0 &&
+		len(m.Languages) == 0 && len(m.ClientTypes) == 0 && len(m.ProtocolHashes) == 0 &&
+		m.ProtocolBuildNumber == nil && m.ClientVersion == nil && len(m.Usernames) == 0 &&
+		len(m.UUIDs) == 0 && len(m.All) == 0 && len(m.Any) == 0
 }
 
 func validateTarget(t Target) error {
@@ -51,65 +125,53 @@ func normalizeStrategy(s string) string {
 	return s
 }
 
-func validatePool(p Pool) error {
-	if len(p.Backends) == 0 && p.Discovery == nil {
-		return fmt.Errorf("backends must not be empty")
-	}
-	strategy := normalizeStrategy(p.Strategy)
-	if strategy == "" {
-		return fmt.Errorf("strategy must not be empty")
-	}
-	switch strategy {
-	case "round_robin", "random", "weighted", "least_loaded", "p2c":
-	default:
-		return fmt.Errorf("unknown strategy %q", p.Strategy)
+func validatePool(path string, p Pool) []error {
+	var errs []error
+	if len(p.Backends) == 0 && p.Discovery == nil && p.Target == nil {
+		errs = append(errs, fmt.Errorf("%s.backends must not be empty", path))
 	}
-	if strategy == "least_loaded" || strategy == "p2c" {
-		if strings.TrimSpace(p.Key) == "" {
-			return fmt.Errorf("key must not be empty for strategy %q", p.Strategy)
+	errs = append(errs, validateSchedule(path+".schedule", p.Schedule)...)
+	if p.Target != nil {
+		if len(p.Backends) > 0 {
+			errs = append(errs, fmt.Errorf("%s.target and %s.backends are mutually exclusive", path, path))
+		}
+		if strings.TrimSpace(p.Target.Host) == "" {
+			errs = append(errs, fmt.Errorf("%s.target.host must not be empty", path))
+		}
+		if p.Target.Port <= 0 || p.Target.Port > 65535 {
+			errs = append(errs, fmt.Errorf("%s.target.port must be between 1 and 65535", path))
 		}
 	}
+	strategy := normalizeStrategy(p.Strategy)
+	if err := validateStrategy(strategy, p.Strategy, p.Key); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", path, err))
+	}
 	if strategy == "p2c" {
 		if p.Sample < 0 {
-			return fmt.Errorf("sample must be >= 0")
+			errs = append(errs, fmt.Errorf("%s.sample must be >= 0", path))
 		}
 	}
-	if p.Limit < 0 {
-		return fmt.Errorf("limit must be >= 0")
+	if p.VirtualNodes < 0 {
+		errs = append(errs, fmt.Errorf("%s.virtual_nodes must be >= 0", path))
 	}
-	for i, s := range p.Sort {
-		if strings.TrimSpace(s.Key) == "" {
-			return fmt.Errorf("sort[%d].key must not be empty", i)
-		}
-		order := normalizeStrategy(s.Order)
-		if order == "" {
-			order = "asc"
-		}
-		switch order {
-		case "asc", "desc":
-		default:
-			return fmt.Errorf("sort[%d].order must be one of: asc, desc", i)
-		}
-		typeHint := normalizeStrategy(s.Type)
-		switch typeHint {
-		case "", "string", "number":
-		default:
-			return fmt.Errorf("sort[%d].type must be one of: string, number", i)
-		}
+	if err := validateUtilization("", p.Utilization); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", path, err))
 	}
+	if p.Limit < 0 {
+		errs = append(errs, fmt.Errorf("%s.limit must be >= 0", path))
+	}
+	errs = append(errs, validateSortKeys(path, p.Sort)...)
 	for i, f := range p.Filters {
 		if err := validateFilter(f); err != nil {
-			return fmt.Errorf("filters[%d]: %w", i, err)
+			errs = append(errs, fmt.Errorf("%s.filters[%d]: %w", path, i, err))
 		}
 	}
 	for i, fb := range p.Fallback {
-		if err := validateFallback(fb); err != nil {
-			return fmt.Errorf("fallback[%d]: %w", i, err)
-		}
+		errs = append(errs, validateFallback(fmt.Sprintf("%s.fallback[%d]", path, i), fb)...)
 	}
 	if p.Discovery != nil {
 		if strings.TrimSpace(p.Discovery.Provider) == "" {
-			return fmt.Errorf("discovery.provider must not be empty")
+			errs = append(errs, fmt.Errorf("%s.discovery.provider must not be empty", path))
 		}
 		mode := normalizeStrategy(p.Discovery.Mode)
 		if mode == "" {
@@ -118,21 +180,81 @@ func validatePool(p Pool) error {
 		switch mode {
 		case "union", "prefer":
 		default:
-			return fmt.Errorf("discovery.mode must be one of: union, prefer")
+			errs = append(errs, fmt.Errorf("%s.discovery.mode must be one of: union, prefer", path))
 		}
 	}
 
 	for i, b := range p.Backends {
 		if err := validateBackend(b); err != nil {
-			return fmt.Errorf("backends[%d]: %w", i, err)
+			errs = append(errs, fmt.Errorf("%s.backends[%d]: %w", path, i, err))
+			continue
 		}
 		if strategy == "weighted" && b.Weight <= 0 {
-			return fmt.Errorf("backends[%d].weight must be > 0 for weighted strategy", i)
+			errs = append(errs, fmt.Errorf("%s.backends[%d].weight must be > 0 for weighted strategy", path, i))
+		}
+	}
+	return errs
+}
+
+func validateStrategy(strategy string, raw string, key string) error {
+	if strategy == "" {
+		return fmt.Errorf("strategy must not be empty")
+	}
+	switch strategy {
+	case "round_robin", "random", "weighted", "least_loaded", "least_utilization", "p2c", "consistent_hash", "rendezvous":
+	default:
+		return fmt.Errorf("unknown strategy %q", raw)
+	}
+	if strategy == "least_loaded" || strategy == "p2c" {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("key must not be empty for strategy %q", raw)
+		}
+	}
+	if strategy == "least_utilization" {
+		if err := validateUtilization(key, nil); err != nil {
+			return err
+		}
+	}
+	if strategy == "consistent_hash" || strategy == "rendezvous" {
+		if !validHashKey(key) {
+			return fmt.Errorf("key must be one of: uuid, username, sni, client_ip for strategy %q", raw)
 		}
 	}
 	return nil
 }
 
+func validateSortKeys(path string, keys []SortKey) []error {
+	var errs []error
+	for i, s := range keys {
+		if strings.TrimSpace(s.Expr) != "" {
+			if strings.TrimSpace(s.Key) != "" {
+				errs = append(errs, fmt.Errorf("%s.sort[%d].key and expr are mutually exclusive", path, i))
+			}
+			if _, err := compileExpr(s.Expr, true); err != nil {
+				errs = append(errs, fmt.Errorf("%s.sort[%d].expr: %w", path, i, err))
+			}
+		} else if strings.TrimSpace(s.Key) == "" {
+			errs = append(errs, fmt.Errorf("%s.sort[%d].key must not be empty", path, i))
+		}
+		order := normalizeStrategy(s.Order)
+		if order == "" {
+			order = "asc"
+		}
+		switch order {
+		case "asc", "desc":
+		default:
+			errs = append(errs, fmt.Errorf("%s.sort[%d].order must be one of: asc, desc", path, i))
+		}
+		typeHint := normalizeStrategy(s.Type)
+		switch typeHint {
+		case "", "string", "number":
+		default:
+			errs = append(errs, fmt.Errorf("%s.sort[%d].type must be one of: string, number", path, i))
+		}
+	}
+	return errs
+}
+
 func validateFilter(f Filter) error {
 	t := normalizeStrategy(f.Type)
 	if t == "" {
@@ -149,8 +271,61 @@ func validateFilter(f Filter) error {
 		default:
 			return fmt.Errorf("op must be one of: lt, lte, gt, gte, eq, neq")
 		}
-		if strings.TrimSpace(f.Right) == "" {
-			return fmt.Errorf("right must not be empty")
+		right, value := strings.TrimSpace(f.Right), strings.TrimSpace(f.Value)
+		switch {
+		case right == "" && value == "":
+			return fmt.Errorf("right or value must be set")
+		case right != "" && value != "":
+			return fmt.Errorf("right and value are mutually exclusive")
+		case value != "":
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return fmt.Errorf("value must be a number")
+			}
+		}
+	case "exists", "not_exists":
+		if strings.TrimSpace(f.Key) == "" {
+			return fmt.Errorf("key must not be empty")
+		}
+	case "string":
+		if strings.TrimSpace(f.Key) == "" {
+			return fmt.Errorf("key must not be empty")
+		}
+		switch normalizeStrategy(f.Op) {
+		case "eq", "neq", "prefix", "suffix":
+		case "in":
+			if len(f.Values) == 0 {
+				return fmt.Errorf("values must not be empty")
+			}
+		case "regex":
+			if _, err := regexp.Compile(f.Value); err != nil {
+				return fmt.Errorf("value: invalid regex: %w", err)
+			}
+		default:
+			return fmt.Errorf("op must be one of: eq, neq, in, prefix, suffix, regex")
+		}
+	case "headroom":
+		if v := strings.TrimSpace(f.Value); v != "" {
+			if _, err := strconv.ParseFloat(v, 64); err != nil {
+				return fmt.Errorf("value must be a number")
+			}
+		}
+	case "blacklist":
+		if strings.TrimSpace(f.ListKey) == "" {
+			return fmt.Errorf("list_key must not be empty")
+		}
+		switch normalizeStrategy(f.Subject) {
+		case "", "uuid", "username":
+		default:
+			return fmt.Errorf("subject must be one of: uuid, username")
+		}
+	case "all", "any", "not":
+		if len(f.Filters) == 0 {
+			return fmt.Errorf("filters must not be empty")
+		}
+		for i, sub := range f.Filters {
+			if err := validateFilter(sub); err != nil {
+				return fmt.Errorf("filters[%d]: %w", i, err)
+			}
 		}
 	case "whitelist":
 		if strings.TrimSpace(f.EnabledKey) == "" {
@@ -166,61 +341,50 @@ func validateFilter(f Filter) error {
 			return fmt.Errorf("subject must be one of: uuid, username")
 		}
 		return nil
-	case "game_start_not_past":
+	case "game_start_not_past", "equals":
 		if strings.TrimSpace(f.Key) == "" {
 			return fmt.Errorf("key must not be empty")
 		}
 		return nil
+	case "expr":
+		if _, err := compileExpr(f.Expr, false); err != nil {
+			return fmt.Errorf("expr: %w", err)
+		}
+		return nil
 	default:
 		return fmt.Errorf("unknown type %q", f.Type)
 	}
 	return nil
 }
 
-func validateFallback(fb Fallback) error {
+func validateFallback(path string, fb Fallback) []error {
+	var errs []error
 	if fb.Strategy != nil {
-		strategy := normalizeStrategy(*fb.Strategy)
-		switch strategy {
-		case "round_robin", "random", "weighted", "least_loaded", "p2c":
-		default:
-			return fmt.Errorf("unknown strategy %q", *fb.Strategy)
+		key := ""
+		if fb.Key != nil {
+			key = *fb.Key
 		}
-		if strategy == "least_loaded" || strategy == "p2c" {
-			if fb.Key == nil || strings.TrimSpace(*fb.Key) == "" {
-				return fmt.Errorf("key must not be empty for strategy %q", *fb.Strategy)
-			}
+		if err := validateStrategy(normalizeStrategy(*fb.Strategy), *fb.Strategy, key); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
 		}
 	}
 	if fb.Sample != nil && *fb.Sample < 0 {
-		return fmt.Errorf("sample must be >= 0")
+		errs = append(errs, fmt.Errorf("%s.sample must be >= 0", path))
 	}
-	if fb.Limit != nil && *fb.Limit < 0 {
-		return fmt.Errorf("limit must be >= 0")
+	if fb.VirtualNodes != nil && *fb.VirtualNodes < 0 {
+		errs = append(errs, fmt.Errorf("%s.virtual_nodes must be >= 0", path))
 	}
-	for i, s := range fb.Sort {
-		if strings.TrimSpace(s.Key) == "" {
-			return fmt.Errorf("sort[%d].key must not be empty", i)
-		}
-		order := normalizeStrategy(s.Order)
-		if order == "" {
-			order = "asc"
-		}
-		switch order {
-		case "asc", "desc":
-		default:
-			return fmt.Errorf("sort[%d].order must be one of: asc, desc", i)
-		}
-		typeHint := normalizeStrategy(s.Type)
-		switch typeHint {
-		case "", "string", "number":
-		default:
-			return fmt.Errorf("sort[%d].type must be one of: string, number", i)
-		}
+	if err := validateUtilization("", fb.Utilization); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", path, err))
+	}
+	if fb.Limit != nil && *fb.Limit < 0 {
+		errs = append(errs, fmt.Errorf("%s.limit must be >= 0", path))
 	}
+	errs = append(errs, validateSortKeys(path, fb.Sort)...)
 	for i, f := range fb.Filters {
 		if err := validateFilter(f); err != nil {
-			return fmt.Errorf("filters[%d]: %w", i, err)
+			errs = append(errs, fmt.Errorf("%s.filters[%d]: %w", path, i, err))
 		}
 	}
-	return nil
+	return errs
 }
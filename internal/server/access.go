@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"reflect"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/access"
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/routing"
+	"github.com/quic-go/quic-go"
+)
+
+// errAccessDenied is the routing error of connections rejected by an access list.
+// Routing and plugins are skipped and the client gets the access_denied Disconnect message.
+var errAccessDenied = errors.New("access denied")
+
+// accessRules collects the global and per-route access rules of cfg.
+func accessRules(cfg *config.Config) (*access.Rules, map[int]*access.Rules) {
+	routes := map[int]*access.Rules{}
+	for i, r := range cfg.Routing.Routes {
+		if r.Access != nil {
+			routes[i] = r.Access
+		}
+	}
+	return cfg.Access, routes
+}
+
+func accessEqual(a *config.Config, b *config.Config) bool {
+	ag, ar := accessRules(a)
+	bg, br := accessRules(b)
+	return reflect.DeepEqual(ag, bg) && reflect.DeepEqual(ar, br)
+}
+
+// newAccessPolicy returns nil if cfg has no access rules.
+func newAccessPolicy(cfg *config.Config) (*access.Policy, error) {
+	global, routes := accessRules(cfg)
+	if global == nil && len(routes) == 0 {
+		return nil, nil
+	}
+	return access.NewPolicy(global, routes)
+}
+
+func (s *Server) accessPolicy() *access.Policy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.access
+}
+
+// runAccessWatch reloads the list files of p until the returned cancel func is called.
+func (s *Server) runAccessWatch(ctx context.Context, p *access.Policy) context.CancelFunc {
+	actx, cancel := context.WithCancel(ctx)
+	p.Watch(actx, reloadInterval(s.config()), s.logger)
+	return cancel
+}
+
+// checkAccess applies the access lists to a connection before any stream is read. It returns
+// errAccessDenied if the client address is not allowed globally or by the route the connection
+// will be handled by. If that route depends on the Connect packet, checkRouteAccess decides later.
+func (s *Server) checkAccess(conn *quic.Conn, sni string, alpn string) error {
+	p := s.accessPolicy()
+	if p == nil {
+		return nil
+	}
+	clientIP := connClientIP(conn)
+	routeIndex := -1
+	router, _ := s.engineAndPlugins()
+	if r, ok := router.(interface {
+		EarlyRouteIndex(req routing.Request) (int, bool)
+	}); ok {
+		routeIndex, _ = r.EarlyRouteIndex(routing.Request{SNI: sni, ALPN: alpn, ClientIP: clientIP})
+	}
+	addr, _ := netip.ParseAddr(clientIP)
+	if p.Allowed(addr, routeIndex) {
+		return nil
+	}
+	s.rejectAccess(routeIndex, conn.RemoteAddr().String())
+	return errAccessDenied
+}
+
+// checkRouteAccess applies the access lists once the route of a connection is known.
+func (s *Server) checkRouteAccess(clientIP string, routeIndex int) error {
+	p := s.accessPolicy()
+	if p == nil {
+		return nil
+	}
+	addr, _ := netip.ParseAddr(clientIP)
+	if p.Allowed(addr, routeIndex) {
+		return nil
+	}
+	s.rejectAccess(routeIndex, clientIP)
+	return errAccessDenied
+}
+
+func (s *Server) rejectAccess(routeIndex int, remoteAddr string) {
+	s.metrics.ConnectionRejected("access_denied")
+	attrs := []any{"reason", "access_denied", "route_index", routeIndex}
+	if cfg := s.config(); cfg != nil && cfg.Logging.LogClientIP {
+		attrs = append(attrs, "remote_addr", remoteAddr)
+	}
+	s.logger.Debug("connection rejected", attrs...)
+}
+
+// reloadInterval returns reload.interval, or 2s if it is unset.
+func reloadInterval(cfg *config.Config) time.Duration {
+	if cfg != nil && cfg.Reload.Interval != "" {
+		if d, err := time.ParseDuration(cfg.Reload.Interval); err == nil && d > 0 {
+			return d
+		}
+	}
+	return 2 * time.Second
+}
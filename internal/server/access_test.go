@@ -0,0 +1,77 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/hybrowse/hyrouter/internal/access"
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/plugins"
+	"github.com/hybrowse/hyrouter/internal/routing"
+)
+
+func TestDumpFrames_AccessDeniedSkipsPluginsAndSendsDisconnect(t *testing.T) {
+	connectPayload := buildConnectPayloadForTest(
+		"6708f121966c1c443f4b0eb525b2f81d0a8dc61f5003a692a8fa157e5e02cea9",
+		0,
+		"d3e6ef90-e113-49a7-a845-1c11f24fe166",
+		"en-US",
+		"tok",
+		"Krymo",
+	)
+	frame := make([]byte, 8+len(connectPayload))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(connectPayload)))
+	binary.LittleEndian.PutUint32(frame[4:8], 0)
+	copy(frame[8:], connectPayload)
+
+	rx := &rw{r: bytes.NewReader(frame)}
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+
+	s := newAdminServerForTest(t)
+	s.cfg.Messages.Disconnect.AccessDenied = "No access to ${sni}."
+	s.plugins = plugins.NewManager(logger, []plugins.Plugin{&pickPlugin{}})
+	s.dumpFrames(context.Background(), nil, rx, logger, routing.Decision{RouteIndex: -1, SelectedIndex: -1}, errAccessDenied, plugins.ConnectEvent{SNI: "staging.example.com"})
+
+	if got := disconnectReasonFromFrameForTest(t, rx.w.Bytes()); got != "No access to staging.example.com." {
+		t.Fatalf("reason=%q", got)
+	}
+}
+
+func TestApplyConfig_SwapsAccessPolicy(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	cfg := config.Default()
+	cfg.Routing.Default = &routing.Pool{Strategy: "round_robin", Backends: []routing.Backend{{Host: "a", Port: 1}}}
+	cfg.Access = &access.Rules{Deny: []string{"192.0.2.0/24"}}
+	s := New(cfg, logger)
+	first := s.accessPolicy()
+	if first == nil {
+		t.Fatalf("expected access policy")
+	}
+
+	next := *cfg
+	next.Access = &access.Rules{Deny: []string{"192.0.2.0/24"}}
+	if err := s.ApplyConfig(context.Background(), &next); err != nil {
+		t.Fatalf("ApplyConfig: %v", err)
+	}
+	if s.accessPolicy() != first {
+		t.Fatalf("expected unchanged access lists to keep the policy")
+	}
+
+	last := *cfg
+	last.Access = nil
+	last.Routing.Routes = []routing.Route{{
+		Match:  routing.Match{Hostname: "staging.example.com"},
+		Pool:   routing.Pool{Strategy: "round_robin", Backends: []routing.Backend{{Host: "b", Port: 1}}},
+		Access: &access.Rules{Allow: []string{"203.0.113.0/24"}},
+	}}
+	if err := s.ApplyConfig(context.Background(), &last); err != nil {
+		t.Fatalf("ApplyConfig: %v", err)
+	}
+	if s.accessPolicy() == nil || s.accessPolicy() == first {
+		t.Fatalf("expected a new access policy for the route list")
+	}
+}
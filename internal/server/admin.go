@@ -0,0 +1,305 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/plugins"
+	"github.com/hybrowse/hyrouter/internal/routing"
+)
+
+const redacted = "REDACTED"
+
+type adminRoute struct {
+	Index       int               `json:"index"`
+	Hostnames   []string          `json:"hostnames,omitempty"`
+	Strategy    string            `json:"strategy"`
+	Maintenance bool              `json:"maintenance"`
+	Candidates  []routing.Backend `json:"candidates"`
+	Error       string            `json:"error,omitempty"`
+}
+
+type adminPlugin struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Stage string `json:"stage"`
+}
+
+func (s *Server) serveAdmin(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.cfg.Admin.Listen)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: s.adminHandler(), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Warn("admin listener failed", "error", err)
+		}
+	}()
+	s.logger.Info("admin listening", "addr", ln.Addr().String())
+	return nil
+}
+
+func (s *Server) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /config", s.adminConfig)
+	mux.HandleFunc("GET /routes", s.adminRoutes)
+	mux.HandleFunc("PUT /routes/{index}/maintenance", s.adminMaintenance(true))
+	mux.HandleFunc("DELETE /routes/{index}/maintenance", s.adminMaintenance(false))
+	mux.HandleFunc("GET /plugins", s.adminPlugins)
+	mux.HandleFunc("GET /discovery", s.adminDiscovery)
+	mux.HandleFunc("GET /outliers", s.adminOutliers)
+	mux.HandleFunc("GET /drains", s.adminDrains)
+	mux.HandleFunc("PUT /drains/{target}", s.adminDrain(true))
+	mux.HandleFunc("DELETE /drains/{target}", s.adminDrain(false))
+	mux.HandleFunc("POST /reload", s.adminReload)
+	mux.HandleFunc("POST /explain", s.adminExplain)
+	return s.adminAuth(mux)
+}
+
+// adminAuth checks the bearer token against the current config, so token changes apply on reload.
+func (s *Server) adminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := ""
+		if cfg := s.config(); cfg != nil && cfg.Admin != nil {
+			token = cfg.Admin.Token
+		}
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="hyrouter"`)
+			writeAdminError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) adminConfig(w http.ResponseWriter, _ *http.Request) {
+	writeAdminJSON(w, http.StatusOK, redactConfig(s.config()))
+}
+
+func (s *Server) adminRoutes(w http.ResponseWriter, r *http.Request) {
+	cfg := s.config()
+	router, _ := s.engineAndPlugins()
+	se, _ := router.(*routing.StaticEngine)
+	ctx := routing.WithDryRun(r.Context())
+	var keys []string
+	if cfg != nil {
+		keys = routing.RouteKeys(cfg.Routing.Routes)
+	}
+
+	describe := func(i int, hostnames []string, pool routing.Pool) adminRoute {
+		key := routing.DefaultRouteKey
+		if i >= 0 {
+			key = keys[i]
+		}
+		ar := adminRoute{
+			Index:       i,
+			Hostnames:   hostnames,
+			Strategy:    pool.Strategy,
+			Maintenance: s.control.inMaintenance(key),
+			Candidates:  []routing.Backend{},
+		}
+		if se == nil {
+			ar.Error = "routing engine does not support inspection"
+			return ar
+		}
+		cands, err := se.Candidates(ctx, i)
+		if err != nil {
+			ar.Error = err.Error()
+			return ar
+		}
+		ar.Candidates = cands
+		return ar
+	}
+
+	out := []adminRoute{}
+	if cfg != nil {
+		for i, rt := range cfg.Routing.Routes {
+			var hostnames []string
+			if rt.Match.Hostname != "" {
+				hostnames = append(hostnames, rt.Match.Hostname)
+			}
+			hostnames = append(hostnames, rt.Match.Hostnames...)
+			ar := describe(i, hostnames, rt.Pool)
+			if rt.Split != nil {
+				ar.Strategy = "split"
+			}
+			out = append(out, ar)
+		}
+		if cfg.Routing.Default != nil {
+			out = append(out, describe(-1, nil, *cfg.Routing.Default))
+		}
+	}
+	writeAdminJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) adminMaintenance(enabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg := s.config()
+		idx, err := parseRouteIndex(cfg, r.PathValue("index"))
+		if err != nil {
+			writeAdminError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		s.control.setMaintenance(routeKey(cfg, idx), enabled)
+		s.logger.Info("route maintenance changed", "route_index", idx, "maintenance", enabled)
+		writeAdminJSON(w, http.StatusOK, map[string]any{"route_index": idx, "maintenance": enabled})
+	}
+}
+
+func (s *Server) adminPlugins(w http.ResponseWriter, _ *http.Request) {
+	out := []adminPlugin{}
+	if cfg := s.config(); cfg != nil {
+		ordered, err := plugins.OrderPluginConfigs(cfg.Plugins)
+		if err != nil {
+			writeAdminError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for _, p := range ordered {
+			out = append(out, adminPlugin{Name: p.Name, Type: strings.ToLower(p.Type), Stage: strings.ToLower(p.Stage)})
+		}
+	}
+	writeAdminJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) adminDiscovery(w http.ResponseWriter, _ *http.Request) {
+	s.mu.RLock()
+	dm := s.discovery
+	s.mu.RUnlock()
+	health := dm.Health()
+	if health == nil {
+		writeAdminJSON(w, http.StatusOK, []any{})
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, health)
+}
+
+func (s *Server) adminOutliers(w http.ResponseWriter, _ *http.Request) {
+	snapshot := s.outlierDetector().Snapshot()
+	if snapshot == nil {
+		writeAdminJSON(w, http.StatusOK, []any{})
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, snapshot)
+}
+
+func (s *Server) adminDrains(w http.ResponseWriter, _ *http.Request) {
+	writeAdminJSON(w, http.StatusOK, s.control.drainedTargets())
+}
+
+func (s *Server) adminDrain(drained bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t, err := parseTarget(r.PathValue("target"))
+		if err != nil {
+			writeAdminError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.control.setDrained(t, drained)
+		s.logger.Info("backend drain changed", "host", t.Host, "port", t.Port, "drained", drained)
+		writeAdminJSON(w, http.StatusOK, s.control.drainedTargets())
+	}
+}
+
+func (s *Server) adminReload(w http.ResponseWriter, r *http.Request) {
+	if err := s.Reload(r.Context()); err != nil {
+		writeAdminError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, map[string]any{"reloaded": true})
+}
+
+func (s *Server) adminExplain(w http.ResponseWriter, r *http.Request) {
+	var req ExplainRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&req); err != nil {
+		writeAdminError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, s.Explain(r.Context(), req))
+}
+
+func parseRouteIndex(cfg *config.Config, v string) (int, error) {
+	if cfg == nil {
+		return 0, fmt.Errorf("no config loaded")
+	}
+	if v == "default" {
+		if cfg.Routing.Default == nil {
+			return 0, fmt.Errorf("routing.default is not configured")
+		}
+		return -1, nil
+	}
+	idx, err := strconv.Atoi(v)
+	if err != nil || idx < 0 || idx >= len(cfg.Routing.Routes) {
+		return 0, fmt.Errorf("unknown route %q", v)
+	}
+	return idx, nil
+}
+
+// routeKey returns the identity of route i in cfg; -1 is the default route.
+func routeKey(cfg *config.Config, i int) string {
+	if cfg == nil || i < 0 || i >= len(cfg.Routing.Routes) {
+		return routing.DefaultRouteKey
+	}
+	return routing.RouteKeys(cfg.Routing.Routes)[i]
+}
+
+func parseTarget(v string) (routing.Target, error) {
+	host, portStr, err := net.SplitHostPort(v)
+	if err != nil {
+		return routing.Target{}, fmt.Errorf("target must be host:port: %w", err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return routing.Target{}, fmt.Errorf("invalid port %q", portStr)
+	}
+	if host == "" {
+		return routing.Target{}, fmt.Errorf("host must not be empty")
+	}
+	return routing.Target{Host: host, Port: port}, nil
+}
+
+func redactConfig(cfg *config.Config) *config.Config {
+	if cfg == nil {
+		return nil
+	}
+	out := *cfg
+	if out.Referral != nil && out.Referral.HMACSecret != "" {
+		ref := *out.Referral
+		ref.HMACSecret = redacted
+		out.Referral = &ref
+	}
+	if out.Admin != nil {
+		adm := *out.Admin
+		adm.Token = redacted
+		out.Admin = &adm
+	}
+	return &out
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+func writeAdminError(w http.ResponseWriter, status int, msg string) {
+	writeAdminJSON(w, status, map[string]string{"error": msg})
+}
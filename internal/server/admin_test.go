@@ -0,0 +1,255 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/plugins"
+	"github.com/hybrowse/hyrouter/internal/routing"
+)
+
+func newAdminServerForTest(t *testing.T) *Server {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Admin = &config.AdminConfig{Listen: "127.0.0.1:0", Token: "secret-token"}
+	cfg.Referral = &config.ReferralConfig{KeyID: 1, HMACSecret: "hmac"}
+	cfg.Routing = routing.Config{
+		Routes: []routing.Route{{
+			Match: routing.Match{Hostname: "play.example.com"},
+			Pool:  routing.Pool{Strategy: "round_robin", Backends: []routing.Backend{{Host: "a", Port: 1}, {Host: "b", Port: 2}}},
+		}},
+		Default: &routing.Pool{Strategy: "round_robin", Backends: []routing.Backend{{Host: "c", Port: 3}}},
+	}
+	cfg.Plugins = []config.PluginConfig{
+		{Name: "late", Type: "grpc", Stage: "mutate", GRPC: &config.GRPCPluginConfig{Address: "x"}},
+		{Name: "early", Type: "grpc", Stage: "deny", GRPC: &config.GRPCPluginConfig{Address: "x"}},
+	}
+	return New(cfg, slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{})))
+}
+
+func adminRequestForTest(t *testing.T, h http.Handler, method string, path string, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdmin_RequiresBearerToken(t *testing.T) {
+	h := newAdminServerForTest(t).adminHandler()
+	if rec := adminRequestForTest(t, h, http.MethodGet, "/config", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status=%d", rec.Code)
+	}
+	if rec := adminRequestForTest(t, h, http.MethodGet, "/config", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status=%d", rec.Code)
+	}
+	if rec := adminRequestForTest(t, h, http.MethodGet, "/config", "secret-token"); rec.Code != http.StatusOK {
+		t.Fatalf("status=%d", rec.Code)
+	}
+}
+
+func TestAdmin_ConfigIsRedacted(t *testing.T) {
+	h := newAdminServerForTest(t).adminHandler()
+	rec := adminRequestForTest(t, h, http.MethodGet, "/config", "secret-token")
+	body := rec.Body.String()
+	if strings.Contains(body, "secret-token") || strings.Contains(body, `"hmac"`) {
+		t.Fatalf("secrets leaked: %s", body)
+	}
+	var cfg config.Config
+	if err := json.Unmarshal(rec.Body.Bytes(), &cfg); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if cfg.Admin.Token != redacted || cfg.Referral.HMACSecret != redacted || len(cfg.Routing.Routes) != 1 {
+		t.Fatalf("cfg=%#v", cfg)
+	}
+}
+
+func TestAdmin_DrainExcludesBackend(t *testing.T) {
+	s := newAdminServerForTest(t)
+	h := s.adminHandler()
+
+	if rec := adminRequestForTest(t, h, http.MethodPut, "/drains/a:1", "secret-token"); rec.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	if rec := adminRequestForTest(t, h, http.MethodPut, "/drains/nope", "secret-token"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("status=%d", rec.Code)
+	}
+
+	rec := adminRequestForTest(t, h, http.MethodGet, "/routes", "secret-token")
+	var routes []adminRoute
+	if err := json.Unmarshal(rec.Body.Bytes(), &routes); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(routes) != 2 || routes[1].Index != -1 {
+		t.Fatalf("routes=%#v", routes)
+	}
+	if len(routes[0].Candidates) != 1 || routes[0].Candidates[0].Host != "b" {
+		t.Fatalf("candidates=%#v", routes[0].Candidates)
+	}
+	router, _ := s.engineAndPlugins()
+	for i := 0; i < 3; i++ {
+		dec, err := router.Decide(context.Background(), routing.Request{SNI: "play.example.com"})
+		if err != nil || dec.Backend.Host != "b" {
+			t.Fatalf("dec=%#v err=%v", dec, err)
+		}
+	}
+
+	rec = adminRequestForTest(t, h, http.MethodGet, "/drains", "secret-token")
+	if !strings.Contains(rec.Body.String(), `"host": "a"`) {
+		t.Fatalf("drains=%s", rec.Body.String())
+	}
+	adminRequestForTest(t, h, http.MethodDelete, "/drains/a:1", "secret-token")
+	if s.control.isDrained(routing.Target{Host: "a", Port: 1}) {
+		t.Fatalf("expected backend to be undrained")
+	}
+}
+
+func TestAdmin_DrainSurvivesReload(t *testing.T) {
+	s := newAdminServerForTest(t)
+	s.control.setDrained(routing.Target{Host: "c", Port: 3}, true)
+
+	next := config.Default()
+	next.Routing.Default = &routing.Pool{Strategy: "round_robin", Backends: []routing.Backend{{Host: "c", Port: 3}, {Host: "d", Port: 4}}}
+	if err := s.ApplyConfig(context.Background(), next); err != nil {
+		t.Fatalf("ApplyConfig: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if got := decideHostForTest(t, s); got != "d" {
+			t.Fatalf("backend=%q", got)
+		}
+	}
+}
+
+func TestAdmin_Maintenance(t *testing.T) {
+	s := newAdminServerForTest(t)
+	h := s.adminHandler()
+
+	if rec := adminRequestForTest(t, h, http.MethodPut, "/routes/0/maintenance", "secret-token"); rec.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	if rec := adminRequestForTest(t, h, http.MethodPut, "/routes/7/maintenance", "secret-token"); rec.Code != http.StatusNotFound {
+		t.Fatalf("status=%d", rec.Code)
+	}
+	if rec := adminRequestForTest(t, h, http.MethodPut, "/routes/default/maintenance", "secret-token"); rec.Code != http.StatusOK {
+		t.Fatalf("status=%d", rec.Code)
+	}
+
+	router, _ := s.engineAndPlugins()
+	if _, err := router.Decide(context.Background(), routing.Request{SNI: "play.example.com"}); err == nil {
+		t.Fatalf("expected maintenance error")
+	}
+	if got := s.disconnectReason("play.example.com", "", routing.ErrMaintenance); got != "The server is under maintenance. Please try again later." {
+		t.Fatalf("reason=%q", got)
+	}
+
+	end := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	if got := s.disconnectReason("play.example.com", "", &routing.MaintenanceError{End: end, Message: "Back at ${maintenance_end}"}); got != "Back at 2026-10-16 12:00 UTC" {
+		t.Fatalf("reason=%q", got)
+	}
+
+	adminRequestForTest(t, h, http.MethodDelete, "/routes/0/maintenance", "secret-token")
+	if _, err := router.Decide(context.Background(), routing.Request{SNI: "play.example.com"}); err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+}
+
+type pickPlugin struct{}
+
+func (p *pickPlugin) Name() string { return "pick" }
+func (p *pickPlugin) OnConnect(ctx context.Context, req plugins.ConnectRequest) (plugins.ConnectResponse, error) {
+	return plugins.ConnectResponse{Backend: &routing.Backend{Host: "plugin", Port: 1}}, nil
+}
+func (p *pickPlugin) Close(ctx context.Context) error { return nil }
+
+func TestDumpFrames_MaintenanceIgnoresPlugins(t *testing.T) {
+	connectPayload := buildConnectPayloadForTest(
+		"6708f121966c1c443f4b0eb525b2f81d0a8dc61f5003a692a8fa157e5e02cea9",
+		0,
+		"d3e6ef90-e113-49a7-a845-1c11f24fe166",
+		"en",
+		"tok",
+		"Krymo",
+	)
+	frame := make([]byte, 8+len(connectPayload))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(connectPayload)))
+	binary.LittleEndian.PutUint32(frame[4:8], 0)
+	copy(frame[8:], connectPayload)
+
+	rx := &rw{r: bytes.NewReader(frame)}
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+
+	s := newAdminServerForTest(t)
+	s.plugins = plugins.NewManager(logger, []plugins.Plugin{&pickPlugin{}})
+	s.control.setMaintenance(routeKey(s.config(), 0), true)
+	s.dumpFrames(context.Background(), nil, rx, logger, routing.Decision{RouteIndex: -1, SelectedIndex: -1}, nil, plugins.ConnectEvent{SNI: "play.example.com"})
+
+	if got := disconnectReasonFromFrameForTest(t, rx.w.Bytes()); got != "The server is under maintenance. Please try again later." {
+		t.Fatalf("reason=%q", got)
+	}
+}
+
+func TestAdmin_PluginsDiscoveryAndReload(t *testing.T) {
+	s := newAdminServerForTest(t)
+	h := s.adminHandler()
+
+	rec := adminRequestForTest(t, h, http.MethodGet, "/plugins", "secret-token")
+	var pls []adminPlugin
+	if err := json.Unmarshal(rec.Body.Bytes(), &pls); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(pls) != 2 || pls[0].Name != "early" || pls[1].Name != "late" {
+		t.Fatalf("plugins=%#v", pls)
+	}
+
+	rec = adminRequestForTest(t, h, http.MethodGet, "/discovery", "secret-token")
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+
+	rec = adminRequestForTest(t, h, http.MethodPost, "/reload", "secret-token")
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status=%d", rec.Code)
+	}
+}
+
+func TestAdmin_OutlierEjectionExcludesBackend(t *testing.T) {
+	s := newAdminServerForTest(t)
+	next := *s.config()
+	next.OutlierDetection = &config.OutlierDetectionConfig{MinReferrals: 1, FailureRatio: 0.5}
+	if err := s.ApplyConfig(context.Background(), &next); err != nil {
+		t.Fatalf("ApplyConfig: %v", err)
+	}
+	od := s.outlierDetector()
+	od.Referred("uuid:1", routing.Target{Host: "b", Port: 2})
+	od.Referred("uuid:2", routing.Target{Host: "a", Port: 1})
+	od.Returned("uuid:2")
+
+	router, _ := s.engineAndPlugins()
+	for i := 0; i < 4; i++ {
+		dec, err := router.Decide(context.Background(), routing.Request{SNI: "play.example.com"})
+		if err != nil {
+			t.Fatalf("Decide: %v", err)
+		}
+		if dec.Backend.Host != "b" {
+			t.Fatalf("backend=%q", dec.Backend.Host)
+		}
+	}
+
+	rec := adminRequestForTest(t, s.adminHandler(), http.MethodGet, "/outliers", "secret-token")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"ejected": true`) {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+}
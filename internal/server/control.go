@@ -0,0 +1,98 @@
+package server
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/hybrowse/hyrouter/internal/routing"
+)
+
+// controlState holds operator overrides set through the admin API.
+// It lives on the Server so that drains and maintenance survive config reloads.
+// Maintenance is keyed by routing.RouteKeys, so it follows a route when other routes
+// are inserted, removed or reordered.
+type controlState struct {
+	mu          sync.RWMutex
+	drained     map[routing.Target]struct{}
+	maintenance map[string]struct{}
+}
+
+func newControlState() *controlState {
+	return &controlState{
+		drained:     map[routing.Target]struct{}{},
+		maintenance: map[string]struct{}{},
+	}
+}
+
+func (c *controlState) isDrained(t routing.Target) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.drained[t]
+	return ok
+}
+
+func (c *controlState) setDrained(t routing.Target, drained bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if drained {
+		c.drained[t] = struct{}{}
+	} else {
+		delete(c.drained, t)
+	}
+}
+
+func (c *controlState) drainedTargets() []routing.Target {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make([]routing.Target, 0, len(c.drained))
+	for t := range c.drained {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Host != out[j].Host {
+			return out[i].Host < out[j].Host
+		}
+		return out[i].Port < out[j].Port
+	})
+	return out
+}
+
+func (c *controlState) inMaintenance(routeKey string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.maintenance[routeKey]
+	return ok
+}
+
+func (c *controlState) setMaintenance(routeKey string, enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if enabled {
+		c.maintenance[routeKey] = struct{}{}
+	} else {
+		delete(c.maintenance, routeKey)
+	}
+}
+
+// retainMaintenance drops maintenance for routes that are no longer configured, so a route
+// that is removed and later added again does not come back in maintenance.
+func (c *controlState) retainMaintenance(routeKeys []string) {
+	keep := make(map[string]struct{}, len(routeKeys)+1)
+	keep[routing.DefaultRouteKey] = struct{}{}
+	for _, k := range routeKeys {
+		keep[k] = struct{}{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range c.maintenance {
+		if _, ok := keep[k]; !ok {
+			delete(c.maintenance, k)
+		}
+	}
+}
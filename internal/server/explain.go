@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/plugins"
+	"github.com/hybrowse/hyrouter/internal/routing"
+)
+
+// ExplainRequest describes a simulated Connect packet.
+type ExplainRequest struct {
+	SNI                 string `json:"sni"`
+	UUID                string `json:"uuid"`
+	Username            string `json:"username"`
+	Language            string `json:"language"`
+	ClientIP            string `json:"client_ip"`
+	ALPN                string `json:"alpn,omitempty"`
+	ProtocolHash        string `json:"protocol_hash,omitempty"`
+	ProtocolBuildNumber int    `json:"protocol_build_number,omitempty"`
+	ClientVersion       string `json:"client_version,omitempty"`
+	ClientType          *uint8 `json:"client_type,omitempty"`
+}
+
+// Explanation is the outcome of a dry-run decision, including the routing trace and every plugin's effect.
+type Explanation struct {
+	Request            ExplainRequest   `json:"request"`
+	Trace              *routing.Trace   `json:"trace,omitempty"`
+	Decision           routing.Decision `json:"decision"`
+	RoutingError       string           `json:"routing_error,omitempty"`
+	Plugins            []plugins.Step   `json:"plugins"`
+	Outcome            string           `json:"outcome"`
+	Backend            *routing.Backend `json:"backend,omitempty"`
+	ReferralContentLen int              `json:"referral_content_len,omitempty"`
+	DisconnectKind     string           `json:"disconnect_kind,omitempty"`
+	DisconnectReason   string           `json:"disconnect_reason,omitempty"`
+}
+
+// Explain runs routing and the plugin chain for req like a real Connect would, without sending anything.
+// Plugins are called for real; discovery providers are not asked to allocate.
+func (s *Server) Explain(ctx context.Context, req ExplainRequest) *Explanation {
+	ctx = routing.WithDryRun(ctx)
+	router, pluginMgr := s.engineAndPlugins()
+	out := &Explanation{Request: req, Plugins: []plugins.Step{}}
+
+	decision := routing.Decision{Matched: false, RouteIndex: -1, SelectedIndex: -1}
+	var routeErr error
+	rreq := routing.Request{
+		SNI:                 req.SNI,
+		UUID:                req.UUID,
+		Username:            req.Username,
+		Language:            req.Language,
+		ClientIP:            req.ClientIP,
+		ALPN:                req.ALPN,
+		ProtocolHash:        req.ProtocolHash,
+		ProtocolBuildNumber: req.ProtocolBuildNumber,
+		ClientVersion:       req.ClientVersion,
+		ClientType:          req.ClientType,
+	}
+	if se, ok := router.(*routing.StaticEngine); ok {
+		d, tr, err := se.Explain(ctx, rreq)
+		out.Trace = tr
+		if err == nil {
+			decision = d
+		}
+		routeErr = err
+	} else if router != nil {
+		d, err := router.Decide(ctx, rreq)
+		if err == nil {
+			decision = d
+		}
+		routeErr = err
+	}
+	if routeErr != nil {
+		out.RoutingError = routeErr.Error()
+	}
+	out.Decision = decision
+
+	backend := decision.Backend
+	if routeErr != nil {
+		backend = routing.Backend{}
+	}
+	referralContent := decision.ReferralContent
+	if pluginMgr != nil && !errors.Is(routeErr, routing.ErrMaintenance) {
+		ev := plugins.ConnectEvent{SNI: req.SNI, UUID: req.UUID, Username: req.Username, Language: req.Language, ProtocolHash: req.ProtocolHash}
+		if req.ClientType != nil {
+			ev.ClientType = *req.ClientType
+		}
+		res, steps := pluginMgr.ExplainOnConnect(ctx, ev, decision, nil)
+		out.Plugins = steps
+		if res.Denied {
+			out.Outcome = "disconnect"
+			out.DisconnectKind = "plugin_deny"
+			out.DisconnectReason = res.DenyReason
+			return out
+		}
+		backend = res.Backend
+		referralContent = res.ReferralContent
+	}
+
+	if backend.Host != "" {
+		out.Outcome = "referral"
+		out.Backend = &backend
+		out.ReferralContentLen = len(referralContent)
+		return out
+	}
+	out.Outcome = "disconnect"
+	out.DisconnectKind = disconnectKind(routeErr)
+	out.DisconnectReason = s.disconnectReason(req.SNI, req.Language, routeErr)
+	return out
+}
+
+// ExplainConfig builds a server for cfg without opening any listener and explains a single decision.
+func ExplainConfig(ctx context.Context, cfg *config.Config, logger *slog.Logger, req ExplainRequest) (*Explanation, error) {
+	s := New(cfg, logger)
+	if s.initErr != nil {
+		return nil, s.initErr
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if err := s.start(ctx); err != nil {
+		return nil, err
+	}
+	defer func() {
+		_, pm := s.engineAndPlugins()
+		pm.Close(ctx)
+	}()
+	return s.Explain(ctx, req), nil
+}
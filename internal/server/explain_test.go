@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/plugins"
+	"github.com/hybrowse/hyrouter/internal/routing"
+)
+
+func TestExplain_ReferralWithPlugins(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	s := newAdminServerForTest(t)
+	s.plugins = plugins.NewManager(logger, []plugins.Plugin{&pickPlugin{}})
+
+	ex := s.Explain(context.Background(), ExplainRequest{SNI: "play.example.com", UUID: "u"})
+	if ex.Trace == nil || !ex.Trace.Matched || ex.Decision.Backend.Host != "a" {
+		t.Fatalf("ex=%#v", ex)
+	}
+	if len(ex.Plugins) != 1 || ex.Plugins[0].Plugin != "pick" || ex.Plugins[0].Backend.Host != "plugin" {
+		t.Fatalf("plugins=%#v", ex.Plugins)
+	}
+	if ex.Outcome != "referral" || ex.Backend.Host != "plugin" {
+		t.Fatalf("outcome=%q backend=%#v", ex.Outcome, ex.Backend)
+	}
+
+	// Explain is a dry run: the next real decision still starts at the first backend.
+	router, _ := s.engineAndPlugins()
+	dec, err := router.Decide(context.Background(), routing.Request{SNI: "play.example.com"})
+	if err != nil || dec.Backend.Host != "a" {
+		t.Fatalf("dec=%#v err=%v", dec, err)
+	}
+}
+
+func TestExplain_Disconnects(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	s := New(config.Default(), logger)
+	ex := s.Explain(context.Background(), ExplainRequest{SNI: "x"})
+	if ex.Outcome != "disconnect" || ex.DisconnectKind != "no_route" || ex.DisconnectReason != "The server is currently unavailable." {
+		t.Fatalf("ex=%#v", ex)
+	}
+
+	s = newAdminServerForTest(t)
+	s.plugins = plugins.NewManager(logger, []plugins.Plugin{&denyPlugin{}})
+	ex = s.Explain(context.Background(), ExplainRequest{SNI: "play.example.com"})
+	if ex.Outcome != "disconnect" || ex.DisconnectKind != "plugin_deny" || ex.DisconnectReason != "no" {
+		t.Fatalf("ex=%#v", ex)
+	}
+
+	s.control.setMaintenance(routeKey(s.config(), 0), true)
+	ex = s.Explain(context.Background(), ExplainRequest{SNI: "play.example.com"})
+	if ex.DisconnectKind != "maintenance" || len(ex.Plugins) != 0 || !ex.Trace.Maintenance {
+		t.Fatalf("ex=%#v", ex)
+	}
+}
+
+func TestAdmin_Explain(t *testing.T) {
+	h := newAdminServerForTest(t).adminHandler()
+
+	req := httptest.NewRequest(http.MethodPost, "/explain", strings.NewReader(`{"sni":"play.example.com","username":"Krymo"}`))
+	req.Header.Set("Authorization", "Bearer secret-token")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	var ex Explanation
+	if err := json.Unmarshal(rec.Body.Bytes(), &ex); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if ex.Outcome != "referral" || ex.Request.Username != "Krymo" || ex.Trace.RouteIndex != 0 {
+		t.Fatalf("ex=%#v", ex)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/explain", strings.NewReader(`nope`))
+	req.Header.Set("Authorization", "Bearer secret-token")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status=%d", rec.Code)
+	}
+}
+
+func TestExplainConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.Routing.Default = &routing.Pool{Strategy: "round_robin", Backends: []routing.Backend{{Host: "d", Port: 1}}}
+	ex, err := ExplainConfig(context.Background(), cfg, slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{})), ExplainRequest{SNI: "x"})
+	if err != nil {
+		t.Fatalf("ExplainConfig: %v", err)
+	}
+	if ex.Outcome != "referral" || ex.Backend.Host != "d" || ex.Trace.Route != "routing.default" {
+		t.Fatalf("ex=%#v", ex)
+	}
+}
+
+func TestExplainConfig_ConsulDiscovery(t *testing.T) {
+	consul := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/health/service/lobby" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("X-Consul-Index", "3")
+		_, _ = io.WriteString(w, `[{"Node":{"Node":"n1","Address":"10.1.0.1"},"Service":{"ID":"lobby-1","Service":"lobby","Port":5520},"Checks":[{"Status":"passing"}]}]`)
+	}))
+	defer consul.Close()
+
+	cfg := config.Default()
+	cfg.Discovery = &config.DiscoveryConfig{Providers: []config.DiscoveryProviderConfig{{
+		Name: "consul", Type: "consul", Consul: &config.ConsulDiscoveryConfig{Address: consul.URL, Service: "lobby"},
+	}}}
+	cfg.Routing.Default = &routing.Pool{Strategy: "round_robin", Discovery: &routing.Discovery{Provider: "consul"}}
+	ex, err := ExplainConfig(context.Background(), cfg, slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{})), ExplainRequest{SNI: "x"})
+	if err != nil {
+		t.Fatalf("ExplainConfig: %v", err)
+	}
+	if ex.Outcome != "referral" || ex.Backend.Host != "10.1.0.1" || ex.Backend.Port != 5520 {
+		t.Fatalf("ex=%#v", ex)
+	}
+}
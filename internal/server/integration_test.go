@@ -248,6 +248,11 @@ func makeClientCertificate(t *testing.T) tls.Certificate {
 
 func dialQUIC(t *testing.T, addr string) *quic.Conn {
 	t.Helper()
+	return dialQUICWithConfig(t, addr, &quic.Config{})
+}
+
+func dialQUICWithConfig(t *testing.T, addr string, quicConf *quic.Config) *quic.Conn {
+	t.Helper()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -263,7 +268,7 @@ func dialQUIC(t *testing.T, addr string) *quic.Conn {
 
 	var lastErr error
 	for i := 0; i < 50; i++ {
-		c, err := quic.DialAddr(ctx, addr, tlsConf, &quic.Config{})
+		c, err := quic.DialAddr(ctx, addr, tlsConf, quicConf)
 		if err == nil {
 			return c
 		}
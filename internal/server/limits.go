@@ -0,0 +1,68 @@
+package server
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+
+	"github.com/hybrowse/hyrouter/internal/limits"
+	"github.com/quic-go/quic-go"
+)
+
+// errRateLimited is the routing error of connections over a rate limit with limits.action: disconnect.
+// Routing and plugins are skipped and the client gets the rate_limited Disconnect message.
+var errRateLimited = errors.New("rate limited")
+
+// QUIC application error codes used when an over-limit connection is closed before any stream is read.
+const (
+	closeCodeRateLimited quic.ApplicationErrorCode = 0x100
+	closeCodeOverloaded  quic.ApplicationErrorCode = 0x101
+)
+
+func (s *Server) connLimiter() *limits.Limiter {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.limiter
+}
+
+// admit applies limits to a freshly accepted connection. When admitted is false the connection
+// was already closed. A non-nil routeErr means it must be answered with a Disconnect.
+// Admitted connections count as in flight until release is called.
+func (s *Server) admit(conn *quic.Conn) (admitted bool, routeErr error) {
+	cfg := s.config()
+	if cfg == nil || cfg.Limits == nil {
+		s.metrics.SetConnectionsInFlight(s.inFlight.Add(1))
+		return true, nil
+	}
+
+	if limit := int64(cfg.Limits.MaxInFlight); limit > 0 && s.inFlight.Load() >= limit {
+		s.rejectConn(conn, "max_in_flight", closeCodeOverloaded, "overloaded")
+		return false, nil
+	}
+
+	addr, _ := netip.ParseAddr(connClientIP(conn))
+	if ok, reason := s.connLimiter().Allow(addr); !ok {
+		if strings.EqualFold(strings.TrimSpace(cfg.Limits.Action), "close") {
+			s.rejectConn(conn, reason, closeCodeRateLimited, "rate limited")
+			return false, nil
+		}
+		s.metrics.ConnectionRejected(reason)
+		routeErr = errRateLimited
+	}
+	s.metrics.SetConnectionsInFlight(s.inFlight.Add(1))
+	return true, routeErr
+}
+
+func (s *Server) release() {
+	s.metrics.SetConnectionsInFlight(s.inFlight.Add(-1))
+}
+
+func (s *Server) rejectConn(conn *quic.Conn, reason string, code quic.ApplicationErrorCode, msg string) {
+	s.metrics.ConnectionRejected(reason)
+	attrs := []any{"reason", reason}
+	if cfg := s.config(); cfg != nil && cfg.Logging.LogClientIP {
+		attrs = append(attrs, "remote_addr", conn.RemoteAddr().String())
+	}
+	s.logger.Debug("connection rejected", attrs...)
+	_ = conn.CloseWithError(code, msg)
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/plugins"
+	"github.com/hybrowse/hyrouter/internal/routing"
+)
+
+func TestDumpFrames_RateLimitedSendsLocalizedDisconnect(t *testing.T) {
+	connectPayload := buildConnectPayloadForTest(
+		"6708f121966c1c443f4b0eb525b2f81d0a8dc61f5003a692a8fa157e5e02cea9",
+		0,
+		"d3e6ef90-e113-49a7-a845-1c11f24fe166",
+		"de-DE",
+		"tok",
+		"Krymo",
+	)
+	frame := make([]byte, 8+len(connectPayload))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(connectPayload)))
+	binary.LittleEndian.PutUint32(frame[4:8], 0)
+	copy(frame[8:], connectPayload)
+
+	rx := &rw{r: bytes.NewReader(frame)}
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+
+	s := newAdminServerForTest(t)
+	s.cfg.Messages.DisconnectLocales = map[string]config.DisconnectMessagesConfig{"de": {RateLimited: "Zu viele Versuche."}}
+	s.plugins = plugins.NewManager(logger, []plugins.Plugin{&pickPlugin{}})
+	s.dumpFrames(context.Background(), nil, rx, logger, routing.Decision{RouteIndex: -1, SelectedIndex: -1}, errRateLimited, plugins.ConnectEvent{SNI: "play.example.com"})
+
+	if got := disconnectReasonFromFrameForTest(t, rx.w.Bytes()); got != "Zu viele Versuche." {
+		t.Fatalf("reason=%q", got)
+	}
+}
+
+func TestApplyConfig_SwapsLimiter(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	cfg := config.Default()
+	cfg.Routing.Default = &routing.Pool{Strategy: "round_robin", Backends: []routing.Backend{{Host: "a", Port: 1}}}
+	cfg.Limits = &config.LimitsConfig{Global: &config.RateLimitConfig{Rate: 1}}
+	s := New(cfg, logger)
+	first := s.connLimiter()
+	if first == nil {
+		t.Fatalf("expected limiter")
+	}
+
+	next := *cfg
+	next.Limits = &config.LimitsConfig{Global: &config.RateLimitConfig{Rate: 1}}
+	if err := s.ApplyConfig(context.Background(), &next); err != nil {
+		t.Fatalf("ApplyConfig: %v", err)
+	}
+	if s.connLimiter() != first {
+		t.Fatalf("expected unchanged limits to keep the limiter")
+	}
+
+	last := *cfg
+	last.Limits = nil
+	if err := s.ApplyConfig(context.Background(), &last); err != nil {
+		t.Fatalf("ApplyConfig: %v", err)
+	}
+	if s.connLimiter() != nil {
+		t.Fatalf("expected limiter to be removed")
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/queue"
+	"github.com/hybrowse/hyrouter/internal/routing"
+)
+
+func (s *Server) playerQueue() *queue.Queue {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.queue
+}
+
+func (s *Server) newQueue(cfg *config.QueueConfig) (*queue.Queue, error) {
+	q, err := queue.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	q.SetLengthObserver(s.metrics.SetQueueLength)
+	return q, nil
+}
+
+// runQueue retries queued players until the returned cancel func is called.
+func (s *Server) runQueue(ctx context.Context, q *queue.Queue) context.CancelFunc {
+	qctx, cancel := context.WithCancel(ctx)
+	go q.Run(qctx)
+	return cancel
+}
+
+// waitInQueue holds a player for whom no backend had capacity and re-runs Decide with the
+// current engine on every retry. Errors other than ErrNoBackends end the wait, so a route that
+// went into maintenance is reported as such.
+func (s *Server) waitInQueue(ctx context.Context, q *queue.Queue, logger *slog.Logger, req routing.Request, priority int) (routing.Decision, error) {
+	var d routing.Decision
+	var derr error
+	e, err := q.Join(priority, func() bool {
+		router, _ := s.engineAndPlugins()
+		if router == nil {
+			return false
+		}
+		d, derr = s.decide(ctx, router, req)
+		return !errors.Is(derr, routing.ErrNoBackends)
+	})
+	if err != nil {
+		logger.Info("queue join failed", "error", err)
+		return routing.Decision{}, routing.ErrNoBackends
+	}
+	logger.Info("queued", "position", q.Position(e), "priority", priority)
+	err = q.Wait(ctx, e)
+	result := "referred"
+	switch {
+	case err == nil && derr != nil:
+		err, result = derr, "error"
+	case errors.Is(err, queue.ErrTimeout):
+		result = "timeout"
+	case err != nil:
+		result = "left"
+	}
+	s.metrics.ObserveQueueWait(result, e.Waited())
+	logger.Info("left queue", "result", result, "waited_ms", e.Waited().Milliseconds())
+	if errors.Is(err, queue.ErrClosed) {
+		return routing.Decision{}, routing.ErrNoBackends
+	}
+	if err != nil {
+		// A retry may still be running; d belongs to it.
+		return routing.Decision{}, err
+	}
+	return d, nil
+}
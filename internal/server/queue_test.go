@@ -0,0 +1,92 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/plugins"
+	"github.com/hybrowse/hyrouter/internal/routing"
+)
+
+func queueServerForTest(t *testing.T, q *config.QueueConfig, full *atomic.Bool) *Server {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Routing = routing.Config{Default: &routing.Pool{Strategy: "round_robin", Backends: []routing.Backend{{Host: "a", Port: 1}}}}
+	cfg.Queue = q
+	s := New(cfg, slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{})))
+	if s.queue == nil {
+		t.Fatalf("expected queue")
+	}
+	s.router.(*routing.StaticEngine).SetExcluded(func(routing.Target) bool { return full.Load() })
+	return s
+}
+
+func connectFrameForTest() []byte {
+	connectPayload := buildConnectPayloadForTest(
+		"6708f121966c1c443f4b0eb525b2f81d0a8dc61f5003a692a8fa157e5e02cea9",
+		0,
+		"d3e6ef90-e113-49a7-a845-1c11f24fe166",
+		"de-DE",
+		"tok",
+		"Krymo",
+	)
+	frame := make([]byte, 8+len(connectPayload))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(connectPayload)))
+	binary.LittleEndian.PutUint32(frame[4:8], 0)
+	copy(frame[8:], connectPayload)
+	return frame
+}
+
+func TestDumpFrames_QueueReferralOnCapacity(t *testing.T) {
+	full := &atomic.Bool{}
+	full.Store(true)
+	s := queueServerForTest(t, &config.QueueConfig{MaxWait: "1m"}, full)
+
+	rx := &rw{r: bytes.NewReader(connectFrameForTest())}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.dumpFrames(context.Background(), nil, rx, s.logger, routing.Decision{RouteIndex: -1, SelectedIndex: -1}, nil, plugins.ConnectEvent{})
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for s.queue.Len() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("player was not queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	s.queue.Retry()
+	if s.queue.Len() != 1 {
+		t.Fatalf("expected the player to keep waiting")
+	}
+	full.Store(false)
+	s.queue.Retry()
+	<-done
+
+	out := rx.w.Bytes()
+	if len(out) < 8 || binary.LittleEndian.Uint32(out[4:8]) != 18 {
+		t.Fatalf("expected a referral, got %x", out)
+	}
+}
+
+func TestDumpFrames_QueueTimeoutSendsDisconnect(t *testing.T) {
+	full := &atomic.Bool{}
+	full.Store(true)
+	s := queueServerForTest(t, &config.QueueConfig{MaxWait: "20ms"}, full)
+	s.cfg.Messages.DisconnectLocales = map[string]config.DisconnectMessagesConfig{"de": {QueueTimeout: "Kein Platz frei."}}
+
+	rx := &rw{r: bytes.NewReader(connectFrameForTest())}
+	s.dumpFrames(context.Background(), nil, rx, s.logger, routing.Decision{RouteIndex: -1, SelectedIndex: -1}, nil, plugins.ConnectEvent{})
+
+	if got := disconnectReasonFromFrameForTest(t, rx.w.Bytes()); got != "Kein Platz frei." {
+		t.Fatalf("reason=%q", got)
+	}
+}
@@ -0,0 +1,366 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/discovery"
+	"github.com/hybrowse/hyrouter/internal/filewatch"
+	"github.com/hybrowse/hyrouter/internal/health"
+	"github.com/hybrowse/hyrouter/internal/inflight"
+	"github.com/hybrowse/hyrouter/internal/limits"
+	"github.com/hybrowse/hyrouter/internal/outlier"
+	"github.com/hybrowse/hyrouter/internal/plugins"
+	"github.com/hybrowse/hyrouter/internal/routing"
+)
+
+// pluginDrainDelay is how long replaced plugins stay open so that in-flight OnConnect calls can finish.
+// It must exceed the per-call plugin timeout.
+const pluginDrainDelay = 5 * time.Second
+
+func (s *Server) watchReload(ctx context.Context) {
+	cfg := s.config()
+	src := cfg.Source()
+	if src == "" {
+		return
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	changed := make(chan struct{}, 1)
+	if cfg.Reload.Watch {
+		go filewatch.Poll(ctx, src, reloadInterval(cfg), func() {
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		})
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			s.logger.Info("reloading config", "trigger", "sighup", "path", src)
+		case <-changed:
+			s.logger.Info("reloading config", "trigger", "file_change", "path", src)
+		}
+		_ = s.Reload(ctx)
+	}
+}
+
+// Reload re-reads the config from its source file and applies it.
+// An invalid config is rejected and the current one keeps serving.
+func (s *Server) Reload(ctx context.Context) error {
+	src := s.config().Source()
+	if src == "" {
+		return fmt.Errorf("config source is unknown")
+	}
+	cfg, err := config.Load(src)
+	if err != nil {
+		s.logger.Warn("config reload rejected", "error", err)
+		s.metrics.ConfigReloaded(false)
+		return err
+	}
+	if err := s.ApplyConfig(ctx, cfg); err != nil {
+		s.logger.Warn("config reload rejected", "error", err)
+		s.metrics.ConfigReloaded(false)
+		return err
+	}
+	s.metrics.ConfigReloaded(true)
+	return nil
+}
+
+// ApplyConfig atomically swaps routing, messages, referral settings, plugins, discovery providers, health checks, outlier detection, referral accounting, queue, limits and access lists.
+// Listener settings (listen, tls, quic, metrics, admin.listen) are only applied on restart.
+func (s *Server) ApplyConfig(ctx context.Context, cfg *config.Config) error {
+	if cfg == nil {
+		return fmt.Errorf("config must not be nil")
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	runCtx := s.runCtx
+	started := runCtx != nil
+	if runCtx == nil {
+		runCtx = ctx
+	}
+
+	s.mu.RLock()
+	oldCfg := s.cfg
+	oldRouter := s.router
+	oldDiscovery := s.discovery
+	oldPlugins := s.plugins
+	oldHealth := s.health
+	oldOutlier := s.outlier
+	oldRecent := s.recent
+	oldQueue := s.queue
+	s.mu.RUnlock()
+
+	if oldCfg != nil {
+		s.warnRestartOnly(oldCfg, cfg)
+	}
+
+	keyID, secret, err := referralFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Components built below are released again unless the new config is committed.
+	var (
+		committed  bool
+		dcancel    context.CancelFunc
+		newPlugins *plugins.Manager
+	)
+	defer func() {
+		if committed {
+			return
+		}
+		if dcancel != nil {
+			dcancel()
+		}
+		newPlugins.Close(context.Background())
+	}()
+
+	dm := oldDiscovery
+	replaceDiscovery := oldCfg == nil || !reflect.DeepEqual(oldCfg.Discovery, cfg.Discovery)
+	if replaceDiscovery {
+		dm = nil
+		if cfg.Discovery != nil {
+			m, err := discovery.New(cfg.Discovery, s.logger)
+			if err != nil {
+				return err
+			}
+			dctx, cancel := context.WithCancel(runCtx)
+			dcancel = cancel
+			if err := m.Start(dctx); err != nil {
+				return err
+			}
+			dm = m
+		}
+	}
+
+	hc := oldHealth
+	replaceHealth := oldCfg == nil || !reflect.DeepEqual(oldCfg.HealthCheck, cfg.HealthCheck)
+	if replaceHealth {
+		hc = nil
+		if cfg.HealthCheck != nil {
+			hc, err = health.New(cfg.HealthCheck, s.logger)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	od := oldOutlier
+	replaceOutlier := oldCfg == nil || !reflect.DeepEqual(oldCfg.OutlierDetection, cfg.OutlierDetection)
+	if replaceOutlier {
+		od = nil
+		if cfg.OutlierDetection != nil {
+			od, err = outlier.New(cfg.OutlierDetection, s.logger)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	rt := oldRecent
+	replaceRecent := oldCfg == nil || !reflect.DeepEqual(oldCfg.ReferralAccounting, cfg.ReferralAccounting)
+	if replaceRecent {
+		rt = nil
+		if cfg.ReferralAccounting != nil {
+			rt, err = inflight.New(cfg.ReferralAccounting)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	limiter := s.connLimiter()
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.Limits, cfg.Limits) {
+		limiter = nil
+		if cfg.Limits != nil {
+			limiter, err = limits.New(cfg.Limits)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	ap := s.accessPolicy()
+	replaceAccess := oldCfg == nil || !accessEqual(oldCfg, cfg)
+	if replaceAccess {
+		ap, err = newAccessPolicy(cfg)
+		if err != nil {
+			return err
+		}
+	}
+
+	pm := oldPlugins
+	replacePlugins := oldCfg == nil || !reflect.DeepEqual(oldCfg.Plugins, cfg.Plugins)
+	if replacePlugins {
+		pm, err = s.loadPlugins(runCtx, cfg.Plugins)
+		if err != nil {
+			return err
+		}
+		newPlugins = pm
+	}
+
+	q := oldQueue
+	replaceQueue := false
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.Queue, cfg.Queue) {
+		// A changed queue keeps its waiting players; only enabling or disabling it replaces it.
+		switch {
+		case cfg.Queue == nil:
+			q, replaceQueue = nil, oldQueue != nil
+		case oldQueue == nil:
+			q, err = s.newQueue(cfg.Queue)
+			replaceQueue = true
+		default:
+			err = oldQueue.Update(cfg.Queue)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	se := s.newEngine(cfg, dm, hc, od, rt)
+	if old, ok := oldRouter.(*routing.StaticEngine); ok {
+		se.InheritCounters(old)
+	}
+
+	committed = true
+	s.mu.Lock()
+	s.cfg = cfg
+	s.router = se
+	s.pluginCfgs = cfg.Plugins
+	s.plugins = pm
+	s.referralKeyID = keyID
+	s.referralSecret = secret
+	s.outlier = od
+	s.recent = rt
+	s.limiter = limiter
+	var oldDiscoveryCancel context.CancelFunc
+	if replaceDiscovery {
+		s.discovery = dm
+		oldDiscoveryCancel = s.discoveryCancel
+		s.discoveryCancel = dcancel
+	}
+	var oldAccessCancel context.CancelFunc
+	if replaceAccess {
+		s.access = ap
+		oldAccessCancel = s.accessCancel
+		s.accessCancel = nil
+	}
+	var oldQueueCancel context.CancelFunc
+	if replaceQueue {
+		s.queue = q
+		oldQueueCancel = s.queueCancel
+		s.queueCancel = nil
+	}
+	var oldHealthCancel context.CancelFunc
+	if replaceHealth {
+		s.health = hc
+		oldHealthCancel = s.healthCancel
+		s.healthCancel = nil
+	}
+	s.mu.Unlock()
+	s.control.retainMaintenance(routing.RouteKeys(cfg.Routing.Routes))
+
+	if oldDiscoveryCancel != nil {
+		oldDiscoveryCancel()
+	}
+	if oldHealthCancel != nil {
+		oldHealthCancel()
+	}
+	if replaceHealth && hc != nil && started {
+		cancel := s.runHealth(runCtx, hc)
+		s.mu.Lock()
+		s.healthCancel = cancel
+		s.mu.Unlock()
+	}
+	if oldAccessCancel != nil {
+		oldAccessCancel()
+	}
+	if replaceAccess && ap != nil && started {
+		cancel := s.runAccessWatch(runCtx, ap)
+		s.mu.Lock()
+		s.accessCancel = cancel
+		s.mu.Unlock()
+	}
+	if oldQueueCancel != nil {
+		oldQueueCancel()
+	}
+	if replaceQueue && oldQueue != nil {
+		oldQueue.Close()
+	}
+	if replaceQueue && q != nil && started {
+		cancel := s.runQueue(runCtx, q)
+		s.mu.Lock()
+		s.queueCancel = cancel
+		s.mu.Unlock()
+	}
+	if replacePlugins && oldPlugins != nil {
+		closePluginsLater(oldPlugins)
+	}
+
+	s.logger.Info(
+		"config applied",
+		"routes", len(cfg.Routing.Routes),
+		"plugins_replaced", replacePlugins,
+		"discovery_replaced", replaceDiscovery,
+		"health_check_replaced", replaceHealth,
+		"outlier_detection_replaced", replaceOutlier,
+		"referral_accounting_replaced", replaceRecent,
+		"queue_replaced", replaceQueue,
+		"access_replaced", replaceAccess,
+	)
+	return nil
+}
+
+func closePluginsLater(pm *plugins.Manager) {
+	time.AfterFunc(pluginDrainDelay, func() {
+		pm.Close(context.Background())
+	})
+}
+
+func (s *Server) warnRestartOnly(oldCfg *config.Config, cfg *config.Config) {
+	if oldCfg.Listen != cfg.Listen {
+		s.logger.Warn("config change requires restart", "field", "listen")
+	}
+	if !reflect.DeepEqual(oldCfg.TLS, cfg.TLS) {
+		s.logger.Warn("config change requires restart", "field", "tls")
+	}
+	if oldCfg.QUIC != cfg.QUIC {
+		s.logger.Warn("config change requires restart", "field", "quic")
+	}
+	if !reflect.DeepEqual(oldCfg.Metrics, cfg.Metrics) {
+		s.logger.Warn("config change requires restart", "field", "metrics")
+	}
+	if adminListen(oldCfg) != adminListen(cfg) {
+		s.logger.Warn("config change requires restart", "field", "admin.listen")
+	}
+	if oldCfg.Reload != cfg.Reload {
+		s.logger.Warn("config change requires restart", "field", "reload")
+	}
+}
+
+func adminListen(cfg *config.Config) string {
+	if cfg.Admin == nil {
+		return ""
+	}
+	return cfg.Admin.Listen
+}
@@ -0,0 +1,294 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/routing"
+	"github.com/quic-go/quic-go"
+)
+
+func writeConfigForTest(t *testing.T, path string, backendHost string) {
+	t.Helper()
+	b := []byte("listen: '127.0.0.1:0'\nrouting:\n  default:\n    strategy: round_robin\n    backends:\n      - host: " + backendHost + "\n        port: 5520\n")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+}
+
+func decideHostForTest(t *testing.T, s *Server) string {
+	t.Helper()
+	router, _ := s.engineAndPlugins()
+	dec, err := router.Decide(context.Background(), routing.Request{SNI: "x"})
+	if err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+	return dec.Backend.Host
+}
+
+func TestApplyConfig_SwapsRoutingAndMessages(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	cfg := config.Default()
+	cfg.Routing.Default = &routing.Pool{Strategy: "round_robin", Backends: []routing.Backend{{Host: "old", Port: 1}}}
+	s := New(cfg, logger)
+
+	next := config.Default()
+	next.Routing.Default = &routing.Pool{Strategy: "round_robin", Backends: []routing.Backend{{Host: "new", Port: 1}}}
+	next.Messages.Disconnect.NoRoute = "reloaded"
+	next.Referral = &config.ReferralConfig{KeyID: 7, HMACSecret: "secret"}
+	if err := s.ApplyConfig(context.Background(), next); err != nil {
+		t.Fatalf("ApplyConfig: %v", err)
+	}
+	if got := decideHostForTest(t, s); got != "new" {
+		t.Fatalf("backend=%q", got)
+	}
+	if got := s.disconnectReason("x", "", nil); got != "reloaded" {
+		t.Fatalf("reason=%q", got)
+	}
+	if s.referralKeyID != 7 || string(s.referralSecret) != "secret" {
+		t.Fatalf("referral not swapped: %d %q", s.referralKeyID, s.referralSecret)
+	}
+}
+
+func TestApplyConfig_RejectsInvalidAndKeepsOld(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	cfg := config.Default()
+	cfg.Routing.Default = &routing.Pool{Strategy: "round_robin", Backends: []routing.Backend{{Host: "old", Port: 1}}}
+	s := New(cfg, logger)
+
+	bad := config.Default()
+	bad.Routing.Default = &routing.Pool{Strategy: "nope", Backends: []routing.Backend{{Host: "new", Port: 1}}}
+	if err := s.ApplyConfig(context.Background(), bad); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	bad = config.Default()
+	bad.Referral = &config.ReferralConfig{HMACSecret: "base64:!!!"}
+	if err := s.ApplyConfig(context.Background(), bad); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if got := decideHostForTest(t, s); got != "old" {
+		t.Fatalf("backend=%q", got)
+	}
+}
+
+func TestApplyConfig_KeepsRoundRobinPosition(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	pool := &routing.Pool{Strategy: "round_robin", Backends: []routing.Backend{{Host: "a", Port: 1}, {Host: "b", Port: 1}}}
+	cfg := config.Default()
+	cfg.Routing.Default = pool
+	s := New(cfg, logger)
+	if got := decideHostForTest(t, s); got != "a" {
+		t.Fatalf("backend=%q", got)
+	}
+
+	next := config.Default()
+	next.Routing.Default = pool
+	if err := s.ApplyConfig(context.Background(), next); err != nil {
+		t.Fatalf("ApplyConfig: %v", err)
+	}
+	if got := decideHostForTest(t, s); got != "b" {
+		t.Fatalf("expected rotation to continue, got %q", got)
+	}
+}
+
+func TestApplyConfig_MaintenanceFollowsReorderedRoutes(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	route := func(host string) routing.Route {
+		return routing.Route{
+			Match: routing.Match{Hostname: host},
+			Pool:  routing.Pool{Strategy: "round_robin", Backends: []routing.Backend{{Host: host + "-backend", Port: 1}}},
+		}
+	}
+	decide := func(s *Server, sni string) error {
+		router, _ := s.engineAndPlugins()
+		_, err := router.Decide(context.Background(), routing.Request{SNI: sni})
+		return err
+	}
+
+	cfg := config.Default()
+	cfg.Routing.Routes = []routing.Route{route("a.example.com"), route("b.example.com"), route("c.example.com")}
+	s := New(cfg, logger)
+	s.control.setMaintenance(routeKey(s.config(), 0), true)
+	if err := decide(s, "a.example.com"); !errors.Is(err, routing.ErrMaintenance) {
+		t.Fatalf("err=%v", err)
+	}
+
+	// Insert a route in front and reorder the rest: maintenance stays with a.example.com.
+	next := config.Default()
+	next.Routing.Routes = []routing.Route{route("new.example.com"), route("c.example.com"), route("a.example.com"), route("b.example.com")}
+	if err := s.ApplyConfig(context.Background(), next); err != nil {
+		t.Fatalf("ApplyConfig: %v", err)
+	}
+	if err := decide(s, "a.example.com"); !errors.Is(err, routing.ErrMaintenance) {
+		t.Fatalf("a: err=%v", err)
+	}
+	for _, sni := range []string{"new.example.com", "b.example.com", "c.example.com"} {
+		if err := decide(s, sni); err != nil {
+			t.Fatalf("%s: err=%v", sni, err)
+		}
+	}
+
+	// Removing the route drops its maintenance, so adding it back later starts clean.
+	next = config.Default()
+	next.Routing.Routes = []routing.Route{route("b.example.com")}
+	if err := s.ApplyConfig(context.Background(), next); err != nil {
+		t.Fatalf("ApplyConfig: %v", err)
+	}
+	next = config.Default()
+	next.Routing.Routes = []routing.Route{route("a.example.com"), route("b.example.com")}
+	if err := s.ApplyConfig(context.Background(), next); err != nil {
+		t.Fatalf("ApplyConfig: %v", err)
+	}
+	if err := decide(s, "a.example.com"); err != nil {
+		t.Fatalf("a after re-add: err=%v", err)
+	}
+}
+
+func TestReload_FromSourceFile(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfigForTest(t, path, "old")
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	s := New(cfg, logger)
+
+	if err := os.WriteFile(path, []byte("listen: ''\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := s.Reload(context.Background()); err == nil {
+		t.Fatalf("expected error for invalid config")
+	}
+	if got := decideHostForTest(t, s); got != "old" {
+		t.Fatalf("backend=%q", got)
+	}
+
+	writeConfigForTest(t, path, "new")
+	if err := s.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := decideHostForTest(t, s); got != "new" {
+		t.Fatalf("backend=%q", got)
+	}
+
+	if err := New(config.Default(), logger).Reload(context.Background()); err == nil {
+		t.Fatalf("expected error without config source")
+	}
+}
+
+func TestWatchReload_FileChange(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfigForTest(t, path, "old")
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	cfg.Reload = config.ReloadConfig{Watch: true, Interval: "10ms"}
+	s := New(cfg, logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.watchReload(ctx)
+
+	time.Sleep(50 * time.Millisecond)
+	writeConfigForTest(t, path, "new-host")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if decideHostForTest(t, s) == "new-host" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected config to be reloaded after file change")
+}
+
+func TestApplyConfig_HealthCheck(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	cfg := config.Default()
+	cfg.HealthCheck = &config.HealthCheckConfig{UnhealthyThreshold: 1}
+	cfg.Routing.Default = &routing.Pool{Strategy: "round_robin", Backends: []routing.Backend{{Host: "down", Port: 1}, {Host: "up", Port: 1}}}
+	s := New(cfg, logger)
+	hc := s.health
+	if hc == nil {
+		t.Fatalf("expected health checker")
+	}
+	hc.SetProbe(func(ctx context.Context, tgt routing.Target) (time.Duration, error) {
+		if tgt.Host == "down" {
+			return 0, context.DeadlineExceeded
+		}
+		return time.Millisecond, nil
+	})
+	router, _ := s.engineAndPlugins()
+	hc.CheckOnce(context.Background(), router.(*routing.StaticEngine).Targets(context.Background()))
+	for i := 0; i < 4; i++ {
+		if got := decideHostForTest(t, s); got != "up" {
+			t.Fatalf("backend=%q", got)
+		}
+	}
+
+	// Unchanged health_check keeps the checker and its state across reloads.
+	next := config.Default()
+	next.HealthCheck = &config.HealthCheckConfig{UnhealthyThreshold: 1}
+	next.Routing.Default = cfg.Routing.Default
+	if err := s.ApplyConfig(context.Background(), next); err != nil {
+		t.Fatalf("ApplyConfig: %v", err)
+	}
+	if s.health != hc {
+		t.Fatalf("expected health checker to be kept")
+	}
+	if got := decideHostForTest(t, s); got != "up" {
+		t.Fatalf("backend=%q", got)
+	}
+
+	next = config.Default()
+	next.Routing.Default = cfg.Routing.Default
+	if err := s.ApplyConfig(context.Background(), next); err != nil {
+		t.Fatalf("ApplyConfig: %v", err)
+	}
+	if s.health != nil {
+		t.Fatalf("expected health checker to be removed")
+	}
+}
+
+func TestApplyConfig_QueueEnabledByReloadKeepsIdleConnectionsOpen(t *testing.T) {
+	addr := reserveUDPAddr(t)
+	cfg := config.Default()
+	cfg.Listen = addr
+	cfg.QUIC.MaxIdleTimeout = "300ms"
+	cfg.Routing.Default = &routing.Pool{Strategy: "round_robin", Backends: []routing.Backend{{Host: "a", Port: 5520}}}
+	s := New(cfg, slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{})))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = s.Run(ctx)
+	}()
+
+	next := *cfg
+	next.Queue = &config.QueueConfig{MaxLength: 10}
+	// The client idles out as fast as the server, so only the server's keep-alives hold the
+	// connection open.
+	conn := dialQUICWithConfig(t, addr, &quic.Config{MaxIdleTimeout: 300 * time.Millisecond})
+	defer conn.CloseWithError(0, "done") // nolint:errcheck
+	if err := s.ApplyConfig(ctx, &next); err != nil {
+		t.Fatalf("ApplyConfig: %v", err)
+	}
+
+	// A queued player sends nothing while waiting.
+	select {
+	case <-conn.Context().Done():
+		t.Fatalf("connection closed while idle: %v", context.Cause(conn.Context()))
+	case <-time.After(time.Second):
+	}
+}
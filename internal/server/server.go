@@ -5,99 +5,287 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/hybrowse/hyrouter/internal/access"
 	"github.com/hybrowse/hyrouter/internal/config"
 	"github.com/hybrowse/hyrouter/internal/discovery"
+	"github.com/hybrowse/hyrouter/internal/health"
+	"github.com/hybrowse/hyrouter/internal/inflight"
+	"github.com/hybrowse/hyrouter/internal/limits"
+	"github.com/hybrowse/hyrouter/internal/metrics"
+	"github.com/hybrowse/hyrouter/internal/outlier"
 	"github.com/hybrowse/hyrouter/internal/plugins"
+	"github.com/hybrowse/hyrouter/internal/queue"
 	"github.com/hybrowse/hyrouter/internal/referral"
 	"github.com/hybrowse/hyrouter/internal/routing"
 	"github.com/quic-go/quic-go"
 )
 
 type Server struct {
-	cfg        *config.Config
-	logger     *slog.Logger
-	router     routing.Engine
-	discovery  *discovery.Manager
-	initErr    error
-	pluginCfgs []config.PluginConfig
-	plugins    *plugins.Manager
+	logger  *slog.Logger
+	initErr error
+	metrics *metrics.Metrics
+	control *controlState
 
-	referralKeyID  uint8
-	referralSecret []byte
+	// mu guards the fields below, which are swapped on config reload.
+	mu              sync.RWMutex
+	cfg             *config.Config
+	router          routing.Engine
+	discovery       *discovery.Manager
+	discoveryCancel context.CancelFunc
+	health          *health.Checker
+	healthCancel    context.CancelFunc
+	outlier         *outlier.Detector
+	recent          *inflight.Tracker
+	queue           *queue.Queue
+	queueCancel     context.CancelFunc
+	limiter         *limits.Limiter
+	access          *access.Policy
+	accessCancel    context.CancelFunc
+	pluginCfgs      []config.PluginConfig
+	plugins         *plugins.Manager
+	referralKeyID   uint8
+	referralSecret  []byte
+
+	reloadMu sync.Mutex
+	runCtx   context.Context
+
+	inFlight atomic.Int64
 }
 
 func New(cfg *config.Config, logger *slog.Logger) *Server {
-	var r routing.Engine
-	var se *routing.StaticEngine
-	var pcfgs []config.PluginConfig
-	var dm *discovery.Manager
-	var initErr error
-	var refKeyID uint8
-	var refSecret []byte
-	if cfg != nil {
-		se = routing.NewStaticEngine(cfg.Routing)
-		r = se
-		pcfgs = cfg.Plugins
-		if cfg.Discovery != nil {
-			m, err := discovery.New(cfg.Discovery, logger)
-			if err != nil {
-				initErr = err
-			} else {
-				dm = m
-				if se != nil {
-					se.SetDiscovery(func(ctx context.Context, provider string) ([]routing.Backend, error) {
-						bs, ok, err := dm.Resolve(ctx, provider)
-						if err != nil {
-							return nil, err
-						}
-						if !ok {
-							return nil, fmt.Errorf("unknown discovery provider %q", provider)
-						}
-						return bs, nil
-					})
-				}
+	s := &Server{cfg: cfg, logger: logger, control: newControlState()}
+	if cfg == nil {
+		return s
+	}
+	if cfg.Metrics != nil {
+		s.metrics = metrics.New()
+	}
+	s.pluginCfgs = cfg.Plugins
+	if cfg.Discovery != nil {
+		d, err := discovery.New(cfg.Discovery, logger)
+		if err != nil {
+			s.initErr = err
+		} else {
+			s.discovery = d
+		}
+	}
+	if cfg.HealthCheck != nil {
+		hc, err := health.New(cfg.HealthCheck, logger)
+		if err != nil {
+			if s.initErr == nil {
+				s.initErr = err
+			}
+		} else {
+			s.health = hc
+		}
+	}
+	if cfg.OutlierDetection != nil {
+		od, err := outlier.New(cfg.OutlierDetection, logger)
+		if err != nil {
+			if s.initErr == nil {
+				s.initErr = err
+			}
+		} else {
+			s.outlier = od
+		}
+	}
+	if cfg.ReferralAccounting != nil {
+		rt, err := inflight.New(cfg.ReferralAccounting)
+		if err != nil {
+			if s.initErr == nil {
+				s.initErr = err
+			}
+		} else {
+			s.recent = rt
+		}
+	}
+	if cfg.Queue != nil {
+		q, err := s.newQueue(cfg.Queue)
+		if err != nil {
+			if s.initErr == nil {
+				s.initErr = err
 			}
+		} else {
+			s.queue = q
 		}
-		if cfg.Referral != nil {
-			refKeyID = cfg.Referral.KeyID
-			if strings.TrimSpace(cfg.Referral.HMACSecret) != "" {
-				b, err := referral.DecodeSecret(cfg.Referral.HMACSecret)
-				if err != nil {
-					if initErr == nil {
-						initErr = fmt.Errorf("invalid referral.hmac_secret: %w", err)
-					}
-				} else {
-					refSecret = b
-				}
+	}
+	if cfg.Limits != nil {
+		l, err := limits.New(cfg.Limits)
+		if err != nil {
+			if s.initErr == nil {
+				s.initErr = err
 			}
+		} else {
+			s.limiter = l
 		}
 	}
-	return &Server{cfg: cfg, logger: logger, router: r, pluginCfgs: pcfgs, discovery: dm, initErr: initErr, referralKeyID: refKeyID, referralSecret: refSecret}
+	if ap, err := newAccessPolicy(cfg); err != nil {
+		if s.initErr == nil {
+			s.initErr = err
+		}
+	} else {
+		s.access = ap
+	}
+	s.router = s.newEngine(cfg, s.discovery, s.health, s.outlier, s.recent)
+	keyID, secret, err := referralFromConfig(cfg)
+	if err != nil {
+		if s.initErr == nil {
+			s.initErr = err
+		}
+	} else {
+		s.referralKeyID = keyID
+		s.referralSecret = secret
+	}
+	return s
 }
 
-func (s *Server) initPlugins(ctx context.Context) error {
-	if s.plugins != nil {
-		return nil
+func (s *Server) newEngine(cfg *config.Config, dm *discovery.Manager, hc *health.Checker, od *outlier.Detector, rt *inflight.Tracker) *routing.StaticEngine {
+	se := routing.NewStaticEngine(cfg.Routing)
+	if dm != nil {
+		se.SetDiscovery(func(ctx context.Context, provider string) ([]routing.Backend, error) {
+			bs, ok, err := dm.Resolve(ctx, provider)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, fmt.Errorf("unknown discovery provider %q", provider)
+			}
+			return bs, nil
+		})
+	}
+	if s.control != nil {
+		control := s.control
+		se.SetExcluded(func(t routing.Target) bool {
+			return control.isDrained(t) || od.Ejected(t)
+		})
+		se.SetMaintenance(func(routeIndex int) bool {
+			return control.inMaintenance(se.RouteKey(routeIndex))
+		})
+	}
+	if hc != nil {
+		se.SetHealth(hc.Status, hc.FailOpen())
 	}
-	if len(s.pluginCfgs) == 0 {
+	if rt != nil {
+		se.SetRecentReferrals(rt.Recent)
+	}
+	return se
+}
+
+func referralFromConfig(cfg *config.Config) (uint8, []byte, error) {
+	if cfg == nil || cfg.Referral == nil {
+		return 0, nil, nil
+	}
+	if strings.TrimSpace(cfg.Referral.HMACSecret) == "" {
+		return cfg.Referral.KeyID, nil, nil
+	}
+	b, err := referral.DecodeSecret(cfg.Referral.HMACSecret)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid referral.hmac_secret: %w", err)
+	}
+	return cfg.Referral.KeyID, b, nil
+}
+
+func (s *Server) config() *config.Config {
+	if s == nil {
 		return nil
 	}
-	ordered, err := plugins.OrderPluginConfigs(s.pluginCfgs)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg
+}
+
+func (s *Server) outlierDetector() *outlier.Detector {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.outlier
+}
+
+func (s *Server) recentReferrals() *inflight.Tracker {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.recent
+}
+
+func (s *Server) engineAndPlugins() (routing.Engine, *plugins.Manager) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.router, s.plugins
+}
+
+func (s *Server) loadPlugins(ctx context.Context, cfgs []config.PluginConfig) (*plugins.Manager, error) {
+	if len(cfgs) == 0 {
+		return nil, nil
+	}
+	ordered, err := plugins.OrderPluginConfigs(cfgs)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	pls, err := plugins.LoadAll(ctx, ordered, s.logger)
+	if err != nil {
+		return nil, err
+	}
+	pm := plugins.NewManager(s.logger, pls)
+	if s.metrics != nil {
+		pm.SetCallObserver(s.metrics.ObservePluginCall)
+	}
+	return pm, nil
+}
+
+func (s *Server) initPlugins(ctx context.Context) error {
+	if s.plugins != nil {
+		return nil
+	}
+	pm, err := s.loadPlugins(ctx, s.pluginCfgs)
 	if err != nil {
 		return err
 	}
-	s.plugins = plugins.NewManager(s.logger, pls)
+	s.plugins = pm
 	return nil
 }
 
+// start starts discovery providers and loads plugins.
+func (s *Server) start(ctx context.Context) error {
+	s.runCtx = ctx
+	if s.discovery != nil {
+		dctx, cancel := context.WithCancel(ctx)
+		if err := s.discovery.Start(dctx); err != nil {
+			cancel()
+			return err
+		}
+		s.discoveryCancel = cancel
+	}
+	if s.health != nil {
+		s.healthCancel = s.runHealth(ctx, s.health)
+	}
+	if s.access != nil {
+		s.accessCancel = s.runAccessWatch(ctx, s.access)
+	}
+	if s.queue != nil {
+		s.queueCancel = s.runQueue(ctx, s.queue)
+	}
+	return s.initPlugins(ctx)
+}
+
+// runHealth probes the backends of whichever engine is current, so it keeps working across reloads.
+func (s *Server) runHealth(ctx context.Context, hc *health.Checker) context.CancelFunc {
+	hctx, cancel := context.WithCancel(ctx)
+	go hc.Run(hctx, func(ctx context.Context) []routing.Target {
+		router, _ := s.engineAndPlugins()
+		se, ok := router.(*routing.StaticEngine)
+		if !ok {
+			return nil
+		}
+		return se.Targets(ctx)
+	})
+	return cancel
+}
+
 func (s *Server) Run(ctx context.Context) error {
 	if s.initErr != nil {
 		return s.initErr
@@ -106,16 +294,22 @@ func (s *Server) Run(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	if s.discovery != nil {
-		if err := s.discovery.Start(ctx); err != nil {
+	if err := s.start(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		_, pm := s.engineAndPlugins()
+		pm.Close(ctx)
+	}()
+	if s.metrics != nil {
+		if err := s.serveMetrics(ctx); err != nil {
 			return err
 		}
 	}
-	if err := s.initPlugins(ctx); err != nil {
-		return err
-	}
-	if s.plugins != nil {
-		defer s.plugins.Close(ctx)
+	if s.cfg.Admin != nil {
+		if err := s.serveAdmin(ctx); err != nil {
+			return err
+		}
 	}
 
 	maxIdleTimeout := 30 * time.Second
@@ -129,6 +323,9 @@ func (s *Server) Run(ctx context.Context) error {
 
 	quicConfig := &quic.Config{
 		MaxIdleTimeout: maxIdleTimeout,
+		// Queued players may not send anything while they wait. A reload can enable the queue,
+		// so keep-alives are always on.
+		KeepAlivePeriod: maxIdleTimeout / 2,
 	}
 
 	listener, err := quic.ListenAddr(s.cfg.Listen, tlsConfig, quicConfig)
@@ -138,6 +335,7 @@ func (s *Server) Run(ctx context.Context) error {
 	defer listener.Close() // nolint:errcheck
 
 	s.logger.Info("listening", "addr", s.cfg.Listen)
+	go s.watchReload(ctx)
 
 	for {
 		conn, err := listener.Accept(ctx)
@@ -149,24 +347,35 @@ func (s *Server) Run(ctx context.Context) error {
 			return err
 		}
 
-		go s.handleConn(ctx, conn)
+		admitted, admitErr := s.admit(conn)
+		if !admitted {
+			continue
+		}
+		go func() {
+			defer s.release()
+			s.handleConn(ctx, conn, admitErr)
+		}()
 	}
 }
 
-func (s *Server) handleConn(ctx context.Context, conn *quic.Conn) {
+func (s *Server) handleConn(ctx context.Context, conn *quic.Conn, admitErr error) {
 	state := conn.ConnectionState()
 
 	attrs := []any{
 		"sni", state.TLS.ServerName,
 		"alpn", state.TLS.NegotiatedProtocol,
 	}
-	if s != nil && s.cfg != nil && s.cfg.Logging.LogClientIP {
+	if cfg := s.config(); cfg != nil && cfg.Logging.LogClientIP {
 		attrs = append(attrs, "remote_addr", conn.RemoteAddr().String())
 	}
 	logger := s.logger.With(attrs...)
+	s.metrics.ConnectionAccepted()
 
 	decision := routing.Decision{Matched: false, RouteIndex: -1, SelectedIndex: -1}
-	routeErr := error(nil)
+	routeErr := admitErr
+	if routeErr == nil {
+		routeErr = s.checkAccess(conn, state.TLS.ServerName, state.TLS.NegotiatedProtocol)
+	}
 
 	fp := ""
 	if len(state.TLS.PeerCertificates) > 0 {
@@ -194,3 +403,23 @@ func (s *Server) handleConn(ctx context.Context, conn *quic.Conn) {
 		logger.Debug("connection closed", "error", connCtx.Err(), "any_stream_accepted", anyStreamAccepted.Load())
 	}
 }
+
+// connALPN returns the negotiated ALPN protocol, or "" if conn is nil.
+func connALPN(conn *quic.Conn) string {
+	if conn == nil {
+		return ""
+	}
+	return conn.ConnectionState().TLS.NegotiatedProtocol
+}
+
+// connClientIP returns the client's IP address without the port, or "" if conn is nil.
+func connClientIP(conn *quic.Conn) string {
+	if conn == nil || conn.RemoteAddr() == nil {
+		return ""
+	}
+	addr := conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
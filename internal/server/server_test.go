@@ -2,11 +2,17 @@ package server
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"log/slog"
+	"net"
+	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/queue"
+	"github.com/hybrowse/hyrouter/internal/routing"
 )
 
 func TestRun_InvalidIdleTimeout(t *testing.T) {
@@ -38,3 +44,62 @@ func TestRun_ContextCanceledReturnsNil(t *testing.T) {
 		t.Fatalf("expected nil, got %v", err)
 	}
 }
+
+func TestDisconnectKind(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{err: nil, want: "no_route"},
+		{err: routing.ErrNoBackends, want: "no_backends"},
+		{err: routing.ErrMaintenance, want: "maintenance"},
+		{err: errRateLimited, want: "rate_limited"},
+		{err: errAccessDenied, want: "access_denied"},
+		{err: queue.ErrTimeout, want: "queue_timeout"},
+		{err: fmt.Errorf("%w: x", routing.ErrDiscovery), want: "discovery_error"},
+		{err: routing.ErrDiscoveryNotSet, want: "discovery_error"},
+		{err: routing.ErrUnknownStrategy, want: "routing_error"},
+	}
+	for _, tc := range cases {
+		if got := disconnectKind(tc.err); got != tc.want {
+			t.Fatalf("disconnectKind(%v)=%q want %q", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestRun_MetricsListenerServesMetrics(t *testing.T) {
+	// Reserve a port so the test knows where serveMetrics listens.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	cfg := config.Default()
+	cfg.Listen = "127.0.0.1:0"
+	cfg.Metrics = &config.MetricsConfig{Listen: addr}
+	s := New(cfg, slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{})))
+	if s.metrics == nil {
+		t.Fatalf("expected metrics to be enabled")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.serveMetrics(ctx); err != nil {
+		t.Fatalf("serveMetrics: %v", err)
+	}
+	s.metrics.ConnectionAccepted()
+
+	resp, err := http.Get("http://" + addr + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "\nhyrouter_") {
+		t.Fatalf("status=%d body=%s", resp.StatusCode, body)
+	}
+}
@@ -10,9 +10,11 @@ import (
 	"log/slog"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/hybrowse/hyrouter/internal/config"
 	"github.com/hybrowse/hyrouter/internal/plugins"
+	"github.com/hybrowse/hyrouter/internal/queue"
 	"github.com/hybrowse/hyrouter/internal/referral"
 	"github.com/hybrowse/hyrouter/internal/routing"
 	"github.com/quic-go/quic-go"
@@ -35,9 +37,13 @@ func (s *Server) acceptBidiStreams(ctx context.Context, conn *quic.Conn, logger
 func (s *Server) referralEnvelope(content []byte) ([]byte, error) {
 	keyID := uint8(0)
 	secret := []byte(nil)
-	if s != nil && s.referralSecret != nil {
-		secret = s.referralSecret
-		keyID = s.referralKeyID
+	if s != nil {
+		s.mu.RLock()
+		if s.referralSecret != nil {
+			secret = s.referralSecret
+			keyID = s.referralKeyID
+		}
+		s.mu.RUnlock()
 	}
 	return referral.EncodeV1(content, keyID, secret)
 }
@@ -83,6 +89,13 @@ func (s *Server) dumpFrames(ctx context.Context, conn *quic.Conn, r io.Reader, l
 	referralContent := []byte(nil)
 	backend := decision.Backend
 	loggedFirstPacket := false
+	router, pluginMgr := s.engineAndPlugins()
+	clientIP := connClientIP(conn)
+	alpn := connALPN(conn)
+	od := s.outlierDetector()
+	recent := s.recentReferrals()
+	clientKey := ""
+	rejected := errors.Is(routeErr, errRateLimited) || errors.Is(routeErr, errAccessDenied)
 
 	for {
 		n, err := r.Read(buf)
@@ -128,12 +141,36 @@ func (s *Server) dumpFrames(ctx context.Context, conn *quic.Conn, r io.Reader, l
 
 				if packetID == 0 {
 					if info, ok := decodeConnectPayload(payload); ok {
-						if s.router != nil {
-							d, err := s.router.Decide(ctx, routing.Request{SNI: baseEvent.SNI, UUID: info.uuid, Username: info.username, Language: info.language})
+						s.metrics.ConnectDecoded(true)
+						clientKey = od.ClientKey(info.uuid, clientIP)
+						// Clients that a backend sent back on purpose carry a referral source; only fresh
+						// reconnects hint at a failed referral.
+						if info.referralSource == nil {
+							od.Returned(clientKey)
+						}
+						clientType := info.clientType
+						req := routing.Request{
+							SNI:                 baseEvent.SNI,
+							UUID:                info.uuid,
+							Username:            info.username,
+							Language:            info.language,
+							ClientIP:            clientIP,
+							ALPN:                alpn,
+							ProtocolHash:        info.protocolHash,
+							ProtocolBuildNumber: int(info.protocolBuildNumber),
+							ClientVersion:       info.clientVersion,
+							ClientType:          &clientType,
+						}
+						if router != nil && !rejected {
+							d, err := s.decide(ctx, router, req)
+							if err == nil {
+								err = s.checkRouteAccess(clientIP, d.RouteIndex)
+							}
 							if err == nil {
 								decision = d
 								routeErr = nil
 								backend = decision.Backend
+								referralContent = decision.ReferralContent
 							} else {
 								routeErr = err
 								backend = routing.Backend{}
@@ -147,32 +184,18 @@ func (s *Server) dumpFrames(ctx context.Context, conn *quic.Conn, r io.Reader, l
 						ev.Username = info.username
 						ev.Language = info.language
 						ev.IdentityTokenPresent = info.identityTokenPresent
-						if s.plugins != nil {
-							res := s.plugins.ApplyOnConnect(ctx, ev, decision, referralContent)
+						// Maintenance and route access lists always deny, so plugins must not pick a backend.
+						var queuePriority *int
+						if pluginMgr != nil && !rejected && !errors.Is(routeErr, routing.ErrMaintenance) && !errors.Is(routeErr, errAccessDenied) {
+							res := pluginMgr.ApplyOnConnect(ctx, ev, decision, referralContent)
 							if res.Denied {
 								// Deny is terminal: send Disconnect and close the stream so the client can progress.
-								w, ok := r.(io.Writer)
-								if !ok {
-									logger.Info("failed to send disconnect", "error", "stream is not writable")
-									return
-								}
-								dp, derr := encodeDisconnectPayload(res.DenyReason)
-								if derr != nil {
-									logger.Info("failed to build disconnect", "error", derr)
-									return
-								}
-								if err := writeFramedPacket(w, 1, dp); err != nil {
-									logger.Info("failed to send disconnect", "error", err)
-									return
-								}
-								logger.Info("tx disconnect", "reason", res.DenyReason)
-								if c, ok := r.(interface{ Close() error }); ok {
-									_ = c.Close()
-								}
+								s.sendDisconnect(r, logger, res.DenyReason, "plugin_deny")
 								return
 							}
 							backend = res.Backend
 							referralContent = res.ReferralContent
+							queuePriority = res.QueuePriority
 						}
 						logger.Info(
 							"rx connect",
@@ -190,6 +213,40 @@ func (s *Server) dumpFrames(ctx context.Context, conn *quic.Conn, r io.Reader, l
 							logger.Debug("connect identity", "uuid", info.uuid, "username", info.username)
 						}
 
+						// Without a backend with capacity, the player waits in the queue (if enabled) and
+						// gets the plugins' final say once a backend frees up.
+						if q := s.playerQueue(); q != nil && !referralSent && backend.Host == "" && errors.Is(routeErr, routing.ErrNoBackends) {
+							priority := q.Priority(info.uuid)
+							if queuePriority != nil {
+								priority = *queuePriority
+							}
+							d, err := s.waitInQueue(ctx, q, logger, req, priority)
+							if ctx.Err() != nil {
+								return
+							}
+							if err == nil {
+								err = s.checkRouteAccess(clientIP, d.RouteIndex)
+							}
+							if err == nil {
+								decision = d
+								routeErr = nil
+								backend = decision.Backend
+								referralContent = decision.ReferralContent
+								if pluginMgr != nil {
+									res := pluginMgr.ApplyOnConnect(ctx, ev, decision, referralContent)
+									if res.Denied {
+										s.sendDisconnect(r, logger, res.DenyReason, "plugin_deny")
+										return
+									}
+									backend = res.Backend
+									referralContent = res.ReferralContent
+								}
+							} else {
+								routeErr = err
+								logger.Info("routing error", "error", err)
+							}
+						}
+
 						if !referralSent && backend.Host != "" {
 							w, ok := r.(io.Writer)
 							if ok {
@@ -213,8 +270,12 @@ func (s *Server) dumpFrames(ctx context.Context, conn *quic.Conn, r io.Reader, l
 										"port", backend.Port,
 										"matched", decision.Matched,
 										"route_index", decision.RouteIndex,
+										"split_arm", decision.SplitArm,
 										"content_len", len(data),
 									)
+									s.metrics.ReferralSent(decision.RouteIndex, decision.Strategy)
+									od.Referred(clientKey, backend.Target())
+									recent.Referred(backend.Target())
 								}
 							}
 						}
@@ -222,36 +283,24 @@ func (s *Server) dumpFrames(ctx context.Context, conn *quic.Conn, r io.Reader, l
 						if !referralSent && backend.Host == "" {
 							reason := s.disconnectReason(baseEvent.SNI, ev.Language, routeErr)
 							if reason != "" {
-								w, ok := r.(io.Writer)
-								if !ok {
-									logger.Info("failed to send disconnect", "error", "stream is not writable")
-									return
-								}
-								dp, derr := encodeDisconnectPayload(reason)
-								if derr != nil {
-									logger.Info("failed to build disconnect", "error", derr)
-									return
-								}
-								if err := writeFramedPacket(w, 1, dp); err != nil {
-									logger.Info("failed to send disconnect", "error", err)
-									return
-								}
-								logger.Info("tx disconnect", "reason", reason)
-								if c, ok := r.(interface{ Close() error }); ok {
-									_ = c.Close()
-								}
+								s.sendDisconnect(r, logger, reason, disconnectKind(routeErr))
 								return
 							}
 						}
 					} else {
 						logger.Info("failed to decode connect", "payload_len", payloadLen)
+						s.metrics.ConnectDecoded(false)
 
-						if s.router != nil {
-							d, err := s.router.Decide(ctx, routing.Request{SNI: baseEvent.SNI})
+						if router != nil && !rejected {
+							d, err := s.decide(ctx, router, routing.Request{SNI: baseEvent.SNI, ClientIP: clientIP, ALPN: alpn})
+							if err == nil {
+								err = s.checkRouteAccess(clientIP, d.RouteIndex)
+							}
 							if err == nil {
 								decision = d
 								routeErr = nil
 								backend = decision.Backend
+								referralContent = decision.ReferralContent
 							} else {
 								routeErr = err
 								backend = routing.Backend{}
@@ -282,32 +331,18 @@ func (s *Server) dumpFrames(ctx context.Context, conn *quic.Conn, r io.Reader, l
 										"port", backend.Port,
 										"matched", decision.Matched,
 										"route_index", decision.RouteIndex,
+										"split_arm", decision.SplitArm,
 										"content_len", len(data),
 									)
+									s.metrics.ReferralSent(decision.RouteIndex, decision.Strategy)
+									recent.Referred(backend.Target())
 								}
 							}
 						}
 
 						if !referralSent && backend.Host == "" {
 							reason := s.disconnectReason(baseEvent.SNI, "", routeErr)
-							w, ok := r.(io.Writer)
-							if !ok {
-								logger.Info("failed to send disconnect", "error", "stream is not writable")
-								return
-							}
-							dp, derr := encodeDisconnectPayload(reason)
-							if derr != nil {
-								logger.Info("failed to build disconnect", "error", derr)
-								return
-							}
-							if err := writeFramedPacket(w, 1, dp); err != nil {
-								logger.Info("failed to send disconnect", "error", err)
-								return
-							}
-							logger.Info("tx disconnect", "reason", reason)
-							if c, ok := r.(interface{ Close() error }); ok {
-								_ = c.Close()
-							}
+							s.sendDisconnect(r, logger, reason, disconnectKind(routeErr))
 							return
 						}
 					}
@@ -338,21 +373,93 @@ func (s *Server) dumpFrames(ctx context.Context, conn *quic.Conn, r io.Reader, l
 	}
 }
 
+// sendDisconnect sends a Disconnect with reason and closes the stream.
+func (s *Server) sendDisconnect(r io.Reader, logger *slog.Logger, reason string, kind string) {
+	w, ok := r.(io.Writer)
+	if !ok {
+		logger.Info("failed to send disconnect", "error", "stream is not writable")
+		return
+	}
+	dp, err := encodeDisconnectPayload(reason)
+	if err != nil {
+		logger.Info("failed to build disconnect", "error", err)
+		return
+	}
+	if err := writeFramedPacket(w, 1, dp); err != nil {
+		logger.Info("failed to send disconnect", "error", err)
+		return
+	}
+	logger.Info("tx disconnect", "reason", reason)
+	s.metrics.DisconnectSent(kind)
+	if c, ok := r.(interface{ Close() error }); ok {
+		_ = c.Close()
+	}
+}
+
+func (s *Server) decide(ctx context.Context, router routing.Engine, req routing.Request) (routing.Decision, error) {
+	start := time.Now()
+	d, err := router.Decide(ctx, req)
+	s.metrics.ObserveDecide(time.Since(start))
+	return d, err
+}
+
+// disconnectKind classifies a routing outcome into the disconnect reason used for metrics and templates.
+func disconnectKind(routeErr error) string {
+	switch {
+	case routeErr == nil:
+		return "no_route"
+	case errors.Is(routeErr, errRateLimited):
+		return "rate_limited"
+	case errors.Is(routeErr, errAccessDenied):
+		return "access_denied"
+	case errors.Is(routeErr, routing.ErrMaintenance):
+		return "maintenance"
+	case errors.Is(routeErr, queue.ErrTimeout):
+		return "queue_timeout"
+	case errors.Is(routeErr, routing.ErrNoBackends):
+		return "no_backends"
+	case errors.Is(routeErr, routing.ErrDiscovery) || errors.Is(routeErr, routing.ErrDiscoveryNotSet) || errors.Is(routeErr, routing.ErrInvalidDiscoveryMode):
+		return "discovery_error"
+	default:
+		return "routing_error"
+	}
+}
+
 func (s *Server) disconnectReason(sni string, language string, routeErr error) string {
-	if routeErr == nil {
+	switch disconnectKind(routeErr) {
+	case "no_route":
 		msg := s.templateOrDefault(s.templateNoRoute(language), "no route")
 		return formatTemplate(msg, sni, nil)
-	}
-	if errors.Is(routeErr, routing.ErrNoBackends) {
+	case "no_backends":
 		msg := s.templateOrDefault(s.templateNoBackends(language), "no backends")
 		return formatTemplate(msg, sni, routeErr)
-	}
-	if errors.Is(routeErr, routing.ErrDiscovery) || errors.Is(routeErr, routing.ErrDiscoveryNotSet) || errors.Is(routeErr, routing.ErrInvalidDiscoveryMode) {
+	case "discovery_error":
 		msg := s.templateOrDefault(s.templateDiscoveryError(language), "discovery error")
 		return formatTemplate(msg, sni, routeErr)
+	case "maintenance":
+		msg := s.templateOrDefault(s.templateMaintenance(language), "maintenance")
+		end := ""
+		var me *routing.MaintenanceError
+		if errors.As(routeErr, &me) {
+			if strings.TrimSpace(me.Message) != "" {
+				msg = me.Message
+			}
+			end = routing.FormatMaintenanceEnd(me.End)
+		}
+		return strings.ReplaceAll(formatTemplate(msg, sni, routeErr), "${maintenance_end}", end)
+	case "rate_limited":
+		msg := s.templateOrDefault(s.templateRateLimited(language), "rate limited")
+		return formatTemplate(msg, sni, routeErr)
+	case "access_denied":
+		msg := s.templateOrDefault(s.templateAccessDenied(language), "access denied")
+		return formatTemplate(msg, sni, routeErr)
+	case "queue_timeout":
+		msg := s.templateOrDefault(s.templateQueueTimeout(language), "queue timeout")
+		return formatTemplate(msg, sni, routeErr)
+	default:
+		msg := s.templateOrDefault(s.templateRoutingError(language), "routing error")
+		return formatTemplate(msg, sni, routeErr)
 	}
-	msg := s.templateOrDefault(s.templateRoutingError(language), "routing error")
-	return formatTemplate(msg, sni, routeErr)
 }
 
 func (s *Server) templateNoRoute(language string) string {
@@ -371,12 +478,29 @@ func (s *Server) templateDiscoveryError(language string) string {
 	return s.disconnectMessagesForLanguage(language).DiscoveryError
 }
 
+func (s *Server) templateMaintenance(language string) string {
+	return s.disconnectMessagesForLanguage(language).Maintenance
+}
+
+func (s *Server) templateRateLimited(language string) string {
+	return s.disconnectMessagesForLanguage(language).RateLimited
+}
+
+func (s *Server) templateAccessDenied(language string) string {
+	return s.disconnectMessagesForLanguage(language).AccessDenied
+}
+
+func (s *Server) templateQueueTimeout(language string) string {
+	return s.disconnectMessagesForLanguage(language).QueueTimeout
+}
+
 func (s *Server) disconnectMessagesForLanguage(language string) config.DisconnectMessagesConfig {
-	if s == nil || s.cfg == nil {
+	cfg := s.config()
+	if cfg == nil {
 		return config.DisconnectMessagesConfig{}
 	}
-	base := s.cfg.Messages.Disconnect
-	locales := s.cfg.Messages.DisconnectLocales
+	base := cfg.Messages.Disconnect
+	locales := cfg.Messages.DisconnectLocales
 	if len(locales) == 0 {
 		return base
 	}
@@ -412,6 +536,18 @@ func (s *Server) disconnectMessagesForLanguage(language string) config.Disconnec
 	if strings.TrimSpace(loc.DiscoveryError) != "" {
 		base.DiscoveryError = loc.DiscoveryError
 	}
+	if strings.TrimSpace(loc.Maintenance) != "" {
+		base.Maintenance = loc.Maintenance
+	}
+	if strings.TrimSpace(loc.RateLimited) != "" {
+		base.RateLimited = loc.RateLimited
+	}
+	if strings.TrimSpace(loc.AccessDenied) != "" {
+		base.AccessDenied = loc.AccessDenied
+	}
+	if strings.TrimSpace(loc.QueueTimeout) != "" {
+		base.QueueTimeout = loc.QueueTimeout
+	}
 	return base
 }
 
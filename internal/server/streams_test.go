@@ -6,9 +6,13 @@ import (
 	"encoding/binary"
 	"io"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/metrics"
 	"github.com/hybrowse/hyrouter/internal/plugins"
 	"github.com/hybrowse/hyrouter/internal/referral"
 	"github.com/hybrowse/hyrouter/internal/routing"
@@ -423,3 +427,42 @@ func TestDumpFrames_RoutingErrorUsesTemplate(t *testing.T) {
 		t.Fatalf("nullbits=%02x", p[0])
 	}
 }
+
+func TestDumpFrames_RecordsMetrics(t *testing.T) {
+	connectPayload := buildConnectPayloadForTest(
+		"6708f121966c1c443f4b0eb525b2f81d0a8dc61f5003a692a8fa157e5e02cea9",
+		0,
+		"d3e6ef90-e113-49a7-a845-1c11f24fe166",
+		"de-DE",
+		"tok",
+		"Krymo",
+	)
+	frame := make([]byte, 8+len(connectPayload))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(connectPayload)))
+	binary.LittleEndian.PutUint32(frame[4:8], 0)
+	copy(frame[8:], connectPayload)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	m := metrics.New()
+	s := &Server{logger: logger, metrics: m, router: routing.NewStaticEngine(routing.Config{
+		Default: &routing.Pool{Strategy: "round_robin", Backends: []routing.Backend{{Host: "play.hyvane.com", Port: 5520}}},
+	})}
+	s.dumpFrames(context.Background(), nil, &rw{r: bytes.NewReader(frame)}, logger, routing.Decision{Matched: false, RouteIndex: -1, SelectedIndex: -1}, nil, plugins.ConnectEvent{SNI: "x"})
+
+	s.router = routing.NewStaticEngine(routing.Config{})
+	s.dumpFrames(context.Background(), nil, &rw{r: bytes.NewReader(frame)}, logger, routing.Decision{Matched: false, RouteIndex: -1, SelectedIndex: -1}, nil, plugins.ConnectEvent{SNI: "x"})
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	out := rec.Body.String()
+	for _, want := range []string{
+		`hyrouter_connect_packets_total{result="decoded"} 2`,
+		`hyrouter_referrals_total{route_index="-1",strategy="round_robin"} 1`,
+		`hyrouter_disconnects_total{reason="no_route"} 1`,
+		"hyrouter_routing_decide_duration_seconds_count 2",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Package validate runs the static checks behind `hyrouter validate`.
+package validate
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/plugins"
+	"github.com/hybrowse/hyrouter/internal/referral"
+	"golang.org/x/text/language"
+)
+
+// Config runs cfg.Validate plus checks that need files or other packages, and returns every problem found.
+// Each problem starts with the YAML path of the offending field.
+func Config(ctx context.Context, cfg *config.Config) []error {
+	if cfg == nil {
+		return []error{fmt.Errorf("config must not be nil")}
+	}
+	problems := config.Problems(cfg.Validate())
+	problems = append(problems, checkTLS(cfg)...)
+	problems = append(problems, checkPlugins(ctx, cfg)...)
+	problems = append(problems, checkReferral(cfg)...)
+	problems = append(problems, checkLocales(cfg)...)
+	problems = append(problems, config.Problems(cfg.Routing.LintKeys())...)
+	return problems
+}
+
+func checkTLS(cfg *config.Config) []error {
+	if cfg.TLS.CertFile == "" || cfg.TLS.KeyFile == "" {
+		return nil
+	}
+	if _, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile); err != nil {
+		return []error{fmt.Errorf("tls.cert_file/tls.key_file: %w", err)}
+	}
+	return nil
+}
+
+func checkPlugins(ctx context.Context, cfg *config.Config) []error {
+	var errs []error
+	for i, p := range cfg.Plugins {
+		if !strings.EqualFold(p.Type, "wasm") || p.WASM == nil || p.WASM.Path == "" {
+			continue
+		}
+		if err := plugins.CheckWASM(ctx, p.WASM.Path); err != nil {
+			errs = append(errs, fmt.Errorf("plugins[%d].wasm.path: %w", i, err))
+		}
+	}
+	if _, err := plugins.OrderPluginConfigs(cfg.Plugins); err != nil {
+		errs = append(errs, fmt.Errorf("plugins: %w", err))
+	}
+	return errs
+}
+
+func checkReferral(cfg *config.Config) []error {
+	if cfg.Referral == nil || strings.TrimSpace(cfg.Referral.HMACSecret) == "" {
+		return nil
+	}
+	if _, err := referral.DecodeSecret(cfg.Referral.HMACSecret); err != nil {
+		return []error{fmt.Errorf("referral.hmac_secret: %w", err)}
+	}
+	return nil
+}
+
+func checkLocales(cfg *config.Config) []error {
+	keys := make([]string, 0, len(cfg.Messages.DisconnectLocales))
+	for k := range cfg.Messages.DisconnectLocales {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var errs []error
+	for _, k := range keys {
+		if _, err := language.Parse(k); err != nil {
+			errs = append(errs, fmt.Errorf("messages.disconnect_locales.%s: invalid BCP-47 language tag: %w", k, err))
+		}
+	}
+	return errs
+}
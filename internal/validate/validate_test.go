@@ -0,0 +1,92 @@
+package validate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hybrowse/hyrouter/internal/config"
+	"github.com/hybrowse/hyrouter/internal/routing"
+)
+
+func writeKeyPairForTest(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	tmpl := x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: time.Now().Add(-1 * time.Hour), NotAfter: time.Now().Add(1 * time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, priv.Public(), priv)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey: %v", err)
+	}
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write cert: %v", err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	return certPath, keyPath
+}
+
+func TestConfig_OK(t *testing.T) {
+	cfg := config.Default()
+	cfg.TLS.CertFile, cfg.TLS.KeyFile = writeKeyPairForTest(t, t.TempDir())
+	cfg.Referral = &config.ReferralConfig{HMACSecret: "base64:c2VjcmV0"}
+	cfg.Messages.DisconnectLocales = map[string]config.DisconnectMessagesConfig{"de": {}, "pt-BR": {}}
+	cfg.Routing.Default = &routing.Pool{Strategy: "round_robin", Backends: []routing.Backend{{Host: "a", Port: 1}}}
+	if problems := Config(context.Background(), cfg); len(problems) != 0 {
+		t.Fatalf("problems=%v", problems)
+	}
+}
+
+func TestConfig_ReportsAllProblems(t *testing.T) {
+	dir := t.TempDir()
+	certPath, _ := writeKeyPairForTest(t, dir)
+	badKey := filepath.Join(dir, "bad.key")
+	if err := os.WriteFile(badKey, []byte("nope"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	cfg := config.Default()
+	cfg.Listen = ""
+	cfg.TLS.CertFile = certPath
+	cfg.TLS.KeyFile = badKey
+	cfg.Referral = &config.ReferralConfig{HMACSecret: "base64:!!!"}
+	cfg.Plugins = []config.PluginConfig{{Name: "w", Type: "wasm", WASM: &config.WASMPluginConfig{Path: filepath.Join(dir, "missing.wasm")}}}
+	cfg.Messages.DisconnectLocales = map[string]config.DisconnectMessagesConfig{"de": {}, "not a tag": {}}
+	cfg.Routing.Default = &routing.Pool{Strategy: "round_robin", Sort: []routing.SortKey{{Key: "lable:x"}}, Backends: []routing.Backend{{Host: "a", Port: 1}}}
+
+	problems := Config(context.Background(), cfg)
+	msg := errors.Join(problems...).Error()
+	for _, want := range []string{
+		"listen must not be empty",
+		"tls.cert_file/tls.key_file: ",
+		"plugins[0].wasm.path: ",
+		"referral.hmac_secret: ",
+		"messages.disconnect_locales.not a tag: invalid BCP-47 language tag",
+		`routing.default.sort[0].key: unknown key "lable:x"`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("missing %q in:\n%s", want, msg)
+		}
+	}
+	if len(problems) != 6 {
+		t.Fatalf("problems=%d:\n%s", len(problems), msg)
+	}
+}